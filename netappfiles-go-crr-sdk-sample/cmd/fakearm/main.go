@@ -0,0 +1,109 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// This command starts a local fake Azure Resource Manager server,
+// backed by the in-memory ANF backend, so the sample can be run
+// end to end without an Azure subscription. It optionally writes an
+// authentication file pointing the sample at the server.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/signal"
+
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/config"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/fake"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/models"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/uri"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/utils"
+)
+
+const (
+	subscriptionID = "00000000-0000-0000-0000-000000000000"
+	tenantID       = "00000000-0000-0000-0000-000000000000"
+)
+
+func main() {
+
+	addr := flag.String("addr", "127.0.0.1:0", "address the server listens on")
+	authFile := flag.String("auth-file", "", "path of an authentication file to write, pointing at the server")
+	topologyLocation := flag.String("topology", "", "path to a topology file whose subnets are registered in the server")
+	locationPolling := flag.Bool("location-polling", false, "track accepted deletes and actions with a Location header instead of Azure-AsyncOperation")
+	flag.Parse()
+
+	backend := fake.New(subscriptionID)
+
+	if *topologyLocation != "" {
+		topology, err := config.LoadTopology(*topologyLocation)
+		if err != nil {
+			utils.ConsoleOutput(fmt.Sprintf("an error ocurred while loading topology: %v", err))
+			os.Exit(1)
+		}
+		for _, properties := range []*models.Properties{topology.Primary, topology.Secondary} {
+			backend.AddResource(uri.SubnetID(
+				subscriptionID,
+				properties.VnetResourceGroupName,
+				properties.VnetName,
+				properties.SubnetName,
+			).String())
+		}
+	}
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		utils.ConsoleOutput(fmt.Sprintf("an error ocurred while listening on %v: %v", *addr, err))
+		os.Exit(1)
+	}
+
+	server := fake.NewUnstartedServer(backend)
+	server.LocationPolling = *locationPolling
+	server.Listener.Close()
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	if *authFile != "" {
+		err = writeAuthFile(*authFile, server.URL+"/")
+		if err != nil {
+			utils.ConsoleOutput(fmt.Sprintf("an error ocurred while writing authentication file: %v", err))
+			os.Exit(1)
+		}
+		utils.ConsoleOutput(fmt.Sprintf("Authentication file written to %v, set AZURE_AUTH_LOCATION to use it", *authFile))
+	}
+
+	utils.ConsoleOutput(fmt.Sprintf("Fake ARM server listening on %v, press Ctrl+C to stop", server.URL))
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	<-signals
+}
+
+// writeAuthFile writes an authentication file with dummy credentials and all endpoints set to the server
+func writeAuthFile(path, endpoint string) error {
+
+	authInfo := map[string]string{
+		"clientId":                       "00000000-0000-0000-0000-000000000000",
+		"clientSecret":                   "fake",
+		"subscriptionId":                 subscriptionID,
+		"tenantId":                       tenantID,
+		"activeDirectoryEndpointUrl":     endpoint,
+		"resourceManagerEndpointUrl":     endpoint,
+		"activeDirectoryGraphResourceId": endpoint,
+		"managementEndpointUrl":          endpoint,
+	}
+
+	authJSON, err := json.MarshalIndent(authInfo, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, authJSON, 0600)
+}
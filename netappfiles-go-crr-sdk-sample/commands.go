@@ -0,0 +1,492 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Commands available in this sample, each one executes a single
+// step of the cross-region replication lifecycle.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/config"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/sdkutils"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/uri"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/utils"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/netapp/mgmt/netapp"
+	"github.com/Azure/go-autorest/autorest/to"
+)
+
+type (
+	// command - a single lifecycle step that can be executed from the command line
+	command struct {
+		name        string
+		description string
+		run         func(cntx context.Context, args []string)
+	}
+)
+
+func getCommands() []command {
+	return []command{
+		{"setup", "creates primary and secondary resources and authorizes the replication", setup},
+		{"status", "prints replication health of the destination volume, exits non-zero if unhealthy", status},
+		{"break", "breaks the replication, secondary volume becomes writable", breakCommand},
+		{"resync", "resyncs a broken replication from primary to secondary volume", resync},
+		{"failover", "breaks a fully transferred replication and promotes the secondary volume", failover},
+		{"failback", "resyncs a broken replication in the original or reverse direction", failback},
+		{"reverse", "makes the secondary volume the source and recreates the primary volume as its destination", reverse},
+		{"monitor", "polls replication lag and raises alerts when it goes over the RPO threshold", monitor},
+		{"schedule", "prints or changes the replication schedule of the secondary volume", schedule},
+		{"snapshot", "lists, creates, deletes or prunes snapshots of a volume, run with list, create, delete or prune", snapshotCommand},
+		{"drill", "clones the secondary volume from its latest replicated snapshot into a test volume and deletes it, replication is untouched", drill},
+		{"revert", "reverts a volume to a snapshot picked by name or point in time, newer data is discarded", revert},
+		{"teardown", "deletes replication, volumes, capacity pools and accounts from both sides", teardown},
+	}
+}
+
+// findCommand returns the command with the provided name
+func findCommand(name string) (command, bool) {
+	for _, cmd := range getCommands() {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// usage prints global flags and available commands
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage: %v [flags] <command> [command flags]\n\nFlags:\n", filepath.Base(os.Args[0]))
+	flag.PrintDefaults()
+	fmt.Fprintf(flag.CommandLine.Output(), "\nCommands:\n")
+	for _, cmd := range getCommands() {
+		fmt.Fprintf(flag.CommandLine.Output(), "  %-10v %v\n", cmd.name, cmd.description)
+	}
+}
+
+// resolveResourceIDs builds resource IDs not recorded in state file from topology names, used by commands that work on already existing resources
+func resolveResourceIDs() {
+	for _, side := range []string{"Primary", "Secondary"} {
+		accountID := uri.AccountID(subscriptionIDs[side], anfResources[side].ResourceGroupName, anfResources[side].AnfAccountName)
+		capacityPoolID := accountID.Child(uri.CapacityPoolsType, anfResources[side].CapacityPoolName)
+		volumeID := capacityPoolID.Child(uri.VolumesType, anfResources[side].VolumeName)
+
+		if anfResources[side].AccountID == "" {
+			anfResources[side].AccountID = accountID.String()
+		}
+		if anfResources[side].CapacityPoolID == "" {
+			anfResources[side].CapacityPoolID = capacityPoolID.String()
+		}
+		if anfResources[side].VolumeID == "" {
+			anfResources[side].VolumeID = volumeID.String()
+		}
+		if anfResources[side].SnapshotPolicyID == "" && topology.SnapshotPolicy != nil {
+			anfResources[side].SnapshotPolicyID = accountID.Child(uri.SnapshotPoliciesType, topology.SnapshotPolicy.Name).String()
+		}
+	}
+}
+
+// breakCommand breaks the replication between primary and secondary volumes
+func breakCommand(cntx context.Context, args []string) {
+
+	flags := flag.NewFlagSet("break", flag.ExitOnError)
+	skipSnapshot := flags.Bool("skip-snapshot", false, "skips the safety snapshot of the secondary volume")
+	flags.Parse(args)
+
+	resolveResourceIDs()
+
+	if !*skipSnapshot {
+		err := takeSafetySnapshot(cntx, preBreakSnapshotTag)
+		if err != nil {
+			utils.ConsoleOutput(fmt.Sprintf("an error ocurred while taking safety snapshot: %v", err))
+			exitCode = 1
+			return
+		}
+	}
+
+	err := breakReplication(cntx)
+	if err != nil {
+		utils.ConsoleOutput(fmt.Sprintf("an error ocurred while breaking replication volume: %v", err))
+		exitCode = 1
+		return
+	}
+	utils.ConsoleOutput("Replication successfully broken")
+}
+
+// resync resumes a broken replication, data written to secondary volume after the break is overwritten
+func resync(cntx context.Context, args []string) {
+
+	flags := flag.NewFlagSet("resync", flag.ExitOnError)
+	flags.Parse(args)
+
+	resolveResourceIDs()
+
+	warnOverwrite("Secondary")
+
+	err := resyncReplication(cntx, "Secondary")
+	if err != nil {
+		utils.ConsoleOutput(fmt.Sprintf("an error ocurred while resyncing replication: %v", err))
+		exitCode = 1
+		return
+	}
+	utils.ConsoleOutput("Replication successfully resynced")
+}
+
+// schedule prints the replication schedule of the secondary volume or changes it, topology file is updated with the new schedule
+func schedule(cntx context.Context, args []string) {
+
+	flags := flag.NewFlagSet("schedule", flag.ExitOnError)
+	newSchedule := flags.String("set", "", "new replication schedule, valid schedules are 10minutely, hourly and daily")
+	flags.Parse(args)
+
+	secondary := anfResources["Secondary"]
+
+	if *newSchedule == "" {
+		volume, err := anfServices["Secondary"].GetAnfVolume(
+			cntx,
+			secondary.ResourceGroupName,
+			secondary.AnfAccountName,
+			secondary.CapacityPoolName,
+			secondary.VolumeName,
+		)
+		if err != nil {
+			utils.ConsoleOutput(fmt.Sprintf("an error ocurred while getting %v volume: %v", secondary.VolumeName, err))
+			exitCode = 1
+			return
+		}
+		if volume.VolumeProperties == nil || volume.DataProtection == nil || volume.DataProtection.Replication == nil {
+			utils.ConsoleOutput(fmt.Sprintf("error: %v volume has no replication", secondary.VolumeName))
+			exitCode = 1
+			return
+		}
+		utils.ConsoleOutput(fmt.Sprintf("Replication schedule of %v volume: %v", secondary.VolumeName, strings.TrimPrefix(string(volume.DataProtection.Replication.ReplicationSchedule), "_")))
+		return
+	}
+
+	if !config.IsValidReplicationSchedule(*newSchedule) {
+		utils.ConsoleOutput(fmt.Sprintf("error: invalid replication schedule %q, valid schedules are 10minutely, hourly and daily", *newSchedule))
+		exitCode = 1
+		return
+	}
+
+	utils.ConsoleOutput(fmt.Sprintf("Changing replication schedule of %v volume to %v...", secondary.VolumeName, *newSchedule))
+	_, err := anfServices["Secondary"].UpdateAnfVolumeReplicationSchedule(
+		cntx,
+		secondary.ResourceGroupName,
+		secondary.AnfAccountName,
+		secondary.CapacityPoolName,
+		secondary.VolumeName,
+		*newSchedule,
+	)
+	if err != nil {
+		utils.ConsoleOutput(fmt.Sprintf("an error ocurred while changing replication schedule: %v", err))
+		exitCode = 1
+		return
+	}
+
+	topology.ReplicationSchedule = *newSchedule
+	err = config.SetReplicationSchedule(topologyLocation, *newSchedule)
+	if err != nil {
+		utils.ConsoleOutput(fmt.Sprintf("an error ocurred while saving topology: %v", err))
+		exitCode = 1
+		return
+	}
+	utils.ConsoleOutput(fmt.Sprintf("Replication schedule successfully changed, topology file %v updated", topologyLocation))
+}
+
+// breakReplication waits for secondary volume to be mirrored, breaks the replication and waits for it to be broken.
+// If replication is already broken there is nothing to break.
+func breakReplication(cntx context.Context) error {
+
+	utils.ConsoleOutput(fmt.Sprintf("\tWaiting for Mirrored state from %v volume...", anfResources["Secondary"].VolumeName))
+	replicationStatus, err := waitForMirrorState(cntx, "Secondary",
+		sdkutils.MirrorCondition{MirrorState: netapp.MirrorStateMirrored},
+		sdkutils.MirrorCondition{MirrorState: netapp.MirrorStateBroken},
+	)
+	if err != nil {
+		return err
+	}
+	if replicationStatus.MirrorState == netapp.MirrorStateBroken {
+		utils.ConsoleOutput(fmt.Sprintf("\tReplication on %v volume is already broken", anfResources["Secondary"].VolumeName))
+		return nil
+	}
+
+	utils.ConsoleOutput(fmt.Sprintf("\tBreaking volume replication on %v volume...", anfResources["Secondary"].VolumeName))
+	err = anfServices["Secondary"].BreakAnfVolumeReplication(
+		cntx,
+		anfResources["Secondary"].ResourceGroupName,
+		anfResources["Secondary"].AnfAccountName,
+		anfResources["Secondary"].CapacityPoolName,
+		anfResources["Secondary"].VolumeName,
+	)
+	if err != nil {
+		return err
+	}
+
+	utils.ConsoleOutput(fmt.Sprintf("\tWaiting for Broken state from %v volume...", anfResources["Secondary"].VolumeName))
+	_, err = waitForMirrorState(cntx, "Secondary", sdkutils.MirrorCondition{MirrorState: netapp.MirrorStateBroken})
+
+	return err
+}
+
+// waitForMirrorState waits for a side's volume replication to reach one of the accepted conditions,
+// reporting mirror state, relationship status and total progress whenever they change
+func waitForMirrorState(cntx context.Context, side string, conditions ...sdkutils.MirrorCondition) (netapp.ReplicationStatus, error) {
+
+	var lastReported string
+	progress := func(replicationStatus netapp.ReplicationStatus) {
+		reported := fmt.Sprintf("mirror state: %v, relationship status: %v, total progress: %v",
+			replicationStatus.MirrorState,
+			replicationStatus.RelationshipStatus,
+			to.String(replicationStatus.TotalProgress),
+		)
+		if reported != lastReported {
+			utils.ConsoleOutput(fmt.Sprintf("\t%v volume %v", side, reported))
+			lastReported = reported
+		}
+	}
+
+	result, replicationStatus, err := anfServices[side].WaitForMirrorState(cntx, anfResources[side].VolumeID, conditions, progress, 60, 50)
+	if err != nil {
+		return replicationStatus, fmt.Errorf("%v waiting for %v volume: %v", strings.ToLower(string(result)), anfResources[side].VolumeName, err)
+	}
+
+	return replicationStatus, nil
+}
+
+// teardown removes all resources in reverse order, starting with the data replication object on secondary volume.
+// When state file has recorded steps, only recorded resources are removed, otherwise resource ids are built from topology names.
+func teardown(cntx context.Context, args []string) {
+
+	flags := flag.NewFlagSet("teardown", flag.ExitOnError)
+	flags.Parse(args)
+
+	if !hasSetupSteps() {
+		resolveResourceIDs()
+		utils.ConsoleOutput("Performing clean up based on topology names")
+	} else {
+		utils.ConsoleOutput(fmt.Sprintf("Performing clean up based on state file %v", deploymentState.Path()))
+	}
+
+	_, failed := cleanUp(cntx, true)
+	if len(failed) > 0 {
+		exitCode = 1
+		return
+	}
+
+	// Steps of an unfinished reverse refer to removed resources
+	if !forgetReverseSteps() {
+		return
+	}
+	utils.ConsoleOutput("Cleanup completed!")
+}
+
+// cleanUp removes resources known by anfResources in reverse order: break replication, delete replication, volume, capacity pool, snapshot policy and account.
+// If stopOnError is false, it keeps removing the remaining resources after a failure. It returns removed resources and the ones that could not be removed.
+func cleanUp(cntx context.Context, stopOnError bool) (removed []string, failed []string) {
+
+	// Clean up must be executed in reverse order, mainly because replication must be deleted on secondary volume first
+	sideIndex := []string{"Secondary", "Primary"}
+
+	for _, side := range sideIndex {
+
+		// Break and delete replication only on secondary volume
+		if side == "Secondary" && anfResources[side].VolumeID != "" {
+			volumeID := anfResources[side].VolumeID
+			replicationID := fmt.Sprintf("%v (replication)", volumeID)
+
+			// Break replication, only possible if it was authorized
+			_, authorized := deploymentState.Completed(replicationStep)
+			if authorized || !hasSetupSteps() {
+				err := breakReplication(cntx)
+				if err != nil {
+					utils.ConsoleOutput(fmt.Sprintf("an error ocurred while breaking replication volume: %v", err))
+					failed = append(failed, replicationID)
+					if stopOnError {
+						return removed, failed
+					}
+				}
+			}
+
+			// Delete replication
+			utils.ConsoleOutput(fmt.Sprintf("\tRemoving data protection object from %v volume...", uri.GetAnfVolume(volumeID)))
+			err := anfServices[side].DeleteAnfVolumeReplication(
+				cntx,
+				uri.GetResourceGroup(volumeID),
+				uri.GetAnfAccount(volumeID),
+				uri.GetAnfCapacityPool(volumeID),
+				uri.GetAnfVolume(volumeID),
+			)
+			if err != nil && sdkutils.IsReplicationMissingError(err) {
+				err = nil
+			}
+			if err == nil {
+				err = anfServices[side].WaitForNoANFResource(cntx, volumeID, 60, 50, true)
+			}
+			if err != nil {
+				utils.ConsoleOutput(fmt.Sprintf("an error ocurred while deleting data replication: %v", err))
+				failed = append(failed, replicationID)
+				if stopOnError {
+					return removed, failed
+				}
+			} else {
+				if !forgetStep(replicationStep) {
+					return removed, append(failed, replicationID)
+				}
+				removed = append(removed, replicationID)
+				utils.ConsoleOutput("\tData replication successfully deleted")
+			}
+		}
+
+		// Volume deletion
+		if volumeID := anfResources[side].VolumeID; volumeID != "" {
+			utils.ConsoleOutput(fmt.Sprintf("\tRemoving %v volume...", volumeID))
+			err := anfServices[side].DeleteAnfVolume(
+				cntx,
+				uri.GetResourceGroup(volumeID),
+				uri.GetAnfAccount(volumeID),
+				uri.GetAnfCapacityPool(volumeID),
+				uri.GetAnfVolume(volumeID),
+			)
+			if err == nil {
+				err = anfServices[side].WaitForNoANFResource(cntx, volumeID, 60, 50, false)
+			}
+			if err != nil {
+				utils.ConsoleOutput(fmt.Sprintf("an error ocurred while deleting volume: %v", err))
+				failed = append(failed, volumeID)
+				if stopOnError {
+					return removed, failed
+				}
+			} else {
+				anfResources[side].VolumeID = ""
+				if !forgetStep(stepName(side, volumeStep)) {
+					return removed, append(failed, volumeID)
+				}
+				removed = append(removed, volumeID)
+				utils.ConsoleOutput("\tVolume successfully deleted")
+			}
+		}
+
+		// Pool Cleanup
+		if capacityPoolID := anfResources[side].CapacityPoolID; capacityPoolID != "" {
+			utils.ConsoleOutput(fmt.Sprintf("\tCleaning up capacity pool %v...", capacityPoolID))
+			err := anfServices[side].DeleteAnfCapacityPool(
+				cntx,
+				uri.GetResourceGroup(capacityPoolID),
+				uri.GetAnfAccount(capacityPoolID),
+				uri.GetAnfCapacityPool(capacityPoolID),
+			)
+			if err == nil {
+				err = anfServices[side].WaitForNoANFResource(cntx, capacityPoolID, 60, 50, false)
+			}
+			if err != nil {
+				utils.ConsoleOutput(fmt.Sprintf("an error ocurred while deleting capacity pool: %v", err))
+				failed = append(failed, capacityPoolID)
+				if stopOnError {
+					return removed, failed
+				}
+			} else {
+				anfResources[side].CapacityPoolID = ""
+				if !forgetStep(stepName(side, capacityPoolStep)) {
+					return removed, append(failed, capacityPoolID)
+				}
+				removed = append(removed, capacityPoolID)
+				utils.ConsoleOutput("\tCapacity pool successfully deleted")
+			}
+		}
+
+		// Snapshot policy Cleanup, only possible once no volume uses it
+		if snapshotPolicyID := anfResources[side].SnapshotPolicyID; snapshotPolicyID != "" {
+			utils.ConsoleOutput(fmt.Sprintf("\tCleaning up snapshot policy %v...", snapshotPolicyID))
+			err := anfServices[side].DeleteAnfSnapshotPolicy(
+				cntx,
+				uri.GetResourceGroup(snapshotPolicyID),
+				uri.GetAnfAccount(snapshotPolicyID),
+				uri.GetAnfSnapshotPolicy(snapshotPolicyID),
+			)
+			if err == nil {
+				err = anfServices[side].WaitForNoANFResource(cntx, snapshotPolicyID, 60, 50, false)
+			}
+			if err != nil {
+				utils.ConsoleOutput(fmt.Sprintf("an error ocurred while deleting snapshot policy: %v", err))
+				failed = append(failed, snapshotPolicyID)
+				if stopOnError {
+					return removed, failed
+				}
+			} else {
+				anfResources[side].SnapshotPolicyID = ""
+				if !forgetStep(stepName(side, snapshotPolicyStep)) {
+					return removed, append(failed, snapshotPolicyID)
+				}
+				removed = append(removed, snapshotPolicyID)
+				utils.ConsoleOutput("\tSnapshot policy successfully deleted")
+			}
+		}
+
+		// Account Cleanup
+		if accountID := anfResources[side].AccountID; accountID != "" {
+			utils.ConsoleOutput(fmt.Sprintf("\tCleaning up account %v...", accountID))
+			err := anfServices[side].DeleteAnfAccount(
+				cntx,
+				uri.GetResourceGroup(accountID),
+				uri.GetAnfAccount(accountID),
+			)
+			if err != nil {
+				utils.ConsoleOutput(fmt.Sprintf("an error ocurred while deleting account: %v", err))
+				failed = append(failed, accountID)
+				if stopOnError {
+					return removed, failed
+				}
+			} else {
+				anfResources[side].AccountID = ""
+				if !forgetStep(stepName(side, accountStep)) {
+					return removed, append(failed, accountID)
+				}
+				removed = append(removed, accountID)
+				utils.ConsoleOutput("\tAccount successfully deleted")
+			}
+		}
+	}
+
+	return removed, failed
+}
+
+// rollBack removes the resources created so far by setup and reports what was and was not removed
+func rollBack(cntx context.Context) {
+
+	if !hasSetupSteps() {
+		utils.ConsoleOutput("Rollback: no resources were created, nothing to remove")
+		return
+	}
+
+	utils.ConsoleOutput("Rolling back resources created by setup...")
+	removed, failed := cleanUp(cntx, false)
+
+	for _, resourceID := range removed {
+		utils.ConsoleOutput(fmt.Sprintf("Rollback removed: %v", resourceID))
+	}
+	for _, resourceID := range failed {
+		utils.ConsoleOutput(fmt.Sprintf("Rollback could not remove: %v", resourceID))
+	}
+	if len(failed) > 0 {
+		utils.ConsoleOutput(fmt.Sprintf("Rollback incomplete, remaining resources are still recorded in state file %v", deploymentState.Path()))
+	}
+}
+
+// forgetStep removes a step from state file once its resource is deleted, returns false if state could not be saved
+func forgetStep(name string) bool {
+	err := deploymentState.Remove(name)
+	if err != nil {
+		utils.ConsoleOutput(fmt.Sprintf("an error ocurred while saving state for step %v: %v", name, err))
+		exitCode = 1
+		return false
+	}
+	return true
+}
@@ -0,0 +1,198 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Non-disruptive disaster recovery drill, clones the secondary volume
+// from its latest replicated snapshot into a read-write test volume
+// and deletes it afterwards, leaving the replication untouched.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/sdkutils"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/utils"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/netapp/mgmt/netapp"
+	"github.com/Azure/go-autorest/autorest/to"
+)
+
+// drill creates a read-write test volume from the latest replicated snapshot of the secondary volume, prints its mount path and deletes it
+func drill(cntx context.Context, args []string) {
+
+	flags := flag.NewFlagSet("drill", flag.ExitOnError)
+	name := flags.String("name", "", "name of the test volume, defaults to <secondary volume>-drill-<UTC time>")
+	hold := flags.Duration("hold", 0, "time to keep the test volume before deleting it, e.g. 30m")
+	flags.Parse(args)
+
+	resolveResourceIDs()
+
+	secondary := anfResources["Secondary"]
+
+	drillVolumeName := *name
+	if drillVolumeName == "" {
+		drillVolumeName = fmt.Sprintf("%v-drill-%v", secondary.VolumeName, time.Now().UTC().Format("20060102T150405Z"))
+	}
+
+	// Latest replicated snapshot, the last point in time transferred from primary volume
+	snapshots, err := getSnapshots(cntx, "Secondary")
+	if err != nil {
+		utils.ConsoleOutput(fmt.Sprintf("an error ocurred while listing snapshots: %v", err))
+		exitCode = 1
+		return
+	}
+
+	var latest *sampleSnapshot
+	for i := range snapshots {
+		if snapshots[i].kind == "replication" {
+			latest = &snapshots[i]
+		}
+	}
+	if latest == nil {
+		utils.ConsoleOutput(fmt.Sprintf("error: %v volume has no replicated snapshots, wait for the first transfer to complete", secondary.VolumeName))
+		exitCode = 1
+		return
+	}
+	utils.ConsoleOutput(fmt.Sprintf("Latest replicated snapshot is %v, created %v", latest.name, latest.created.Format(time.RFC3339)))
+
+	// Test volume gets the same size as the secondary volume so the snapshot data fits
+	volume, err := anfServices["Secondary"].GetAnfVolume(
+		cntx,
+		secondary.ResourceGroupName,
+		secondary.AnfAccountName,
+		secondary.CapacityPoolName,
+		secondary.VolumeName,
+	)
+	if err != nil {
+		utils.ConsoleOutput(fmt.Sprintf("an error ocurred while getting %v volume: %v", secondary.VolumeName, err))
+		exitCode = 1
+		return
+	}
+	usageThreshold := volumeSizeBytes
+	if volume.VolumeProperties != nil && volume.UsageThreshold != nil {
+		usageThreshold = *volume.UsageThreshold
+	}
+
+	utils.ConsoleOutput(fmt.Sprintf("Creating test volume %v from snapshot %v...", drillVolumeName, latest.name))
+	drillTags := map[string]*string{"drill": to.StringPtr("true")}
+	for key, value := range sampleTags {
+		drillTags[key] = value
+	}
+	drillVolume, err := anfServices["Secondary"].CreateAnfVolume(
+		cntx,
+		secondary.Location,
+		secondary.ResourceGroupName,
+		secondary.AnfAccountName,
+		secondary.CapacityPoolName,
+		drillVolumeName,
+		secondary.ServiceLevel,
+		getSubnetID("Secondary"),
+		latest.snapshotID,
+		protocolTypes,
+		usageThreshold,
+		false,
+		true,
+		drillTags,
+		netapp.VolumePropertiesDataProtection{},
+	)
+	if err != nil {
+		utils.ConsoleOutput(fmt.Sprintf("an error ocurred while creating test volume: %v", err))
+		exitCode = 1
+		return
+	}
+
+	// From here on the test volume is deleted whatever the outcome
+	defer deleteDrillVolume(cntx, drillVolumeName, *drillVolume.ID)
+
+	utils.ConsoleOutput("Waiting for test volume to be ready...")
+	err = anfServices["Secondary"].WaitForANFResource(cntx, *drillVolume.ID, 60, 50, false)
+	if err != nil {
+		utils.ConsoleOutput(fmt.Sprintf("an error ocurred while waiting for test volume: %v", err))
+		exitCode = 1
+		return
+	}
+
+	// Mount targets are only reported once the volume is ready
+	drillVolume, err = anfServices["Secondary"].GetAnfVolume(
+		cntx,
+		secondary.ResourceGroupName,
+		secondary.AnfAccountName,
+		secondary.CapacityPoolName,
+		drillVolumeName,
+	)
+	if err != nil {
+		utils.ConsoleOutput(fmt.Sprintf("an error ocurred while getting test volume: %v", err))
+		exitCode = 1
+		return
+	}
+	utils.ConsoleOutput(fmt.Sprintf("Test volume is ready and writable, it holds primary volume data as of %v:", latest.created.Format(time.RFC3339)))
+	printVolume(drillVolume)
+
+	if *hold > 0 {
+		utils.ConsoleOutput(fmt.Sprintf("Keeping test volume for %v, interrupt to delete it earlier...", *hold))
+
+		// Only the hold is interrupted, the test volume is deleted with the command context
+		holdCntx, stop := signal.NotifyContext(cntx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		select {
+		case <-holdCntx.Done():
+			utils.ConsoleOutput("Hold interrupted")
+		case <-time.After(*hold):
+		}
+	}
+}
+
+// deleteDrillVolume deletes the drill test volume and waits until it is gone, reporting the replication state it left untouched
+func deleteDrillVolume(cntx context.Context, drillVolumeName, drillVolumeID string) {
+
+	secondary := anfResources["Secondary"]
+
+	utils.ConsoleOutput(fmt.Sprintf("Deleting test volume %v...", drillVolumeName))
+	err := anfServices["Secondary"].DeleteAnfVolume(
+		cntx,
+		secondary.ResourceGroupName,
+		secondary.AnfAccountName,
+		secondary.CapacityPoolName,
+		drillVolumeName,
+	)
+	if err != nil {
+		utils.ConsoleOutput(fmt.Sprintf("an error ocurred while deleting test volume, delete %v manually: %v", drillVolumeID, err))
+		exitCode = 1
+		return
+	}
+	err = anfServices["Secondary"].WaitForNoANFResource(cntx, drillVolumeID, 60, 50, false)
+	if err != nil {
+		utils.ConsoleOutput(fmt.Sprintf("an error ocurred while waiting for test volume deletion, check %v manually: %v", drillVolumeID, err))
+		exitCode = 1
+		return
+	}
+	utils.ConsoleOutput("Test volume successfully deleted")
+
+	replicationStatus, err := anfServices["Secondary"].GetAnfVolumeReplicationStatus(
+		cntx,
+		secondary.ResourceGroupName,
+		secondary.AnfAccountName,
+		secondary.CapacityPoolName,
+		secondary.VolumeName,
+	)
+	if err != nil {
+		if !sdkutils.IsReplicationMissingError(err) {
+			utils.ConsoleOutput(fmt.Sprintf("an error ocurred while getting replication status: %v", err))
+			exitCode = 1
+		}
+		return
+	}
+	utils.ConsoleOutput(fmt.Sprintf("%v volume replication is untouched, mirror state: %v, relationship status: %v",
+		secondary.VolumeName,
+		replicationStatus.MirrorState,
+		replicationStatus.RelationshipStatus,
+	))
+}
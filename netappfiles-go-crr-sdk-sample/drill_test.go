@@ -0,0 +1,65 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/uri"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/netapp/mgmt/netapp"
+)
+
+func TestDrillInterruptedHoldDeletesVolume(t *testing.T) {
+
+	backend := newFakeSample(t)
+	runCommand(t, setup)
+
+	// Interrupts sent before drill listens for them must not stop the test process
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	process, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatalf("cannot find test process: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(10 * time.Millisecond):
+				if err := process.Signal(os.Interrupt); err != nil {
+					t.Errorf("cannot interrupt drill: %v", err)
+					return
+				}
+			}
+		}
+	}()
+
+	start := time.Now()
+	runCommand(t, drill, "-name", "drill1", "-hold", "1h")
+	close(done)
+
+	if elapsed := time.Since(start); elapsed > time.Minute {
+		t.Errorf("drill held test volume for %v after interrupt", elapsed)
+	}
+	for _, resourceID := range backend.ResourceIDs() {
+		if strings.EqualFold(uri.GetAnfVolume(resourceID), "drill1") {
+			t.Errorf("drill left test volume %v", resourceID)
+		}
+	}
+	if got := countResources(backend)[uri.VolumesType]; got != 2 {
+		t.Errorf("drill left %v volumes, want 2", got)
+	}
+	assertReplicationState(t, backend, anfResources["Primary"].VolumeID, netapp.MirrorStateMirrored, netapp.RelationshipStatusIdle)
+}
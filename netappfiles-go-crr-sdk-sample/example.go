@@ -1,362 +1,556 @@
-// Copyright (c) Microsoft and contributors.  All rights reserved.
-//
-// This source code is licensed under the MIT license found in the
-// LICENSE file in the root directory of this source tree.
-
-// This sample code shows how to enable cross-region replication
-// on an NFSv3 volume by creating primary and secondary resources
-// (Account, Capacity Pool, Volumes), then enabling it from primary
-// volume. Clean up process (not enabled by default) is made in
-// reverse order, but it starts by deleting the data replication object
-// from secondary volume. Clean up process is not taking place if
-// there is an execution failure, you will need to clean it up manually
-// in this case.
-
-package main
-
-import (
-	"context"
-	"fmt"
-	"os"
-	"strings"
-
-	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/sdkutils"
-	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/utils"
-	"github.com/Azure/azure-sdk-for-go/profiles/latest/netapp/mgmt/netapp"
-	"github.com/Azure/go-autorest/autorest/to"
-)
-
-const (
-	virtualNetworksApiVersion string = "2019-09-01"
-)
-
-type (
-	// Properties - properties to be used when defining primary and secondary anf resources
-	Properties struct {
-		Location              string
-		ResourceGroupName     string
-		VnetResourceGroupName string
-		VnetName              string
-		SubnetName            string
-		AnfAccountName        string
-		CapacityPoolName      string
-		VolumeName            string
-		ServiceLevel          string // Valid service levels are Standard, Premium and Ultra
-		VolumeID              string // This will be populated after resource is created
-		CapacityPoolID        string // This will be populated after resource is created
-		AccountID             string // This will be populated after resource is created
-	}
-)
-
-var (
-	shouldCleanUp bool = false
-
-	// Important - change ANF related variables below to appropriate values related to your environment
-	// Share ANF properties related
-	capacityPoolSizeBytes int64 = 4398046511104 // 4TiB (minimum capacity pool size)
-	volumeSizeBytes       int64 = 107374182400  // 100GiB (minimum volume size)
-	protocolTypes               = []string{"NFSv3"}
-	sampleTags                  = map[string]*string{
-		"Author":  to.StringPtr("ANF Go CRR SDK Sample"),
-		"Service": to.StringPtr("Azure Netapp Files"),
-	}
-
-	// ANF Resource Properties
-	anfResources = map[string]*Properties{
-		"Primary": {
-			Location:              "westus",
-			ResourceGroupName:     "anf-primary-rg",
-			VnetResourceGroupName: "anf-primary-rg",
-			VnetName:              "westus-primary-vnet",
-			SubnetName:            "anf-primary-sn",
-			AnfAccountName:        "PrimaryANFAccount",
-			CapacityPoolName:      "PrimaryPool",
-			ServiceLevel:          "Premium",
-			VolumeName:            "PrimaryVolume",
-		},
-		"Secondary": {
-			Location:              "eastus",
-			ResourceGroupName:     "anf-secondary-rg",
-			VnetResourceGroupName: "anf-secondary-rg",
-			VnetName:              "eastus-secondary-vnet",
-			SubnetName:            "anf-secondary-sn",
-			AnfAccountName:        "SecondaryANFAccount",
-			CapacityPoolName:      "SecondaryPool",
-			ServiceLevel:          "Standard",
-			VolumeName:            "SecondaryVolume",
-		},
-	}
-
-	// Some other variables used throughout the course of the code execution - no need to change it
-	exitCode int
-)
-
-func main() {
-
-	cntx := context.Background()
-
-	// Cleanup and exit handling
-	defer func() { exit(cntx); os.Exit(exitCode) }()
-
-	utils.PrintHeader("Azure NetAppFiles Go CRR SDK Sample - Sample application that enables cross-region replication on an NFSv3 volume.")
-
-	// Getting subscription ID from authentication file
-	config, err := utils.ReadAzureBasicInfoJSON(os.Getenv("AZURE_AUTH_LOCATION"))
-	if err != nil {
-		utils.ConsoleOutput(fmt.Sprintf("an error ocurred getting non-sensitive info from AzureAuthFile: %v", err))
-		exitCode = 1
-		shouldCleanUp = false
-		return
-	}
-
-	// Primary and Secondary ANF operations
-	sideIndex := []string{"Primary", "Secondary"}
-	for _, side := range sideIndex {
-		utils.ConsoleOutput(fmt.Sprintf("Working on %v ANF Resources...", side))
-
-		// Checking if subnet exists before any other operation starts
-		subnetID := fmt.Sprintf("/subscriptions/%v/resourceGroups/%v/providers/Microsoft.Network/virtualNetworks/%v/subnets/%v",
-			*config.SubscriptionID,
-			anfResources[side].VnetResourceGroupName,
-			anfResources[side].VnetName,
-			anfResources[side].SubnetName,
-		)
-
-		utils.ConsoleOutput(fmt.Sprintf("Checking if vnet/subnet %v exists.", subnetID))
-
-		_, err = sdkutils.GetResourceByID(cntx, subnetID, virtualNetworksApiVersion)
-		if err != nil {
-			if string(err.Error()) == "NotFound" {
-				utils.ConsoleOutput(fmt.Sprintf("error: %v subnet %v not found: %v", side, subnetID, err))
-			} else {
-				utils.ConsoleOutput(fmt.Sprintf("error: an error ocurred trying to check if %v %v subnet exists: %v", side, subnetID, err))
-			}
-			exitCode = 1
-			shouldCleanUp = false
-			return
-		}
-
-		// Account creation
-		utils.ConsoleOutput(fmt.Sprintf("Creating %v Azure NetApp Files account...", side))
-
-		account, err := sdkutils.CreateAnfAccount(cntx, anfResources[side].Location, anfResources[side].ResourceGroupName, anfResources[side].AnfAccountName, nil, sampleTags)
-		if err != nil {
-			utils.ConsoleOutput(fmt.Sprintf("an error ocurred while creating account: %v", err))
-			exitCode = 1
-			shouldCleanUp = false
-			return
-		}
-		anfResources[side].AccountID = *account.ID
-		utils.ConsoleOutput(fmt.Sprintf("Account successfully created, resource id: %v", anfResources[side].AccountID))
-
-		// Capacity pool creation
-		utils.ConsoleOutput(fmt.Sprintf("Creating %v Capacity Pool...", side))
-		capacityPool, err := sdkutils.CreateAnfCapacityPool(
-			cntx,
-			anfResources[side].Location,
-			anfResources[side].ResourceGroupName,
-			anfResources[side].AnfAccountName,
-			anfResources[side].CapacityPoolName,
-			anfResources[side].ServiceLevel,
-			capacityPoolSizeBytes,
-			sampleTags,
-		)
-		if err != nil {
-			utils.ConsoleOutput(fmt.Sprintf("an error ocurred while creating %v capacity pool: %v", side, err))
-			exitCode = 1
-			shouldCleanUp = false
-			return
-		}
-		anfResources[side].CapacityPoolID = *capacityPool.ID
-		utils.ConsoleOutput(fmt.Sprintf("Capacity Pool successfully created, resource id: %v", anfResources[side].CapacityPoolID))
-
-		// Volume creation
-		utils.ConsoleOutput(fmt.Sprintf("Creating %v NFSv3 Volume...", side))
-
-		// Build data protection object if Secondary side.
-		dataProtectionObject := netapp.VolumePropertiesDataProtection{}
-		if side == "Secondary" {
-			utils.ConsoleOutput(fmt.Sprintf("\tCreating data protection object since this is %v volume...", side))
-			utils.ConsoleOutput(fmt.Sprintf("\tRemote volume id is %v...", anfResources["Primary"].VolumeID))
-			replicationObject := netapp.ReplicationObject{
-				EndpointType:           "dst",
-				RemoteVolumeRegion:     to.StringPtr(anfResources["Primary"].Location),
-				RemoteVolumeResourceID: to.StringPtr(anfResources["Primary"].VolumeID),
-				ReplicationSchedule:    "hourly",
-			}
-
-			dataProtectionObject = netapp.VolumePropertiesDataProtection{
-				Replication: &replicationObject,
-			}
-		}
-
-		volume, err := sdkutils.CreateAnfVolume(
-			cntx,
-			anfResources[side].Location,
-			anfResources[side].ResourceGroupName,
-			anfResources[side].AnfAccountName,
-			anfResources[side].CapacityPoolName,
-			anfResources[side].VolumeName,
-			anfResources[side].ServiceLevel,
-			subnetID,
-			"",
-			protocolTypes,
-			volumeSizeBytes,
-			false,
-			true,
-			sampleTags,
-			dataProtectionObject,
-		)
-
-		if err != nil {
-			utils.ConsoleOutput(fmt.Sprintf("an error ocurred while creating %v volume: %v", side, err))
-			exitCode = 1
-			shouldCleanUp = false
-			return
-		}
-
-		anfResources[side].VolumeID = *volume.ID
-		utils.ConsoleOutput(fmt.Sprintf("Volume successfully created, resource id: %v", anfResources[side].VolumeID))
-
-		utils.ConsoleOutput("Waiting for volume to be ready...")
-		err = sdkutils.WaitForANFResource(cntx, anfResources[side].VolumeID, 60, 50, false)
-		if err != nil {
-			utils.ConsoleOutput(fmt.Sprintf("an error ocurred while waiting for %v volume: %v", side, err))
-			exitCode = 1
-			shouldCleanUp = false
-			return
-		}
-	}
-
-	// Authorizing replication
-	utils.ConsoleOutput("Authorizing replication...")
-	err = sdkutils.AuthorizeReplication(
-		cntx,
-		anfResources["Primary"].ResourceGroupName,
-		anfResources["Primary"].AnfAccountName,
-		anfResources["Primary"].CapacityPoolName,
-		anfResources["Primary"].VolumeName,
-		anfResources["Secondary"].VolumeID,
-	)
-	if err != nil {
-		utils.ConsoleOutput(fmt.Sprintf("an error ocurred while authorizing replication: %v", err))
-		exitCode = 1
-		shouldCleanUp = false
-		return
-	}
-
-	utils.ConsoleOutput("Waiting for primary volume replication be ready...")
-	err = sdkutils.WaitForANFResource(cntx, anfResources["Primary"].VolumeID, 60, 50, true)
-	if err != nil {
-		utils.ConsoleOutput(fmt.Sprintf("an error ocurred while waiting for Primary volume be replication ready: %v", err))
-		exitCode = 1
-		shouldCleanUp = false
-		return
-	}
-}
-
-func exit(cntx context.Context) {
-	utils.ConsoleOutput("Exiting")
-
-	if shouldCleanUp {
-		utils.ConsoleOutput("\tPerforming clean up")
-
-		// Clean up must be executed in reverse order, mainly because replication must be deleted on secondary volume first
-		sideIndex := []string{"Secondary", "Primary"}
-
-		for _, side := range sideIndex {
-			resourceGroupName := anfResources[side].ResourceGroupName
-			accountName := anfResources[side].AnfAccountName
-			poolName := anfResources[side].CapacityPoolName
-			volumeName := anfResources[side].VolumeName
-
-			// Break and delete replication only on secondary volume
-			if side == "Secondary" {
-
-				// Break replication
-				utils.ConsoleOutput(fmt.Sprintf("\tWaiting for Mirrored state from %v volume...", anfResources[side].VolumeName))
-				sdkutils.WaitForMirrorState(cntx, anfResources[side].VolumeID, netapp.MirrorStateMirrored, 60, 50)
-				utils.ConsoleOutput(fmt.Sprintf("\tBreaking volume replication on %v volume...", anfResources[side].VolumeName))
-				err := sdkutils.BreakAnfVolumeReplication(
-					cntx,
-					resourceGroupName,
-					accountName,
-					poolName,
-					volumeName,
-				)
-				if err != nil {
-					utils.ConsoleOutput(fmt.Sprintf("an error ocurred while breaking replication volume: %v", err))
-					exitCode = 1
-					return
-				}
-
-				// Delete replication
-				utils.ConsoleOutput(fmt.Sprintf("\tWaiting for Broken state from %v volume...", anfResources[side].VolumeName))
-				sdkutils.WaitForMirrorState(cntx, anfResources[side].VolumeID, netapp.MirrorStateBroken, 60, 50)
-				utils.ConsoleOutput(fmt.Sprintf("\tRemoving data protection object from %v volume...", anfResources[side].VolumeName))
-				err = sdkutils.DeleteAnfVolumeReplication(
-					cntx,
-					resourceGroupName,
-					accountName,
-					poolName,
-					volumeName,
-				)
-				if err != nil && !strings.Contains(err.Error(), "VolumeReplicationMissing") {
-					utils.ConsoleOutput(fmt.Sprintf("an error ocurred while deleting data replication: %v", err))
-					exitCode = 1
-					return
-				}
-				sdkutils.WaitForNoANFResource(cntx, anfResources[side].VolumeID, 60, 50, true)
-				utils.ConsoleOutput("\tData replication successfully deleted")
-			}
-
-			// Volume deletion
-			utils.ConsoleOutput(fmt.Sprintf("\tRemoving %v volume...", anfResources[side].VolumeID))
-			err := sdkutils.DeleteAnfVolume(
-				cntx,
-				resourceGroupName,
-				accountName,
-				poolName,
-				volumeName,
-			)
-			if err != nil {
-				utils.ConsoleOutput(fmt.Sprintf("an error ocurred while deleting volume: %v", err))
-				exitCode = 1
-				return
-			}
-			sdkutils.WaitForNoANFResource(cntx, anfResources[side].VolumeID, 60, 50, false)
-			utils.ConsoleOutput("\tVolume successfully deleted")
-
-			// Pool Cleanup
-			utils.ConsoleOutput(fmt.Sprintf("\tCleaning up capacity pool %v...", anfResources[side].CapacityPoolID))
-			err = sdkutils.DeleteAnfCapacityPool(
-				cntx,
-				resourceGroupName,
-				accountName,
-				poolName,
-			)
-			if err != nil {
-				utils.ConsoleOutput(fmt.Sprintf("an error ocurred while deleting capacity pool: %v", err))
-				exitCode = 1
-				return
-			}
-			sdkutils.WaitForNoANFResource(cntx, anfResources[side].CapacityPoolID, 60, 50, false)
-			utils.ConsoleOutput("\tCapacity pool successfully deleted")
-
-			// Account Cleanup
-			utils.ConsoleOutput(fmt.Sprintf("\tCleaning up account %v...", anfResources[side].AccountID))
-			err = sdkutils.DeleteAnfAccount(
-				cntx,
-				resourceGroupName,
-				accountName,
-			)
-			if err != nil {
-				utils.ConsoleOutput(fmt.Sprintf("an error ocurred while deleting account: %v", err))
-				exitCode = 1
-				return
-			}
-			utils.ConsoleOutput("\tAccount successfully deleted")
-		}
-		utils.ConsoleOutput("\tCleanup completed!")
-	}
-}
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// This sample code shows how to enable cross-region replication
+// on an NFSv3 volume by creating primary and secondary resources
+// (Account, Capacity Pool, Volumes), then enabling it from primary
+// volume. Each lifecycle step is exposed as a command (setup, status,
+// break, resync and teardown). Teardown is made in reverse order,
+// but it starts by deleting the data replication object from
+// secondary volume. Teardown is not taking place automatically if
+// there is an execution failure, unless setup runs with -rollback,
+// you will need to run it in this case.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/cloud"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/config"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/iam"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/models"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/sdkutils"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/state"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/uri"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/utils"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/netapp/mgmt/netapp"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/Azure/go-autorest/autorest/to"
+)
+
+const (
+	virtualNetworksApiVersion string = "2019-09-01"
+
+	// Setup steps recorded in state file
+	accountStep        string = "account"
+	capacityPoolStep   string = "capacityPool"
+	volumeStep         string = "volume"
+	snapshotPolicyStep string = "snapshotPolicy"
+	replicationStep    string = "replicationAuthorization"
+)
+
+var (
+	// Important - change ANF related variables below to appropriate values related to your environment
+	// Share ANF properties related
+	capacityPoolSizeBytes int64 = 4398046511104 // 4TiB (minimum capacity pool size)
+	volumeSizeBytes       int64 = 107374182400  // 100GiB (minimum volume size)
+	protocolTypes               = []string{"NFSv3"}
+	sampleTags                  = map[string]*string{
+		"Author":  to.StringPtr("ANF Go CRR SDK Sample"),
+		"Service": to.StringPtr("Azure Netapp Files"),
+	}
+
+	// ANF Resource Properties, loaded from topology file
+	topologyLocation string
+	topology         *models.Topology
+	anfResources     map[string]*models.Properties
+
+	// Completed steps and resource ids, loaded from state file
+	deploymentState *state.State
+
+	// Some other variables used throughout the course of the code execution - no need to change it
+	subscriptionIDs = map[string]string{}
+	anfServices     = map[string]*sdkutils.Service{}
+	exitCode        int
+)
+
+func main() {
+
+	cntx := context.Background()
+
+	// Exit handling
+	defer func() { exit(); os.Exit(exitCode) }()
+
+	utils.PrintHeader("Azure NetAppFiles Go CRR SDK Sample - Sample application that enables cross-region replication on an NFSv3 volume.")
+
+	flag.StringVar(&topologyLocation, "topology", os.Getenv("ANF_TOPOLOGY_LOCATION"), "path to the YAML or JSON topology file, defaults to ANF_TOPOLOGY_LOCATION environment variable")
+	stateLocation := flag.String("state", os.Getenv("ANF_STATE_LOCATION"), "path to the state file with completed steps and resource ids, defaults to ANF_STATE_LOCATION environment variable or <topology file name>.state.json")
+	cloudName := flag.String("cloud", os.Getenv("AZURE_ENVIRONMENT"), "cloud name (AzurePublicCloud, AzureUSGovernment, AzureChina, AzureGerman) or Azure Stack resource manager endpoint, defaults to AZURE_ENVIRONMENT environment variable")
+	cloudFile := flag.String("cloud-file", os.Getenv("AZURE_ENVIRONMENT_FILEPATH"), "path to a JSON file with custom cloud endpoints, defaults to AZURE_ENVIRONMENT_FILEPATH environment variable")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		exitCode = 1
+		return
+	}
+
+	cmd, found := findCommand(flag.Arg(0))
+	if !found {
+		utils.ConsoleOutput(fmt.Sprintf("unknown command: %v", flag.Arg(0)))
+		usage()
+		exitCode = 1
+		return
+	}
+
+	// Loading primary and secondary resource properties from topology file
+	var err error
+	topology, err = config.LoadTopology(topologyLocation)
+	if err != nil {
+		utils.ConsoleOutput(fmt.Sprintf("an error ocurred loading topology: %v", err))
+		exitCode = 1
+		return
+	}
+
+	anfResources = map[string]*models.Properties{
+		"Primary":   topology.Primary,
+		"Secondary": topology.Secondary,
+	}
+
+	// Loading completed steps from state file
+	if *stateLocation == "" {
+		*stateLocation = fmt.Sprintf("%v.state.json", strings.TrimSuffix(topologyLocation, filepath.Ext(topologyLocation)))
+	}
+
+	deploymentState, err = state.Load(*stateLocation)
+	if err != nil {
+		utils.ConsoleOutput(fmt.Sprintf("an error ocurred loading state: %v", err))
+		exitCode = 1
+		return
+	}
+
+	for _, side := range []string{"Primary", "Secondary"} {
+		anfResources[side].AccountID, _ = deploymentState.Completed(stepName(side, accountStep))
+		anfResources[side].CapacityPoolID, _ = deploymentState.Completed(stepName(side, capacityPoolStep))
+		anfResources[side].VolumeID, _ = deploymentState.Completed(stepName(side, volumeStep))
+		anfResources[side].SnapshotPolicyID, _ = deploymentState.Completed(stepName(side, snapshotPolicyStep))
+	}
+
+	// Selecting the cloud, endpoints come from the authentication file when no cloud is selected and one is used
+	var environment azure.Environment
+	if *cloudName == "" && *cloudFile == "" && os.Getenv("AZURE_AUTH_LOCATION") != "" {
+		azureBasicInfo, err := utils.ReadAzureBasicInfoJSON(os.Getenv("AZURE_AUTH_LOCATION"))
+		if err != nil {
+			utils.ConsoleOutput(fmt.Sprintf("an error ocurred getting non-sensitive info from AzureAuthFile: %v", err))
+			exitCode = 1
+			return
+		}
+		environment = cloud.FromEndpoints(to.String(azureBasicInfo.ResourceManagerEndpointURL), to.String(azureBasicInfo.ActiveDirectoryEndpointURL))
+	} else {
+		environment, err = cloud.Load(*cloudName, *cloudFile)
+		if err != nil {
+			utils.ConsoleOutput(fmt.Sprintf("an error ocurred selecting cloud: %v", err))
+			exitCode = 1
+			return
+		}
+	}
+	utils.ConsoleOutput(fmt.Sprintf("Using %v cloud, resource manager endpoint %v", environment.Name, environment.ResourceManagerEndpoint))
+
+	// Authenticating once per distinct side credential, ARM clients are shared by all operations of a side
+	err = connectSides(environment)
+	if err != nil {
+		utils.ConsoleOutput(fmt.Sprintf("an error ocurred getting authorizer: %v", err))
+		exitCode = 1
+		return
+	}
+
+	cmd.run(cntx, flag.Args()[1:])
+}
+
+// setup creates primary and secondary resources and authorizes the replication between both volumes
+func setup(cntx context.Context, args []string) {
+
+	flags := flag.NewFlagSet("setup", flag.ExitOnError)
+	rollback := flags.Bool("rollback", false, "removes the resources created so far, in reverse order, if any step fails")
+	flags.Parse(args)
+
+	// Rollback handling, exitCode is set by any failed step
+	defer func() {
+		if *rollback && exitCode != 0 {
+			rollBack(cntx)
+		}
+	}()
+
+	if hasSetupSteps() {
+		utils.ConsoleOutput(fmt.Sprintf("Resuming setup from state file %v", deploymentState.Path()))
+	}
+
+	// Primary and Secondary ANF operations
+	sideIndex := []string{"Primary", "Secondary"}
+	for _, side := range sideIndex {
+		utils.ConsoleOutput(fmt.Sprintf("Working on %v ANF Resources...", side))
+
+		// Checking if subnet exists before any other operation starts
+		subnetID := getSubnetID(side)
+
+		utils.ConsoleOutput(fmt.Sprintf("Checking if vnet/subnet %v exists.", subnetID))
+
+		_, err := anfServices[side].GetResourceByID(cntx, subnetID, virtualNetworksApiVersion)
+		if err != nil {
+			if sdkutils.IsNotFoundError(err) {
+				utils.ConsoleOutput(fmt.Sprintf("error: %v subnet %v not found: %v", side, subnetID, err))
+			} else {
+				utils.ConsoleOutput(fmt.Sprintf("error: an error ocurred trying to check if %v %v subnet exists: %v", side, subnetID, err))
+			}
+			exitCode = 1
+			return
+		}
+
+		// Account creation
+		if anfResources[side].AccountID != "" {
+			utils.ConsoleOutput(fmt.Sprintf("%v account already created, resource id: %v", side, anfResources[side].AccountID))
+		} else {
+			utils.ConsoleOutput(fmt.Sprintf("Creating %v Azure NetApp Files account...", side))
+
+			account, err := anfServices[side].CreateAnfAccount(cntx, anfResources[side].Location, anfResources[side].ResourceGroupName, anfResources[side].AnfAccountName, nil, sampleTags)
+			if err != nil {
+				utils.ConsoleOutput(fmt.Sprintf("an error ocurred while creating account: %v", err))
+				exitCode = 1
+				return
+			}
+			anfResources[side].AccountID = *account.ID
+			utils.ConsoleOutput(fmt.Sprintf("Account successfully created, resource id: %v", anfResources[side].AccountID))
+
+			if !recordStep(stepName(side, accountStep), anfResources[side].AccountID) {
+				return
+			}
+		}
+
+		// Capacity pool creation
+		if anfResources[side].CapacityPoolID != "" {
+			utils.ConsoleOutput(fmt.Sprintf("%v capacity pool already created, resource id: %v", side, anfResources[side].CapacityPoolID))
+		} else {
+			utils.ConsoleOutput(fmt.Sprintf("Creating %v Capacity Pool...", side))
+			capacityPool, err := anfServices[side].CreateAnfCapacityPool(
+				cntx,
+				anfResources[side].Location,
+				anfResources[side].ResourceGroupName,
+				anfResources[side].AnfAccountName,
+				anfResources[side].CapacityPoolName,
+				anfResources[side].ServiceLevel,
+				capacityPoolSizeBytes,
+				sampleTags,
+			)
+			if err != nil {
+				utils.ConsoleOutput(fmt.Sprintf("an error ocurred while creating %v capacity pool: %v", side, err))
+				exitCode = 1
+				return
+			}
+			anfResources[side].CapacityPoolID = *capacityPool.ID
+			utils.ConsoleOutput(fmt.Sprintf("Capacity Pool successfully created, resource id: %v", anfResources[side].CapacityPoolID))
+
+			if !recordStep(stepName(side, capacityPoolStep), anfResources[side].CapacityPoolID) {
+				return
+			}
+		}
+
+		// Snapshot policy creation, on both accounts so the destination volume can use it after a failover
+		if topology.SnapshotPolicy != nil {
+			if anfResources[side].SnapshotPolicyID != "" {
+				utils.ConsoleOutput(fmt.Sprintf("%v snapshot policy already created, resource id: %v", side, anfResources[side].SnapshotPolicyID))
+			} else {
+				utils.ConsoleOutput(fmt.Sprintf("Creating %v snapshot policy %v...", side, topology.SnapshotPolicy.Name))
+				snapshotPolicy, err := anfServices[side].CreateAnfSnapshotPolicy(
+					cntx,
+					anfResources[side].Location,
+					anfResources[side].ResourceGroupName,
+					anfResources[side].AnfAccountName,
+					topology.SnapshotPolicy.Name,
+					getSnapshotPolicyProperties(topology.SnapshotPolicy),
+					sampleTags,
+				)
+				if err != nil {
+					utils.ConsoleOutput(fmt.Sprintf("an error ocurred while creating %v snapshot policy: %v", side, err))
+					exitCode = 1
+					return
+				}
+				anfResources[side].SnapshotPolicyID = *snapshotPolicy.ID
+				utils.ConsoleOutput(fmt.Sprintf("Snapshot policy successfully created, resource id: %v", anfResources[side].SnapshotPolicyID))
+
+				if !recordStep(stepName(side, snapshotPolicyStep), anfResources[side].SnapshotPolicyID) {
+					return
+				}
+			}
+		}
+
+		// Volume creation
+		if anfResources[side].VolumeID != "" {
+			utils.ConsoleOutput(fmt.Sprintf("%v volume already created, resource id: %v", side, anfResources[side].VolumeID))
+		} else {
+			utils.ConsoleOutput(fmt.Sprintf("Creating %v NFSv3 Volume...", side))
+
+			// Build data protection object if Secondary side, the Primary (source) volume gets the snapshot policy if any.
+			dataProtectionObject := netapp.VolumePropertiesDataProtection{}
+			if side == "Primary" && anfResources[side].SnapshotPolicyID != "" {
+				utils.ConsoleOutput(fmt.Sprintf("\tAttaching snapshot policy %v...", topology.SnapshotPolicy.Name))
+				dataProtectionObject.Snapshot = &netapp.VolumeSnapshotProperties{
+					SnapshotPolicyID: to.StringPtr(anfResources[side].SnapshotPolicyID),
+				}
+			}
+			if side == "Secondary" {
+				utils.ConsoleOutput(fmt.Sprintf("\tCreating data protection object since this is %v volume...", side))
+				utils.ConsoleOutput(fmt.Sprintf("\tRemote volume id is %v, replication schedule is %v...", anfResources["Primary"].VolumeID, topology.ReplicationSchedule))
+				var err error
+				dataProtectionObject, err = getDataProtectionObject("Primary")
+				if err != nil {
+					utils.ConsoleOutput(fmt.Sprintf("an error ocurred while creating data protection object: %v", err))
+					exitCode = 1
+					return
+				}
+			}
+
+			volume, err := anfServices[side].CreateAnfVolume(
+				cntx,
+				anfResources[side].Location,
+				anfResources[side].ResourceGroupName,
+				anfResources[side].AnfAccountName,
+				anfResources[side].CapacityPoolName,
+				anfResources[side].VolumeName,
+				anfResources[side].ServiceLevel,
+				subnetID,
+				"",
+				protocolTypes,
+				volumeSizeBytes,
+				false,
+				true,
+				sampleTags,
+				dataProtectionObject,
+			)
+
+			if err != nil {
+				utils.ConsoleOutput(fmt.Sprintf("an error ocurred while creating %v volume: %v", side, err))
+				exitCode = 1
+				return
+			}
+
+			anfResources[side].VolumeID = *volume.ID
+			utils.ConsoleOutput(fmt.Sprintf("Volume successfully created, resource id: %v", anfResources[side].VolumeID))
+
+			if !recordStep(stepName(side, volumeStep), anfResources[side].VolumeID) {
+				return
+			}
+		}
+
+		utils.ConsoleOutput("Waiting for volume to be ready...")
+		err = anfServices[side].WaitForANFResource(cntx, anfResources[side].VolumeID, 60, 50, false)
+		if err != nil {
+			utils.ConsoleOutput(fmt.Sprintf("an error ocurred while waiting for %v volume: %v", side, err))
+			exitCode = 1
+			return
+		}
+	}
+
+	// Authorizing replication
+	if _, completed := deploymentState.Completed(replicationStep); completed {
+		utils.ConsoleOutput("Replication already authorized")
+	} else {
+		utils.ConsoleOutput("Authorizing replication...")
+		err := anfServices["Primary"].AuthorizeReplication(
+			cntx,
+			anfResources["Primary"].ResourceGroupName,
+			anfResources["Primary"].AnfAccountName,
+			anfResources["Primary"].CapacityPoolName,
+			anfResources["Primary"].VolumeName,
+			anfResources["Secondary"].VolumeID,
+		)
+		if err != nil {
+			utils.ConsoleOutput(fmt.Sprintf("an error ocurred while authorizing replication: %v", err))
+			exitCode = 1
+			return
+		}
+
+		if !recordStep(replicationStep, anfResources["Secondary"].VolumeID) {
+			return
+		}
+	}
+
+	utils.ConsoleOutput("Waiting for primary volume replication be ready...")
+	err := anfServices["Primary"].WaitForANFResource(cntx, anfResources["Primary"].VolumeID, 60, 50, true)
+	if err != nil {
+		utils.ConsoleOutput(fmt.Sprintf("an error ocurred while waiting for Primary volume be replication ready: %v", err))
+		exitCode = 1
+		return
+	}
+}
+
+// connectSides gets an authorizer and the subscription of each side, and creates the service used for the side's resources.
+// Sides with the same credential settings share the authorizer and the clients.
+func connectSides(environment azure.Environment) error {
+
+	type connection struct {
+		subscriptionID string
+		service        *sdkutils.Service
+	}
+	connections := map[iam.Settings]connection{}
+
+	for _, side := range []string{"Primary", "Secondary"} {
+		settings, err := getCredentialSettings(side)
+		if err != nil {
+			return err
+		}
+
+		if _, found := connections[settings]; !found {
+			authorizer, subscriptionID, err := iam.GetAuthorizer(environment, settings)
+			if err != nil {
+				return fmt.Errorf("%v side: %v", side, err)
+			}
+			connections[settings] = connection{
+				subscriptionID: subscriptionID,
+				service:        sdkutils.NewService(sdkutils.NewClientFactoryWithBaseURI(environment.ResourceManagerEndpoint, authorizer, subscriptionID)),
+			}
+		}
+
+		subscriptionIDs[side] = connections[settings].subscriptionID
+		anfServices[side] = connections[settings].service
+	}
+
+	if subscriptionIDs["Primary"] != subscriptionIDs["Secondary"] {
+		utils.ConsoleOutput(fmt.Sprintf("Cross-subscription replication, primary subscription: %v, secondary subscription: %v", subscriptionIDs["Primary"], subscriptionIDs["Secondary"]))
+	}
+
+	return nil
+}
+
+// getCredentialSettings returns the credential settings of the environment overridden by the side's subscription and credential
+func getCredentialSettings(side string) (iam.Settings, error) {
+
+	settings := iam.SettingsFromEnvironment()
+	overrides := iam.Settings{SubscriptionID: anfResources[side].SubscriptionID}
+
+	if credential := anfResources[side].Credential; credential != nil {
+		overrides.Credential = credential.Name
+		overrides.AuthLocation = credential.AuthLocation
+		overrides.TenantID = credential.TenantID
+		overrides.ClientID = credential.ClientID
+		overrides.ClientCertificatePath = credential.ClientCertificatePath
+		overrides.FederatedTokenFile = credential.FederatedTokenFile
+
+		if credential.ClientSecretVariable != "" {
+			overrides.ClientSecret = os.Getenv(credential.ClientSecretVariable)
+			if overrides.ClientSecret == "" {
+				return iam.Settings{}, fmt.Errorf("%v side: client secret environment variable %v is not set", side, credential.ClientSecretVariable)
+			}
+		}
+		if credential.ClientCertificatePasswordVariable != "" {
+			overrides.ClientCertificatePassword = os.Getenv(credential.ClientCertificatePasswordVariable)
+		}
+	}
+
+	return settings.Override(overrides), nil
+}
+
+// getSubnetID builds the subnet id of a side from topology names
+func getSubnetID(side string) string {
+	return uri.SubnetID(
+		subscriptionIDs[side],
+		anfResources[side].VnetResourceGroupName,
+		anfResources[side].VnetName,
+		anfResources[side].SubnetName,
+	).String()
+}
+
+// getDataProtectionObject builds the data protection object of a destination volume replicating from the remote side's volume
+func getDataProtectionObject(remoteSide string) (netapp.VolumePropertiesDataProtection, error) {
+	return sdkutils.GetReplicationDataProtectionObject(
+		anfResources[remoteSide].Location,
+		anfResources[remoteSide].VolumeID,
+		topology.ReplicationSchedule,
+	)
+}
+
+// getSnapshotPolicyProperties builds the snapshot policy properties of the topology schedules, unset schedules are not created
+func getSnapshotPolicyProperties(policy *models.SnapshotPolicy) netapp.SnapshotPolicyProperties {
+
+	policyProperties := netapp.SnapshotPolicyProperties{Enabled: to.BoolPtr(true)}
+
+	if policy.Hourly != nil {
+		policyProperties.HourlySchedule = &netapp.HourlySchedule{
+			SnapshotsToKeep: to.Int32Ptr(policy.Hourly.SnapshotsToKeep),
+			Minute:          to.Int32Ptr(policy.Hourly.Minute),
+		}
+	}
+	if policy.Daily != nil {
+		policyProperties.DailySchedule = &netapp.DailySchedule{
+			SnapshotsToKeep: to.Int32Ptr(policy.Daily.SnapshotsToKeep),
+			Hour:            to.Int32Ptr(policy.Daily.Hour),
+			Minute:          to.Int32Ptr(policy.Daily.Minute),
+		}
+	}
+	if policy.Weekly != nil {
+		policyProperties.WeeklySchedule = &netapp.WeeklySchedule{
+			SnapshotsToKeep: to.Int32Ptr(policy.Weekly.SnapshotsToKeep),
+			Day:             to.StringPtr(policy.Weekly.Day),
+			Hour:            to.Int32Ptr(policy.Weekly.Hour),
+			Minute:          to.Int32Ptr(policy.Weekly.Minute),
+		}
+	}
+	if policy.Monthly != nil {
+		policyProperties.MonthlySchedule = &netapp.MonthlySchedule{
+			SnapshotsToKeep: to.Int32Ptr(policy.Monthly.SnapshotsToKeep),
+			DaysOfMonth:     to.StringPtr(policy.Monthly.DaysOfMonth),
+			Hour:            to.Int32Ptr(policy.Monthly.Hour),
+			Minute:          to.Int32Ptr(policy.Monthly.Minute),
+		}
+	}
+
+	return policyProperties
+}
+
+// attachSnapshotPolicy attaches the snapshot policy of a side's account to the side's volume
+func attachSnapshotPolicy(cntx context.Context, side string) error {
+
+	utils.ConsoleOutput(fmt.Sprintf("Attaching snapshot policy %v to %v volume...", uri.GetAnfSnapshotPolicy(anfResources[side].SnapshotPolicyID), anfResources[side].VolumeName))
+	_, err := anfServices[side].SetAnfVolumeSnapshotPolicy(
+		cntx,
+		anfResources[side].Location,
+		anfResources[side].ResourceGroupName,
+		anfResources[side].AnfAccountName,
+		anfResources[side].CapacityPoolName,
+		anfResources[side].VolumeName,
+		anfResources[side].SnapshotPolicyID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return anfServices[side].WaitForANFResource(cntx, anfResources[side].VolumeID, 60, 50, false)
+}
+
+// stepName returns the name used to record a step of a side in state file
+func stepName(side, step string) string {
+	return fmt.Sprintf("%v/%v", side, step)
+}
+
+// recordStep records a completed step in state file, returns false if state could not be saved
+func recordStep(name, resourceID string) bool {
+	err := deploymentState.Complete(name, resourceID)
+	if err != nil {
+		utils.ConsoleOutput(fmt.Sprintf("an error ocurred while saving state for step %v: %v", name, err))
+		exitCode = 1
+		return false
+	}
+	return true
+}
+
+// hasSetupSteps checks if state file records steps of setup, the steps of an unfinished reverse are not taken into account
+func hasSetupSteps() bool {
+	for _, step := range deploymentState.Steps {
+		if _, found := utils.FindInSlice(reverseSteps, step.Name); !found {
+			return true
+		}
+	}
+	return false
+}
+
+func exit() {
+	utils.ConsoleOutput("Exiting")
+}
@@ -0,0 +1,98 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Failback, resumes a broken replication either in the original
+// direction (primary to secondary) or in the reverse direction
+// (secondary to primary) after the secondary volume took writes.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/sdkutils"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/utils"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/netapp/mgmt/netapp"
+)
+
+const (
+	originalDirection string = "original"
+	reverseDirection  string = "reverse"
+)
+
+// failback resyncs a broken replication in the requested direction and waits for it to be mirrored again
+func failback(cntx context.Context, args []string) {
+
+	flags := flag.NewFlagSet("failback", flag.ExitOnError)
+	direction := flags.String("direction", "", fmt.Sprintf("resync direction, %q resyncs primary to secondary, %q resyncs secondary to primary", originalDirection, reverseDirection))
+	flags.Parse(args)
+
+	resolveResourceIDs()
+
+	// Resync is executed on the destination volume for the original direction
+	// and on the source volume for the reverse direction
+	var side string
+	switch *direction {
+	case originalDirection:
+		side = "Secondary"
+	case reverseDirection:
+		side = "Primary"
+	default:
+		utils.ConsoleOutput(fmt.Sprintf("error: invalid direction %q, valid directions are: %v, %v", *direction, originalDirection, reverseDirection))
+		exitCode = 1
+		return
+	}
+
+	warnOverwrite(side)
+
+	err := resyncReplication(cntx, side)
+	if err != nil {
+		utils.ConsoleOutput(fmt.Sprintf("an error ocurred while resyncing replication: %v", err))
+		exitCode = 1
+		return
+	}
+
+	utils.ConsoleOutput(fmt.Sprintf("Failback completed, replication is mirrored in the %v direction", *direction))
+	if *direction == reverseDirection {
+		utils.ConsoleOutput("To return to the original direction, stop writes on the secondary volume, break the replication and run failback with the original direction")
+	}
+}
+
+// warnOverwrite warns about the data that will be overwritten when resyncing from a side's volume
+func warnOverwrite(side string) {
+	utils.ConsoleOutput(fmt.Sprintf("WARNING: resync overwrites %v volume %v, any data written to it after the replication was broken will be lost and replaced with the content of the %v volume",
+		side,
+		anfResources[side].VolumeID,
+		map[string]string{"Primary": "Secondary", "Secondary": "Primary"}[side],
+	))
+}
+
+// resyncReplication resyncs the replication on a side's volume and waits until it is mirrored again.
+// On the secondary (destination) volume it resyncs from primary to secondary, on the primary (source)
+// volume it reverse-resyncs from secondary to primary.
+func resyncReplication(cntx context.Context, side string) error {
+
+	utils.ConsoleOutput(fmt.Sprintf("Resyncing volume replication on %v volume...", anfResources[side].VolumeName))
+	err := anfServices[side].ResyncAnfVolumeReplication(
+		cntx,
+		anfResources[side].ResourceGroupName,
+		anfResources[side].AnfAccountName,
+		anfResources[side].CapacityPoolName,
+		anfResources[side].VolumeName,
+	)
+	if err != nil {
+		return err
+	}
+
+	utils.ConsoleOutput(fmt.Sprintf("Waiting for Mirrored state from %v volume...", anfResources[side].VolumeName))
+	_, err = waitForMirrorState(cntx, side, sdkutils.MirrorCondition{MirrorState: netapp.MirrorStateMirrored})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Planned failover, breaks the replication once the secondary volume
+// is fully transferred and promotes it to a writable volume.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/sdkutils"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/utils"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/netapp/mgmt/netapp"
+	"github.com/Azure/go-autorest/autorest/to"
+)
+
+// failover checks that secondary volume is mirrored and fully transferred, breaks the replication and reports the writable secondary volume
+func failover(cntx context.Context, args []string) {
+
+	flags := flag.NewFlagSet("failover", flag.ExitOnError)
+	skipSnapshot := flags.Bool("skip-snapshot", false, "skips the safety snapshot of the secondary volume")
+	flags.Parse(args)
+
+	resolveResourceIDs()
+
+	secondary := anfResources["Secondary"]
+
+	// Pre-checks, replication must be mirrored and with no transfer in progress
+	utils.ConsoleOutput(fmt.Sprintf("Waiting for Mirrored state with no transfer in progress from %v volume...", secondary.VolumeName))
+	replicationStatus, err := waitForMirrorState(cntx, "Secondary", sdkutils.MirrorCondition{
+		MirrorState:        netapp.MirrorStateMirrored,
+		RelationshipStatus: netapp.RelationshipStatusIdle,
+	})
+	if err != nil {
+		utils.ConsoleOutput(fmt.Sprintf("error: %v volume is not ready for failover: %v", secondary.VolumeName, err))
+		exitCode = 1
+		return
+	}
+	utils.ConsoleOutput(fmt.Sprintf("%v volume is mirrored and fully transferred, total progress: %v", secondary.VolumeName, to.String(replicationStatus.TotalProgress)))
+
+	// Rollback point to the last replicated data
+	if !*skipSnapshot {
+		err = takeSafetySnapshot(cntx, preFailoverSnapshotTag)
+		if err != nil {
+			utils.ConsoleOutput(fmt.Sprintf("an error ocurred while taking safety snapshot: %v", err))
+			exitCode = 1
+			return
+		}
+	}
+
+	// Break replication
+	err = breakReplication(cntx)
+	if err != nil {
+		utils.ConsoleOutput(fmt.Sprintf("an error ocurred while breaking replication volume: %v", err))
+		exitCode = 1
+		return
+	}
+
+	// Writable secondary volume takes its own local snapshots from now on
+	if topology.SnapshotPolicy != nil && topology.SnapshotPolicy.AttachToDestination {
+		err = attachSnapshotPolicy(cntx, "Secondary")
+		if err != nil {
+			utils.ConsoleOutput(fmt.Sprintf("an error ocurred while attaching snapshot policy to %v volume: %v", secondary.VolumeName, err))
+			exitCode = 1
+			return
+		}
+	}
+
+	// Promoted volume report
+	volume, err := anfServices["Secondary"].GetAnfVolume(
+		cntx,
+		secondary.ResourceGroupName,
+		secondary.AnfAccountName,
+		secondary.CapacityPoolName,
+		secondary.VolumeName,
+	)
+	if err != nil {
+		utils.ConsoleOutput(fmt.Sprintf("an error ocurred while getting %v volume: %v", secondary.VolumeName, err))
+		exitCode = 1
+		return
+	}
+
+	utils.ConsoleOutput("Failover completed, secondary volume is now writable")
+	printVolume(volume)
+}
+
+// printVolume prints volume id and its mount targets
+func printVolume(volume netapp.Volume) {
+
+	utils.ConsoleOutput(fmt.Sprintf("\tVolume: %v", to.String(volume.ID)))
+
+	if volume.VolumeProperties == nil || volume.MountTargets == nil || len(*volume.MountTargets) == 0 {
+		utils.ConsoleOutput("\tNo mount targets available")
+		return
+	}
+
+	for _, mountTarget := range *volume.MountTargets {
+		utils.ConsoleOutput(fmt.Sprintf("\tMount target: %v, mount path: %v:/%v",
+			to.String(mountTarget.IPAddress),
+			to.String(mountTarget.IPAddress),
+			to.String(volume.CreationToken),
+		))
+	}
+}
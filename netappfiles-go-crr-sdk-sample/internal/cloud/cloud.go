@@ -0,0 +1,101 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// This package selects the Azure cloud (public, sovereign, Azure Stack
+// or custom) whose endpoints are used to get tokens and to build
+// every ARM client of this sample.
+
+package cloud
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Azure/go-autorest/autorest/azure"
+)
+
+var (
+	// Short names accepted on top of the ones known by the SDK, e.g. AzureUSGovernment or AzureChinaCloud
+	aliases = map[string]string{
+		"azure":             azure.PublicCloud.Name,
+		"azurecloud":        azure.PublicCloud.Name,
+		"azurepublic":       azure.PublicCloud.Name,
+		"azureusgovernment": azure.USGovernmentCloud.Name,
+		"azurechina":        azure.ChinaCloud.Name,
+		"azuregerman":       azure.GermanCloud.Name,
+	}
+
+	knownEnvironments = []azure.Environment{azure.PublicCloud, azure.USGovernmentCloud, azure.ChinaCloud, azure.GermanCloud}
+)
+
+// Load returns the environment selected by an endpoints file or a name, the endpoints file takes precedence.
+// The name is a cloud name, e.g. AzurePublicCloud, AzureUSGovernment or AzureChina, or the https resource manager
+// endpoint of an Azure Stack instance whose metadata is read. An empty selection means the public cloud.
+func Load(name, endpointsFile string) (azure.Environment, error) {
+
+	if endpointsFile != "" {
+		environment, err := azure.EnvironmentFromFile(endpointsFile)
+		if err != nil {
+			return azure.Environment{}, fmt.Errorf("failed to read cloud endpoints file: %v", err)
+		}
+		if environment.ResourceManagerEndpoint == "" || environment.ActiveDirectoryEndpoint == "" {
+			return azure.Environment{}, fmt.Errorf("resourceManagerEndpoint and activeDirectoryEndpoint are required in cloud endpoints file %v", endpointsFile)
+		}
+		if environment.TokenAudience == "" {
+			environment.TokenAudience = environment.ResourceManagerEndpoint
+		}
+		return environment, nil
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return azure.PublicCloud, nil
+	}
+
+	if strings.HasPrefix(strings.ToLower(name), "https://") {
+		environment, err := azure.EnvironmentFromURL(name)
+		if err != nil {
+			return azure.Environment{}, fmt.Errorf("failed to read cloud metadata from %v: %v", name, err)
+		}
+		return environment, nil
+	}
+
+	if alias, found := aliases[strings.ToLower(name)]; found {
+		name = alias
+	}
+
+	environment, err := azure.EnvironmentFromName(name)
+	if err != nil {
+		return azure.Environment{}, fmt.Errorf("unknown cloud %v, valid clouds are AzurePublicCloud, AzureUSGovernment, AzureChina, AzureGerman or an Azure Stack resource manager endpoint", name)
+	}
+
+	return environment, nil
+}
+
+// FromEndpoints returns the known environment using a resource manager endpoint, or a custom environment
+// with the endpoints as provided, e.g. the ones of an authentication file pointing at a local fake ARM server
+func FromEndpoints(resourceManagerEndpoint, activeDirectoryEndpoint string) azure.Environment {
+
+	for _, environment := range knownEnvironments {
+		if sameEndpoint(environment.ResourceManagerEndpoint, resourceManagerEndpoint) {
+			return environment
+		}
+	}
+
+	environment := azure.PublicCloud
+	environment.Name = "Custom"
+	environment.ResourceManagerEndpoint = resourceManagerEndpoint
+	environment.TokenAudience = resourceManagerEndpoint
+	if activeDirectoryEndpoint != "" {
+		environment.ActiveDirectoryEndpoint = activeDirectoryEndpoint
+	}
+
+	return environment
+}
+
+// sameEndpoint compares endpoints regardless of case and trailing slash
+func sameEndpoint(a, b string) bool {
+	return strings.EqualFold(strings.TrimSuffix(a, "/"), strings.TrimSuffix(b, "/"))
+}
@@ -0,0 +1,222 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package cloud
+
+import (
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest/azure"
+)
+
+// writeEndpointsFile writes a cloud endpoints file
+func writeEndpointsFile(t *testing.T, content string) string {
+
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "cloud.json")
+	err := ioutil.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		t.Fatalf("cannot write endpoints file: %v", err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+
+	endpointsFile := writeEndpointsFile(t, `{
+		"name": "Lab",
+		"resourceManagerEndpoint": "https://management.lab.example.com/",
+		"activeDirectoryEndpoint": "https://login.lab.example.com/"
+	}`)
+	incompleteEndpointsFile := writeEndpointsFile(t, `{"name": "Lab", "resourceManagerEndpoint": "https://management.lab.example.com/"}`)
+
+	tests := []struct {
+		name                        string
+		cloudName                   string
+		endpointsFile               string
+		wantName                    string
+		wantResourceManagerEndpoint string
+		wantErr                     string
+	}{
+		{name: "default", wantName: azure.PublicCloud.Name},
+		{name: "sdk name", cloudName: "AzureUSGovernmentCloud", wantName: azure.USGovernmentCloud.Name},
+		{name: "alias", cloudName: "AzureChina", wantName: azure.ChinaCloud.Name},
+		{name: "alias ignores case and spaces", cloudName: " AZUREUSGOVERNMENT ", wantName: azure.USGovernmentCloud.Name},
+		{name: "unknown", cloudName: "AzureMars", wantErr: "unknown cloud AzureMars"},
+		{
+			name:                        "endpoints file",
+			endpointsFile:               endpointsFile,
+			wantName:                    "Lab",
+			wantResourceManagerEndpoint: "https://management.lab.example.com/",
+		},
+		{
+			name:                        "endpoints file before name",
+			cloudName:                   "AzureChina",
+			endpointsFile:               endpointsFile,
+			wantName:                    "Lab",
+			wantResourceManagerEndpoint: "https://management.lab.example.com/",
+		},
+		{name: "incomplete endpoints file", endpointsFile: incompleteEndpointsFile, wantErr: "resourceManagerEndpoint and activeDirectoryEndpoint are required"},
+		{name: "missing endpoints file", endpointsFile: filepath.Join(t.TempDir(), "missing.json"), wantErr: "failed to read cloud endpoints file"},
+		{name: "unreachable azure stack", cloudName: "https://127.0.0.1:1", wantErr: "failed to read cloud metadata from https://127.0.0.1:1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			environment, err := Load(test.cloudName, test.endpointsFile)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("Load() returned error %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() returned error: %v", err)
+			}
+			if environment.Name != test.wantName {
+				t.Errorf("cloud is %v, want %v", environment.Name, test.wantName)
+			}
+			if test.wantResourceManagerEndpoint != "" && environment.ResourceManagerEndpoint != test.wantResourceManagerEndpoint {
+				t.Errorf("resource manager endpoint is %v, want %v", environment.ResourceManagerEndpoint, test.wantResourceManagerEndpoint)
+			}
+		})
+	}
+}
+
+func TestLoadEndpointsFileTokenAudience(t *testing.T) {
+
+	// Token audience defaults to the resource manager endpoint, as Azure Stack expects
+	environment, err := Load("", writeEndpointsFile(t, `{
+		"resourceManagerEndpoint": "https://management.lab.example.com/",
+		"activeDirectoryEndpoint": "https://login.lab.example.com/"
+	}`))
+	if err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+	if environment.TokenAudience != "https://management.lab.example.com/" {
+		t.Errorf("token audience is %v, want the resource manager endpoint", environment.TokenAudience)
+	}
+
+	environment, err = Load("", writeEndpointsFile(t, `{
+		"resourceManagerEndpoint": "https://management.lab.example.com/",
+		"activeDirectoryEndpoint": "https://login.lab.example.com/",
+		"tokenAudience": "https://management.lab.example.com/audience"
+	}`))
+	if err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+	if environment.TokenAudience != "https://management.lab.example.com/audience" {
+		t.Errorf("token audience is %v, want the one of the file", environment.TokenAudience)
+	}
+}
+
+func TestLoadAzureStack(t *testing.T) {
+
+	// The SDK reads the metadata with its own client, the test server certificate is trusted through SSL_CERT_FILE
+	if runtime.GOOS != "linux" {
+		t.Skip("SSL_CERT_FILE is only honored on linux")
+	}
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metadata/endpoints" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{
+			"galleryEndpoint": "https://gallery.local.azurestack.external/",
+			"graphEndpoint": "https://graph.local.azurestack.external/",
+			"authentication": {
+				"loginEndpoint": "https://login.local.azurestack.external/adfs",
+				"audiences": ["https://management.adfs.local.azurestack.external/"]
+			}
+		}`)
+	}))
+	defer server.Close()
+
+	certificateFile := filepath.Join(t.TempDir(), "server.pem")
+	certificate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := ioutil.WriteFile(certificateFile, certificate, 0600); err != nil {
+		t.Fatalf("cannot write certificate: %v", err)
+	}
+	t.Setenv("SSL_CERT_FILE", certificateFile)
+
+	environment, err := Load(server.URL, "")
+	if err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+	if environment.ResourceManagerEndpoint != server.URL {
+		t.Errorf("resource manager endpoint is %v, want %v", environment.ResourceManagerEndpoint, server.URL)
+	}
+	if environment.ActiveDirectoryEndpoint != "https://login.local.azurestack.external/adfs" {
+		t.Errorf("active directory endpoint is %v, want the login endpoint of the metadata", environment.ActiveDirectoryEndpoint)
+	}
+	if environment.TokenAudience != "https://management.adfs.local.azurestack.external/" {
+		t.Errorf("token audience is %v, want the audience of the metadata", environment.TokenAudience)
+	}
+}
+
+func TestFromEndpoints(t *testing.T) {
+
+	tests := []struct {
+		name                        string
+		resourceManagerEndpoint     string
+		activeDirectoryEndpoint     string
+		wantName                    string
+		wantActiveDirectoryEndpoint string
+	}{
+		{
+			name:                        "known cloud",
+			resourceManagerEndpoint:     "https://management.usgovcloudapi.net/",
+			wantName:                    azure.USGovernmentCloud.Name,
+			wantActiveDirectoryEndpoint: azure.USGovernmentCloud.ActiveDirectoryEndpoint,
+		},
+		{
+			name:                        "known cloud ignores case and trailing slash",
+			resourceManagerEndpoint:     "https://Management.ChinaCloudAPI.cn",
+			activeDirectoryEndpoint:     "https://login.example.com/",
+			wantName:                    azure.ChinaCloud.Name,
+			wantActiveDirectoryEndpoint: azure.ChinaCloud.ActiveDirectoryEndpoint,
+		},
+		{
+			name:                        "custom",
+			resourceManagerEndpoint:     "http://127.0.0.1:8080/",
+			activeDirectoryEndpoint:     "http://127.0.0.1:8080/aad/",
+			wantName:                    "Custom",
+			wantActiveDirectoryEndpoint: "http://127.0.0.1:8080/aad/",
+		},
+		{
+			name:                        "custom without active directory endpoint",
+			resourceManagerEndpoint:     "http://127.0.0.1:8080/",
+			wantName:                    "Custom",
+			wantActiveDirectoryEndpoint: azure.PublicCloud.ActiveDirectoryEndpoint,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			environment := FromEndpoints(test.resourceManagerEndpoint, test.activeDirectoryEndpoint)
+			if environment.Name != test.wantName {
+				t.Errorf("cloud is %v, want %v", environment.Name, test.wantName)
+			}
+			if environment.ActiveDirectoryEndpoint != test.wantActiveDirectoryEndpoint {
+				t.Errorf("active directory endpoint is %v, want %v", environment.ActiveDirectoryEndpoint, test.wantActiveDirectoryEndpoint)
+			}
+			if test.wantName == "Custom" && (environment.ResourceManagerEndpoint != test.resourceManagerEndpoint || environment.TokenAudience != test.resourceManagerEndpoint) {
+				t.Errorf("custom cloud endpoint is %v, audience %v, want %v", environment.ResourceManagerEndpoint, environment.TokenAudience, test.resourceManagerEndpoint)
+			}
+		})
+	}
+}
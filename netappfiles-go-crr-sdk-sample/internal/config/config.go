@@ -0,0 +1,586 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// This package loads the primary/secondary topology used by this
+// sample from a YAML or JSON file and validates it before any
+// Azure operation takes place.
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/models"
+
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/netapp/mgmt/netapp"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultReplicationSchedule string = "hourly"
+
+	// Maximum number of snapshots of a volume
+	maxSnapshotsPerVolume int32 = 255
+)
+
+var (
+	// Same service levels accepted by sdkutils when creating pools and volumes
+	validServiceLevels = []netapp.ServiceLevel{netapp.ServiceLevelStandard, netapp.ServiceLevelPremium, netapp.ServiceLevelUltra}
+)
+
+// LoadTopology reads a topology file and unmarshals it, file format is chosen based on file extension (.json, .yaml or .yml).
+func LoadTopology(path string) (*models.Topology, error) {
+
+	if len(strings.TrimSpace(path)) == 0 {
+		return nil, fmt.Errorf("topology file location not provided")
+	}
+
+	topologyFile, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read topology file: %v", err)
+	}
+
+	var topology models.Topology
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(topologyFile))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&topology)
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(topologyFile))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&topology)
+	default:
+		return nil, fmt.Errorf("unsupported topology file extension %q, supported extensions are: .json, .yaml, .yml", filepath.Ext(path))
+	}
+
+	if err == io.EOF {
+		return nil, fmt.Errorf("topology file %v is empty", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid topology file %v: %v", path, err)
+	}
+
+	if topology.ReplicationSchedule == "" {
+		topology.ReplicationSchedule = defaultReplicationSchedule
+	}
+
+	err = ValidateTopology(&topology)
+	if err != nil {
+		return nil, fmt.Errorf("invalid topology file %v: %v", path, err)
+	}
+
+	return &topology, nil
+}
+
+// SwapTopologyRoles swaps primary and secondary sections of a topology file in place, comments and key order are kept
+func SwapTopologyRoles(path string) error {
+	return editTopology(path, swapRoles)
+}
+
+// CheckTopologyRoles checks that primary and secondary sections of a topology file can be swapped, without changing the file
+func CheckTopologyRoles(path string) error {
+
+	fields, err := readTopologyFields(path)
+	if err != nil {
+		return err
+	}
+
+	err = swapRoles(fields)
+	if err != nil {
+		return fmt.Errorf("cannot edit topology file %v: %v", path, err)
+	}
+
+	_, err = fields.marshal()
+	if err != nil {
+		return fmt.Errorf("failed to marshal topology: %v", err)
+	}
+
+	return nil
+}
+
+func swapRoles(fields topologyFields) error {
+	return fields.swap("primary", "secondary")
+}
+
+// SetReplicationSchedule sets the replication schedule of a topology file in place, the rest of the file is kept as is
+func SetReplicationSchedule(path, replicationSchedule string) error {
+	return editTopology(path, func(fields topologyFields) error {
+		fields.set("replicationSchedule", replicationSchedule)
+		return nil
+	})
+}
+
+// topologyFields are the top level fields of a topology file, edits only change the fields they target
+// so the rest of the file, including comments and key order, is written back as the user left it
+type topologyFields interface {
+	// swap exchanges the values of two fields
+	swap(key1, key2 string) error
+	// set sets a string field, it is added after the existing fields when missing
+	set(key, value string)
+	// marshal returns the edited file content
+	marshal() ([]byte, error)
+}
+
+// readTopologyFields reads the top level fields of a topology file,
+// file format is chosen based on file extension (.json, .yaml or .yml).
+func readTopologyFields(path string) (topologyFields, error) {
+
+	topologyFile, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read topology file: %v", err)
+	}
+
+	var fields topologyFields
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		fields, err = parseJSONFields(topologyFile)
+	case ".yaml", ".yml":
+		fields, err = parseYAMLFields(topologyFile)
+	default:
+		return nil, fmt.Errorf("unsupported topology file extension %q, supported extensions are: .json, .yaml, .yml", filepath.Ext(path))
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid topology file %v: %v", path, err)
+	}
+
+	return fields, nil
+}
+
+// editTopology reads a topology file, edits its top level fields and writes it back
+func editTopology(path string, edit func(fields topologyFields) error) error {
+
+	fields, err := readTopologyFields(path)
+	if err != nil {
+		return err
+	}
+
+	err = edit(fields)
+	if err != nil {
+		return fmt.Errorf("cannot edit topology file %v: %v", path, err)
+	}
+
+	content, err := fields.marshal()
+	if err != nil {
+		return fmt.Errorf("failed to marshal topology: %v", err)
+	}
+
+	err = ioutil.WriteFile(path, content, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write topology file: %v", err)
+	}
+
+	return nil
+}
+
+// yamlFields are the top level fields of a YAML topology file, kept as a node tree along with their comments
+type yamlFields struct {
+	document yaml.Node
+}
+
+func parseYAMLFields(content []byte) (*yamlFields, error) {
+
+	fields := &yamlFields{}
+
+	err := yaml.Unmarshal(content, &fields.document)
+	if err != nil {
+		return nil, err
+	}
+	if fields.document.Kind != yaml.DocumentNode || len(fields.document.Content) != 1 || fields.document.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("top level is not a mapping")
+	}
+
+	return fields, nil
+}
+
+// index returns the position of the value of a field in the top level mapping, -1 if missing
+func (f *yamlFields) index(key string) int {
+	mapping := f.document.Content[0]
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return i + 1
+		}
+	}
+	return -1
+}
+
+func (f *yamlFields) swap(key1, key2 string) error {
+
+	index1, index2 := f.index(key1), f.index(key2)
+	if index1 < 0 || index2 < 0 {
+		return fmt.Errorf("%v and %v fields are required", key1, key2)
+	}
+
+	mapping := f.document.Content[0]
+	mapping.Content[index1], mapping.Content[index2] = mapping.Content[index2], mapping.Content[index1]
+
+	return nil
+}
+
+func (f *yamlFields) set(key, value string) {
+
+	if index := f.index(key); index >= 0 {
+		node := f.document.Content[0].Content[index]
+		node.Kind, node.Tag, node.Value, node.Content = yaml.ScalarNode, "!!str", value, nil
+		return
+	}
+
+	mapping := f.document.Content[0]
+	mapping.Content = append(mapping.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value},
+	)
+}
+
+func (f *yamlFields) marshal() ([]byte, error) {
+
+	var content bytes.Buffer
+
+	encoder := yaml.NewEncoder(&content)
+	encoder.SetIndent(2)
+	err := encoder.Encode(&f.document)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return content.Bytes(), nil
+}
+
+// jsonFields are the top level fields of a JSON topology file in file order, values are kept as written
+type jsonFields struct {
+	keys   []string
+	values []json.RawMessage
+}
+
+func parseJSONFields(content []byte) (*jsonFields, error) {
+
+	decoder := json.NewDecoder(bytes.NewReader(content))
+
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return nil, fmt.Errorf("top level is not an object")
+	}
+
+	fields := &jsonFields{}
+	for decoder.More() {
+		token, err = decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		var value json.RawMessage
+		err = decoder.Decode(&value)
+		if err != nil {
+			return nil, err
+		}
+		fields.keys = append(fields.keys, token.(string))
+		fields.values = append(fields.values, value)
+	}
+
+	return fields, nil
+}
+
+// index returns the position of a field, -1 if missing. Keys are matched
+// case-insensitively, the same way LoadTopology decodes them.
+func (f *jsonFields) index(key string) int {
+	for i, k := range f.keys {
+		if strings.EqualFold(k, key) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f *jsonFields) swap(key1, key2 string) error {
+
+	index1, index2 := f.index(key1), f.index(key2)
+	if index1 < 0 || index2 < 0 {
+		return fmt.Errorf("%v and %v fields are required", key1, key2)
+	}
+
+	f.values[index1], f.values[index2] = f.values[index2], f.values[index1]
+
+	return nil
+}
+
+func (f *jsonFields) set(key, value string) {
+
+	// Marshaling a string cannot fail
+	raw, _ := json.Marshal(value)
+
+	if index := f.index(key); index >= 0 {
+		f.values[index] = raw
+		return
+	}
+
+	f.keys = append(f.keys, key)
+	f.values = append(f.values, raw)
+}
+
+func (f *jsonFields) marshal() ([]byte, error) {
+
+	var object bytes.Buffer
+
+	object.WriteString("{")
+	for i, key := range f.keys {
+		if i > 0 {
+			object.WriteString(",")
+		}
+		name, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		object.Write(name)
+		object.WriteString(":")
+		object.Write(f.values[i])
+	}
+	object.WriteString("}")
+
+	var content bytes.Buffer
+	err := json.Indent(&content, object.Bytes(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	content.WriteString("\n")
+
+	return content.Bytes(), nil
+}
+
+// ValidateTopology checks that both sides of the topology are present and that all required properties are set
+func ValidateTopology(topology *models.Topology) error {
+
+	var problems []string
+
+	sides := []struct {
+		name       string
+		properties *models.Properties
+	}{
+		{"primary", topology.Primary},
+		{"secondary", topology.Secondary},
+	}
+
+	for _, side := range sides {
+		if side.properties == nil {
+			problems = append(problems, fmt.Sprintf("%v: section is missing", side.name))
+			continue
+		}
+
+		for _, field := range missingFields(side.properties) {
+			problems = append(problems, fmt.Sprintf("%v.%v: required field is missing", side.name, field))
+		}
+
+		if side.properties.ServiceLevel != "" && !isValidServiceLevel(side.properties.ServiceLevel) {
+			problems = append(problems, fmt.Sprintf("%v.serviceLevel: invalid value %q, supported service levels are: %v", side.name, side.properties.ServiceLevel, validServiceLevels))
+		}
+	}
+
+	if topology.ReplicationSchedule != "" && !IsValidReplicationSchedule(topology.ReplicationSchedule) {
+		problems = append(problems, fmt.Sprintf("replicationSchedule: invalid value %q, supported schedules are: %v", topology.ReplicationSchedule, validReplicationSchedules()))
+	}
+
+	if topology.RPOThreshold != "" {
+		threshold, err := time.ParseDuration(topology.RPOThreshold)
+		if err != nil || threshold <= 0 {
+			problems = append(problems, fmt.Sprintf("rpoThreshold: invalid value %q, it must be a positive duration like 90m or 2h", topology.RPOThreshold))
+		}
+	}
+
+	if topology.SnapshotPolicy != nil {
+		problems = append(problems, snapshotPolicyProblems(topology.SnapshotPolicy)...)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%v", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// missingFields returns the names of the required properties that are empty
+func missingFields(properties *models.Properties) []string {
+
+	required := []struct {
+		name  string
+		value string
+	}{
+		{"location", properties.Location},
+		{"resourceGroupName", properties.ResourceGroupName},
+		{"vnetResourceGroupName", properties.VnetResourceGroupName},
+		{"vnetName", properties.VnetName},
+		{"subnetName", properties.SubnetName},
+		{"anfAccountName", properties.AnfAccountName},
+		{"capacityPoolName", properties.CapacityPoolName},
+		{"volumeName", properties.VolumeName},
+		{"serviceLevel", properties.ServiceLevel},
+	}
+
+	var missing []string
+	for _, field := range required {
+		if len(strings.TrimSpace(field.value)) == 0 {
+			missing = append(missing, field.name)
+		}
+	}
+
+	return missing
+}
+
+// snapshotPolicyProblems returns the problems of a snapshot policy, it needs a name and at least one schedule with values in range
+func snapshotPolicyProblems(policy *models.SnapshotPolicy) []string {
+
+	var problems []string
+
+	if len(strings.TrimSpace(policy.Name)) == 0 {
+		problems = append(problems, "snapshotPolicy.name: required field is missing")
+	}
+
+	schedules := []struct {
+		name     string
+		schedule *models.SnapshotSchedule
+	}{
+		{"hourly", policy.Hourly},
+		{"daily", policy.Daily},
+		{"weekly", policy.Weekly},
+		{"monthly", policy.Monthly},
+	}
+
+	var snapshotsToKeep int32
+	for _, s := range schedules {
+		if s.schedule == nil {
+			continue
+		}
+		field := fmt.Sprintf("snapshotPolicy.%v", s.name)
+		snapshotsToKeep += s.schedule.SnapshotsToKeep
+
+		if s.schedule.SnapshotsToKeep < 1 {
+			problems = append(problems, fmt.Sprintf("%v.snapshotsToKeep: it must be at least 1", field))
+		}
+		if s.schedule.Minute < 0 || s.schedule.Minute > 59 {
+			problems = append(problems, fmt.Sprintf("%v.minute: invalid value %d, it must be between 0 and 59", field, s.schedule.Minute))
+		}
+		if s.schedule.Hour < 0 || s.schedule.Hour > 23 {
+			problems = append(problems, fmt.Sprintf("%v.hour: invalid value %d, it must be between 0 and 23", field, s.schedule.Hour))
+		}
+		if s.name == "hourly" && s.schedule.Hour != 0 {
+			problems = append(problems, fmt.Sprintf("%v.hour: not used by hourly schedules", field))
+		}
+		if s.name == "weekly" && !isValidWeekDays(s.schedule.Day) {
+			problems = append(problems, fmt.Sprintf("%v.day: invalid value %q, it must be comma separated week days, e.g. Monday,Friday", field, s.schedule.Day))
+		}
+		if s.name != "weekly" && s.schedule.Day != "" {
+			problems = append(problems, fmt.Sprintf("%v.day: only used by weekly schedules", field))
+		}
+		if s.name == "monthly" && !isValidDaysOfMonth(s.schedule.DaysOfMonth) {
+			problems = append(problems, fmt.Sprintf("%v.daysOfMonth: invalid value %q, it must be comma separated days between 1 and 31, e.g. 1,15", field, s.schedule.DaysOfMonth))
+		}
+		if s.name != "monthly" && s.schedule.DaysOfMonth != "" {
+			problems = append(problems, fmt.Sprintf("%v.daysOfMonth: only used by monthly schedules", field))
+		}
+	}
+
+	if policy.Hourly == nil && policy.Daily == nil && policy.Weekly == nil && policy.Monthly == nil {
+		problems = append(problems, "snapshotPolicy: at least one of hourly, daily, weekly or monthly schedules is required")
+	}
+	if snapshotsToKeep > maxSnapshotsPerVolume {
+		problems = append(problems, fmt.Sprintf("snapshotPolicy: schedules keep %d snapshots, a volume can have at most %d snapshots", snapshotsToKeep, maxSnapshotsPerVolume))
+	}
+
+	return problems
+}
+
+// isValidWeekDays checks a comma separated list of week day names
+func isValidWeekDays(days string) bool {
+	if strings.TrimSpace(days) == "" {
+		return false
+	}
+	for _, day := range strings.Split(days, ",") {
+		found := false
+		for weekDay := time.Sunday; weekDay <= time.Saturday; weekDay++ {
+			if strings.EqualFold(strings.TrimSpace(day), weekDay.String()) {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// isValidDaysOfMonth checks a comma separated list of days of the month
+func isValidDaysOfMonth(days string) bool {
+	if strings.TrimSpace(days) == "" {
+		return false
+	}
+	for _, day := range strings.Split(days, ",") {
+		dayOfMonth, err := strconv.Atoi(strings.TrimSpace(day))
+		if err != nil || dayOfMonth < 1 || dayOfMonth > 31 {
+			return false
+		}
+	}
+	return true
+}
+
+// isValidServiceLevel checks service level against the values supported by this sample
+func isValidServiceLevel(serviceLevel string) bool {
+	for _, svcLevel := range validServiceLevels {
+		if strings.EqualFold(string(svcLevel), serviceLevel) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidReplicationSchedule checks replication schedule against the values supported by the SDK
+func IsValidReplicationSchedule(replicationSchedule string) bool {
+	for _, schedule := range validReplicationSchedules() {
+		if strings.EqualFold(schedule, replicationSchedule) {
+			return true
+		}
+	}
+	return false
+}
+
+// validReplicationSchedules returns SDK replication schedules without the leading underscore used by 10minutely
+func validReplicationSchedules() []string {
+	var schedules []string
+	for _, schedule := range netapp.PossibleReplicationScheduleValues() {
+		schedules = append(schedules, strings.TrimPrefix(string(schedule), "_"))
+	}
+	return schedules
+}
+
+// GetRPOThreshold returns the replication lag threshold of a topology, defaults to twice the replication schedule interval
+func GetRPOThreshold(topology *models.Topology) time.Duration {
+
+	if threshold, err := time.ParseDuration(topology.RPOThreshold); err == nil && threshold > 0 {
+		return threshold
+	}
+
+	switch strings.ToLower(strings.TrimPrefix(topology.ReplicationSchedule, "_")) {
+	case "10minutely":
+		return 2 * 10 * time.Minute
+	case "daily":
+		return 2 * 24 * time.Hour
+	default:
+		return 2 * time.Hour
+	}
+}
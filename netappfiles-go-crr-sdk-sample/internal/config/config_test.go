@@ -0,0 +1,468 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package config
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/models"
+)
+
+const testYAMLTopology = `# Topology comment
+replicationSchedule: hourly # schedule comment
+rpoThreshold: 2h
+primary:
+  # primary side comment
+  location: westus
+  resourceGroupName: anf-primary-rg
+  vnetResourceGroupName: anf-primary-rg
+  vnetName: westus-primary-vnet
+  subnetName: anf-primary-sn
+  anfAccountName: PrimaryANFAccount
+  capacityPoolName: PrimaryPool
+  volumeName: PrimaryVolume
+  serviceLevel: Premium
+secondary:
+  location: eastus
+  resourceGroupName: anf-secondary-rg
+  vnetResourceGroupName: anf-secondary-rg
+  vnetName: eastus-secondary-vnet
+  subnetName: anf-secondary-sn
+  anfAccountName: SecondaryANFAccount
+  capacityPoolName: SecondaryPool
+  volumeName: SecondaryVolume
+  serviceLevel: Standard
+`
+
+const testJSONTopology = `{
+  "rpoThreshold": "2h",
+  "secondary": {
+    "volumeName": "SecondaryVolume",
+    "location": "eastus",
+    "resourceGroupName": "anf-secondary-rg",
+    "vnetResourceGroupName": "anf-secondary-rg",
+    "vnetName": "eastus-secondary-vnet",
+    "subnetName": "anf-secondary-sn",
+    "anfAccountName": "SecondaryANFAccount",
+    "capacityPoolName": "SecondaryPool",
+    "serviceLevel": "Standard"
+  },
+  "primary": {
+    "volumeName": "PrimaryVolume",
+    "location": "westus",
+    "resourceGroupName": "anf-primary-rg",
+    "vnetResourceGroupName": "anf-primary-rg",
+    "vnetName": "westus-primary-vnet",
+    "subnetName": "anf-primary-sn",
+    "anfAccountName": "PrimaryANFAccount",
+    "capacityPoolName": "PrimaryPool",
+    "serviceLevel": "Premium"
+  }
+}
+`
+
+// writeTopology writes a topology file in a temporary directory and returns its path
+func writeTopology(t *testing.T, name, content string) string {
+
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	err := ioutil.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("cannot write topology: %v", err)
+	}
+
+	return path
+}
+
+// readTopology returns the content of a topology file
+func readTopology(t *testing.T, path string) string {
+
+	t.Helper()
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("cannot read topology: %v", err)
+	}
+
+	return string(content)
+}
+
+// assertInOrder checks that parts appear in content in the given order
+func assertInOrder(t *testing.T, content string, parts ...string) {
+
+	t.Helper()
+
+	position := 0
+	for _, part := range parts {
+		index := strings.Index(content[position:], part)
+		if index < 0 {
+			t.Fatalf("%q missing or out of order in:\n%v", part, content)
+		}
+		position += index + len(part)
+	}
+}
+
+func TestSwapTopologyRoles(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		content string
+		order   []string
+	}{
+		{
+			name:    "topology.yaml",
+			content: testYAMLTopology,
+			order: []string{
+				"# Topology comment",
+				"replicationSchedule: hourly # schedule comment",
+				"rpoThreshold: 2h",
+				"primary:", "location: eastus",
+				"secondary:", "# primary side comment", "location: westus",
+			},
+		},
+		{
+			name:    "topology.json",
+			content: testJSONTopology,
+			order: []string{
+				`"rpoThreshold": "2h"`,
+				`"secondary": {`, `"volumeName": "PrimaryVolume"`, `"location": "westus"`,
+				`"primary": {`, `"volumeName": "SecondaryVolume"`, `"location": "eastus"`,
+			},
+		},
+		{
+			// JSON keys are decoded case-insensitively, they are matched the same way
+			name:    "capitalized.json",
+			content: strings.NewReplacer(`"secondary"`, `"Secondary"`, `"primary"`, `"Primary"`).Replace(testJSONTopology),
+			order: []string{
+				`"Secondary": {`, `"volumeName": "PrimaryVolume"`,
+				`"Primary": {`, `"volumeName": "SecondaryVolume"`,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			path := writeTopology(t, test.name, test.content)
+
+			err := SwapTopologyRoles(path)
+			if err != nil {
+				t.Fatalf("cannot swap roles: %v", err)
+			}
+			assertInOrder(t, readTopology(t, path), test.order...)
+
+			topology, err := LoadTopology(path)
+			if err != nil {
+				t.Fatalf("cannot load swapped topology: %v", err)
+			}
+			if topology.Primary.VolumeName != "SecondaryVolume" || topology.Secondary.VolumeName != "PrimaryVolume" {
+				t.Errorf("swapped topology has primary %v and secondary %v", topology.Primary.VolumeName, topology.Secondary.VolumeName)
+			}
+
+			// Swapping back gives the original roles
+			err = SwapTopologyRoles(path)
+			if err != nil {
+				t.Fatalf("cannot swap roles back: %v", err)
+			}
+			topology, err = LoadTopology(path)
+			if err != nil {
+				t.Fatalf("cannot load topology: %v", err)
+			}
+			if topology.Primary.VolumeName != "PrimaryVolume" {
+				t.Errorf("topology swapped twice has primary %v", topology.Primary.VolumeName)
+			}
+		})
+	}
+}
+
+func TestSwapTopologyRolesMissingSide(t *testing.T) {
+
+	path := writeTopology(t, "topology.yaml", "replicationSchedule: hourly\nprimary:\n  location: westus\n")
+
+	err := SwapTopologyRoles(path)
+	if err == nil {
+		t.Fatalf("swapping roles of a topology without secondary side succeeded")
+	}
+	if content := readTopology(t, path); content != "replicationSchedule: hourly\nprimary:\n  location: westus\n" {
+		t.Errorf("failed swap changed topology file:\n%v", content)
+	}
+}
+
+func TestCheckTopologyRoles(t *testing.T) {
+
+	path := writeTopology(t, "topology.yaml", testYAMLTopology)
+	err := CheckTopologyRoles(path)
+	if err != nil {
+		t.Fatalf("cannot check roles: %v", err)
+	}
+	if content := readTopology(t, path); content != testYAMLTopology {
+		t.Errorf("checking roles changed topology file:\n%v", content)
+	}
+
+	path = writeTopology(t, "topology.json", `{"primary": {"location": "westus"}}`)
+	err = CheckTopologyRoles(path)
+	if err == nil {
+		t.Errorf("checking roles of a topology without secondary side succeeded")
+	}
+}
+
+func TestSetReplicationSchedule(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "topology.yaml",
+			content: testYAMLTopology,
+			want:    strings.Replace(testYAMLTopology, "replicationSchedule: hourly", "replicationSchedule: 10minutely", 1),
+		},
+		{
+			name:    "topology.yml",
+			content: strings.Replace(testYAMLTopology, "replicationSchedule: hourly # schedule comment\n", "", 1),
+			want:    strings.Replace(testYAMLTopology, "replicationSchedule: hourly # schedule comment\n", "", 1) + "replicationSchedule: 10minutely\n",
+		},
+		{
+			name:    "topology.json",
+			content: testJSONTopology,
+			want:    strings.Replace(testJSONTopology, "\n}\n", ",\n  \"replicationSchedule\": \"10minutely\"\n}\n", 1),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			path := writeTopology(t, test.name, test.content)
+
+			err := SetReplicationSchedule(path, "10minutely")
+			if err != nil {
+				t.Fatalf("cannot set replication schedule: %v", err)
+			}
+			if content := readTopology(t, path); content != test.want {
+				t.Fatalf("topology file is:\n%v\nwant:\n%v", content, test.want)
+			}
+
+			topology, err := LoadTopology(path)
+			if err != nil {
+				t.Fatalf("cannot load topology: %v", err)
+			}
+			if topology.ReplicationSchedule != "10minutely" {
+				t.Errorf("replication schedule is %v, want 10minutely", topology.ReplicationSchedule)
+			}
+		})
+	}
+}
+
+func TestLoadTopology(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr []string
+	}{
+		{
+			name:    "topology.yaml",
+			content: testYAMLTopology,
+		},
+		{
+			name:    "topology.json",
+			content: testJSONTopology,
+		},
+		{
+			name:    "unknown.yaml",
+			content: strings.Replace(testYAMLTopology, "  serviceLevel: Premium\n", "  serviceLevel: Premium\n  volumeSize: 100\n", 1),
+			wantErr: []string{"field volumeSize not found"},
+		},
+		{
+			name:    "unknown.json",
+			content: strings.Replace(testJSONTopology, `"rpoThreshold": "2h",`, `"rpoThreshold": "2h", "region": "westus",`, 1),
+			wantErr: []string{`unknown field "region"`},
+		},
+		{
+			name:    "missing.yaml",
+			content: strings.NewReplacer("  vnetName: westus-primary-vnet\n", "", "  volumeName: SecondaryVolume\n", "").Replace(testYAMLTopology),
+			wantErr: []string{"primary.vnetName: required field is missing", "secondary.volumeName: required field is missing"},
+		},
+		{
+			name:    "policy.yaml",
+			content: testYAMLTopology + "snapshotPolicy:\n  name: daily\n  daily:\n    snapshotsToKeep: 5\n    hour: 2\n  attachToDestination: true\n",
+		},
+		{
+			name:    "invalidpolicy.yaml",
+			content: testYAMLTopology + "snapshotPolicy:\n  name: daily\n  daily:\n    snapshotsToKeep: 5\n    hour: 24\n",
+			wantErr: []string{"snapshotPolicy.daily.hour: invalid value 24"},
+		},
+		{
+			name:    "side.yaml",
+			content: testYAMLTopology[:strings.Index(testYAMLTopology, "secondary:")],
+			wantErr: []string{"secondary: section is missing"},
+		},
+		{
+			name:    "invalid.yaml",
+			content: strings.NewReplacer("serviceLevel: Premium", "serviceLevel: Gold", "replicationSchedule: hourly", "replicationSchedule: weekly", "rpoThreshold: 2h", "rpoThreshold: -1h").Replace(testYAMLTopology),
+			wantErr: []string{`primary.serviceLevel: invalid value "Gold"`, `replicationSchedule: invalid value "weekly"`, `rpoThreshold: invalid value "-1h"`},
+		},
+		{
+			name:    "empty.yaml",
+			content: "",
+			wantErr: []string{"is empty"},
+		},
+		{
+			name:    "topology.txt",
+			content: testYAMLTopology,
+			wantErr: []string{`unsupported topology file extension ".txt"`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			topology, err := LoadTopology(writeTopology(t, test.name, test.content))
+			if len(test.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("cannot load topology: %v", err)
+				}
+				if topology.Primary.VolumeName != "PrimaryVolume" || topology.Secondary.ServiceLevel != "Standard" {
+					t.Errorf("loaded topology has primary volume %v and secondary service level %v", topology.Primary.VolumeName, topology.Secondary.ServiceLevel)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("loading topology succeeded, want errors %v", test.wantErr)
+			}
+			for _, want := range test.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not report %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadTopologyDefaultSchedule(t *testing.T) {
+
+	path := writeTopology(t, "topology.json", testJSONTopology)
+
+	topology, err := LoadTopology(path)
+	if err != nil {
+		t.Fatalf("cannot load topology: %v", err)
+	}
+	if topology.ReplicationSchedule != defaultReplicationSchedule {
+		t.Errorf("replication schedule is %v, want %v", topology.ReplicationSchedule, defaultReplicationSchedule)
+	}
+}
+
+func TestLoadTopologyWithoutLocation(t *testing.T) {
+
+	_, err := LoadTopology(" ")
+	if err == nil || !strings.Contains(err.Error(), "location not provided") {
+		t.Errorf("loading a topology without location returned %v", err)
+	}
+}
+
+func TestSnapshotPolicyProblems(t *testing.T) {
+
+	schedule := func(snapshotsToKeep, minute, hour int32) *models.SnapshotSchedule {
+		return &models.SnapshotSchedule{SnapshotsToKeep: snapshotsToKeep, Minute: minute, Hour: hour}
+	}
+
+	tests := []struct {
+		name   string
+		policy models.SnapshotPolicy
+		want   []string
+	}{
+		{
+			name: "valid",
+			policy: models.SnapshotPolicy{
+				Name:    "policy",
+				Hourly:  schedule(24, 5, 0),
+				Daily:   schedule(7, 30, 2),
+				Weekly:  &models.SnapshotSchedule{SnapshotsToKeep: 4, Day: "Monday, friday", Hour: 3},
+				Monthly: &models.SnapshotSchedule{SnapshotsToKeep: 12, DaysOfMonth: "1,15, 31", Hour: 4},
+			},
+		},
+		{
+			name:   "no name and no schedule",
+			policy: models.SnapshotPolicy{Name: " "},
+			want: []string{
+				"snapshotPolicy.name: required field is missing",
+				"snapshotPolicy: at least one of hourly, daily, weekly or monthly schedules is required",
+			},
+		},
+		{
+			name:   "out of range",
+			policy: models.SnapshotPolicy{Name: "policy", Daily: schedule(0, 60, -1)},
+			want: []string{
+				"snapshotPolicy.daily.snapshotsToKeep: it must be at least 1",
+				"snapshotPolicy.daily.minute: invalid value 60, it must be between 0 and 59",
+				"snapshotPolicy.daily.hour: invalid value -1, it must be between 0 and 23",
+			},
+		},
+		{
+			name:   "hour of hourly schedule",
+			policy: models.SnapshotPolicy{Name: "policy", Hourly: schedule(1, 0, 2)},
+			want:   []string{"snapshotPolicy.hourly.hour: not used by hourly schedules"},
+		},
+		{
+			name: "days of the wrong schedules",
+			policy: models.SnapshotPolicy{
+				Name:   "policy",
+				Daily:  &models.SnapshotSchedule{SnapshotsToKeep: 1, Day: "Monday"},
+				Weekly: &models.SnapshotSchedule{SnapshotsToKeep: 1, Day: "Monday", DaysOfMonth: "1"},
+			},
+			want: []string{
+				"snapshotPolicy.daily.day: only used by weekly schedules",
+				"snapshotPolicy.weekly.daysOfMonth: only used by monthly schedules",
+			},
+		},
+		{
+			name: "invalid days",
+			policy: models.SnapshotPolicy{
+				Name:    "policy",
+				Weekly:  &models.SnapshotSchedule{SnapshotsToKeep: 1, Day: "Monday,Funday"},
+				Monthly: &models.SnapshotSchedule{SnapshotsToKeep: 1, DaysOfMonth: "1,32"},
+			},
+			want: []string{
+				`snapshotPolicy.weekly.day: invalid value "Monday,Funday"`,
+				`snapshotPolicy.monthly.daysOfMonth: invalid value "1,32"`,
+			},
+		},
+		{
+			name:   "missing days",
+			policy: models.SnapshotPolicy{Name: "policy", Weekly: schedule(1, 0, 0), Monthly: schedule(1, 0, 0)},
+			want: []string{
+				`snapshotPolicy.weekly.day: invalid value ""`,
+				`snapshotPolicy.monthly.daysOfMonth: invalid value ""`,
+			},
+		},
+		{
+			name:   "too many snapshots",
+			policy: models.SnapshotPolicy{Name: "policy", Hourly: schedule(200, 0, 0), Daily: schedule(56, 0, 0)},
+			want:   []string{"snapshotPolicy: schedules keep 256 snapshots, a volume can have at most 255 snapshots"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			problems := snapshotPolicyProblems(&test.policy)
+			if len(problems) != len(test.want) {
+				t.Fatalf("problems are %q, want %q", problems, test.want)
+			}
+			for i, want := range test.want {
+				if !strings.HasPrefix(problems[i], want) {
+					t.Errorf("problem %q, want %q", problems[i], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,895 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package fake
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/netapp/mgmt/netapp"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/resources"
+	"github.com/Azure/go-autorest/autorest/date"
+	"github.com/Azure/go-autorest/autorest/to"
+)
+
+type resourcesAPI struct {
+	b *Backend
+}
+
+func (a resourcesAPI) Get(ctx context.Context, resourceGroupName, resourceProviderNamespace, parentResourcePath, resourceType, resourceName, APIVersion string) (resources.GenericResource, error) {
+
+	id := fmt.Sprintf("/subscriptions/%v/resourceGroups/%v/providers/%v/%v/%v", a.b.subscriptionID, resourceGroupName, resourceProviderNamespace, resourceType, resourceName)
+	if parentResourcePath != "" {
+		id = fmt.Sprintf("/subscriptions/%v/resourceGroups/%v/providers/%v/%v/%v/%v", a.b.subscriptionID, resourceGroupName, resourceProviderNamespace, parentResourcePath, resourceType, resourceName)
+	}
+
+	a.b.mu.Lock()
+	defer a.b.mu.Unlock()
+
+	if !a.b.resources[strings.ToLower(id)] {
+		return resources.GenericResource{}, notFound("ResourcesGet", id)
+	}
+
+	return resources.GenericResource{ID: to.StringPtr(id), Name: to.StringPtr(resourceName)}, nil
+}
+
+type accountsAPI struct {
+	b *Backend
+}
+
+func (a accountsAPI) CreateOrUpdate(ctx context.Context, body netapp.Account, resourceGroupName, accountName string) (netapp.Account, error) {
+
+	a.b.mu.Lock()
+	defer a.b.mu.Unlock()
+
+	id := a.b.accountID(resourceGroupName, accountName)
+	if body.AccountProperties == nil {
+		body.AccountProperties = &netapp.AccountProperties{}
+	}
+	body.ID = to.StringPtr(id)
+	body.Name = to.StringPtr(accountName)
+	body.ProvisioningState = to.StringPtr(provisioningStateSucceeded)
+	a.b.accounts[strings.ToLower(id)] = body
+
+	return body, nil
+}
+
+func (a accountsAPI) Get(ctx context.Context, resourceGroupName, accountName string) (netapp.Account, error) {
+
+	a.b.mu.Lock()
+	defer a.b.mu.Unlock()
+
+	id := a.b.accountID(resourceGroupName, accountName)
+	account, found := a.b.accounts[strings.ToLower(id)]
+	if !found {
+		return netapp.Account{}, notFound("AccountsGet", id)
+	}
+	if provisioningState, ok := a.b.nextProvisioningState(id); ok {
+		properties := *account.AccountProperties
+		properties.ProvisioningState = provisioningState
+		account.AccountProperties = &properties
+	}
+
+	return account, nil
+}
+
+func (a accountsAPI) Delete(ctx context.Context, resourceGroupName, accountName string) error {
+
+	a.b.mu.Lock()
+	defer a.b.mu.Unlock()
+
+	id := a.b.accountID(resourceGroupName, accountName)
+	if _, found := a.b.accounts[strings.ToLower(id)]; !found {
+		return notFound("AccountsDelete", id)
+	}
+
+	var poolIDs []string
+	for poolID := range a.b.pools {
+		poolIDs = append(poolIDs, poolID)
+	}
+	if hasChildren(id, poolIDs) {
+		return conflict("AccountsDelete", fmt.Sprintf("account %v still has capacity pools", accountName))
+	}
+
+	var policyIDs []string
+	for policyID := range a.b.snapshotPolicies {
+		policyIDs = append(policyIDs, policyID)
+	}
+	for policyID := range a.b.backupPolicies {
+		policyIDs = append(policyIDs, policyID)
+	}
+	if hasChildren(id, policyIDs) {
+		return conflict("AccountsDelete", fmt.Sprintf("account %v still has snapshot or backup policies", accountName))
+	}
+
+	delete(a.b.accounts, strings.ToLower(id))
+
+	return nil
+}
+
+type poolsAPI struct {
+	b *Backend
+}
+
+func (a poolsAPI) CreateOrUpdate(ctx context.Context, body netapp.CapacityPool, resourceGroupName, accountName, poolName string) (netapp.CapacityPool, error) {
+
+	a.b.mu.Lock()
+	defer a.b.mu.Unlock()
+
+	accountID := a.b.accountID(resourceGroupName, accountName)
+	if _, found := a.b.accounts[strings.ToLower(accountID)]; !found {
+		return netapp.CapacityPool{}, notFound("PoolsCreateOrUpdate", accountID)
+	}
+
+	id := a.b.poolID(resourceGroupName, accountName, poolName)
+	if body.PoolProperties == nil {
+		body.PoolProperties = &netapp.PoolProperties{}
+	}
+	body.ID = to.StringPtr(id)
+	body.Name = to.StringPtr(fmt.Sprintf("%v/%v", accountName, poolName))
+	body.ProvisioningState = to.StringPtr(provisioningStateSucceeded)
+	a.b.pools[strings.ToLower(id)] = body
+
+	return body, nil
+}
+
+func (a poolsAPI) Get(ctx context.Context, resourceGroupName, accountName, poolName string) (netapp.CapacityPool, error) {
+
+	a.b.mu.Lock()
+	defer a.b.mu.Unlock()
+
+	id := a.b.poolID(resourceGroupName, accountName, poolName)
+	pool, found := a.b.pools[strings.ToLower(id)]
+	if !found {
+		return netapp.CapacityPool{}, notFound("PoolsGet", id)
+	}
+	if provisioningState, ok := a.b.nextProvisioningState(id); ok {
+		properties := *pool.PoolProperties
+		properties.ProvisioningState = provisioningState
+		pool.PoolProperties = &properties
+	}
+
+	return pool, nil
+}
+
+func (a poolsAPI) Delete(ctx context.Context, resourceGroupName, accountName, poolName string) error {
+
+	a.b.mu.Lock()
+	defer a.b.mu.Unlock()
+
+	id := a.b.poolID(resourceGroupName, accountName, poolName)
+	if _, found := a.b.pools[strings.ToLower(id)]; !found {
+		return notFound("PoolsDelete", id)
+	}
+
+	var volumeIDs []string
+	for volumeID := range a.b.volumes {
+		volumeIDs = append(volumeIDs, volumeID)
+	}
+	if hasChildren(id, volumeIDs) {
+		return conflict("PoolsDelete", fmt.Sprintf("capacity pool %v still has volumes", poolName))
+	}
+
+	delete(a.b.pools, strings.ToLower(id))
+
+	return nil
+}
+
+type volumesAPI struct {
+	b *Backend
+}
+
+func (a volumesAPI) CreateOrUpdate(ctx context.Context, body netapp.Volume, resourceGroupName, accountName, poolName, volumeName string) (netapp.Volume, error) {
+
+	a.b.mu.Lock()
+	defer a.b.mu.Unlock()
+
+	poolID := a.b.poolID(resourceGroupName, accountName, poolName)
+	if _, found := a.b.pools[strings.ToLower(poolID)]; !found {
+		return netapp.Volume{}, notFound("VolumesCreateOrUpdate", poolID)
+	}
+
+	id := a.b.volumeID(resourceGroupName, accountName, poolName, volumeName)
+	if body.VolumeProperties == nil {
+		body.VolumeProperties = &netapp.VolumeProperties{}
+	}
+	if dataProtection := body.DataProtection; dataProtection != nil && dataProtection.Snapshot != nil {
+		snapshotPolicyID := to.String(dataProtection.Snapshot.SnapshotPolicyID)
+		if _, found := a.b.snapshotPolicies[strings.ToLower(snapshotPolicyID)]; snapshotPolicyID != "" && !found {
+			return netapp.Volume{}, notFound("VolumesCreateOrUpdate", snapshotPolicyID)
+		}
+	}
+	// Volumes created from a snapshot must reference an existing snapshot of the same account
+	if snapshotID := to.String(body.SnapshotID); snapshotID != "" && !a.b.snapshotExists(a.b.accountID(resourceGroupName, accountName), snapshotID) {
+		return netapp.Volume{}, notFound("VolumesCreateOrUpdate", snapshotID)
+	}
+	body.ID = to.StringPtr(id)
+	body.Name = to.StringPtr(fmt.Sprintf("%v/%v/%v", accountName, poolName, volumeName))
+	body.ProvisioningState = to.StringPtr(provisioningStateSucceeded)
+	body.MountTargets = &[]netapp.MountTargetProperties{
+		{IPAddress: to.StringPtr(fmt.Sprintf("10.0.0.%d", len(a.b.volumes)+4))},
+	}
+	a.b.volumes[strings.ToLower(id)] = body
+
+	// Destination volumes start an uninitialized replication that waits for the source volume authorization
+	dataProtection := body.DataProtection
+	if dataProtection != nil && dataProtection.Replication != nil && dataProtection.Replication.EndpointType == netapp.EndpointTypeDst {
+		if a.b.findReplication(id) == nil {
+			a.b.replications[strings.ToLower(id)] = &replication{
+				sourceID:           to.String(dataProtection.Replication.RemoteVolumeResourceID),
+				destinationID:      id,
+				mirrorState:        netapp.MirrorStateUninitialized,
+				relationshipStatus: netapp.RelationshipStatusIdle,
+			}
+		}
+	}
+
+	return body, nil
+}
+
+func (a volumesAPI) Get(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string) (netapp.Volume, error) {
+
+	a.b.mu.Lock()
+	defer a.b.mu.Unlock()
+
+	id := a.b.volumeID(resourceGroupName, accountName, poolName, volumeName)
+	volume, found := a.b.volumes[strings.ToLower(id)]
+	if !found {
+		return netapp.Volume{}, notFound("VolumesGet", id)
+	}
+	if provisioningState, ok := a.b.nextProvisioningState(id); ok {
+		properties := *volume.VolumeProperties
+		properties.ProvisioningState = provisioningState
+		volume.VolumeProperties = &properties
+	}
+
+	return volume, nil
+}
+
+func (a volumesAPI) Update(ctx context.Context, body netapp.VolumePatch, resourceGroupName, accountName, poolName, volumeName string) (netapp.Volume, error) {
+
+	a.b.mu.Lock()
+	defer a.b.mu.Unlock()
+
+	id := a.b.volumeID(resourceGroupName, accountName, poolName, volumeName)
+	volume, found := a.b.volumes[strings.ToLower(id)]
+	if !found {
+		return netapp.Volume{}, notFound("VolumesUpdate", id)
+	}
+
+	if body.Tags != nil {
+		volume.Tags = body.Tags
+	}
+	if body.VolumePatchProperties != nil && body.UsageThreshold != nil {
+		volume.UsageThreshold = body.UsageThreshold
+	}
+	if body.VolumePatchProperties != nil && body.DataProtection != nil {
+		dataProtection := netapp.VolumePropertiesDataProtection{}
+		if volume.DataProtection != nil {
+			dataProtection = *volume.DataProtection
+		}
+		if body.DataProtection.Snapshot != nil {
+			dataProtection.Snapshot = body.DataProtection.Snapshot
+		}
+		if body.DataProtection.Backup != nil {
+			dataProtection.Backup = body.DataProtection.Backup
+		}
+		volume.DataProtection = &dataProtection
+	}
+	a.b.volumes[strings.ToLower(id)] = volume
+
+	return volume, nil
+}
+
+func (a volumesAPI) Delete(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string) error {
+
+	a.b.mu.Lock()
+	defer a.b.mu.Unlock()
+
+	id := a.b.volumeID(resourceGroupName, accountName, poolName, volumeName)
+	if _, found := a.b.volumes[strings.ToLower(id)]; !found {
+		return notFound("VolumesDelete", id)
+	}
+
+	if r := a.b.findReplication(id); r != nil && r.authorized {
+		return conflict("VolumesDelete", fmt.Sprintf("volume %v has a replication, delete the replication first", volumeName))
+	}
+	delete(a.b.replications, strings.ToLower(id))
+
+	for snapshotID := range a.b.snapshots {
+		if strings.HasPrefix(snapshotID, strings.ToLower(id)+"/") {
+			delete(a.b.snapshots, snapshotID)
+		}
+	}
+	for backupID := range a.b.backups {
+		if strings.HasPrefix(backupID, strings.ToLower(id)+"/") {
+			delete(a.b.backups, backupID)
+		}
+	}
+	delete(a.b.volumes, strings.ToLower(id))
+
+	return nil
+}
+
+func (a volumesAPI) AuthorizeReplication(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string, body netapp.AuthorizeRequest) error {
+
+	a.b.mu.Lock()
+	defer a.b.mu.Unlock()
+
+	id := a.b.volumeID(resourceGroupName, accountName, poolName, volumeName)
+	if _, found := a.b.volumes[strings.ToLower(id)]; !found {
+		return notFound("VolumesAuthorizeReplication", id)
+	}
+
+	r, found := a.b.replications[strings.ToLower(to.String(body.RemoteVolumeResourceID))]
+	if !found || !strings.EqualFold(r.sourceID, id) {
+		return newError("VolumesAuthorizeReplication", http.StatusBadRequest, "VolumeReplicationMissing", fmt.Sprintf("volume %v is not the source of a replication to %v", volumeName, to.String(body.RemoteVolumeResourceID)))
+	}
+
+	if !r.authorized {
+		r.authorized = true
+		a.b.startTransfer(r)
+	}
+
+	return nil
+}
+
+func (a volumesAPI) BreakReplication(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string, body *netapp.BreakReplicationRequest) error {
+
+	a.b.mu.Lock()
+	defer a.b.mu.Unlock()
+
+	r, err := a.destinationReplication("VolumesBreakReplication", resourceGroupName, accountName, poolName, volumeName)
+	if err != nil {
+		return err
+	}
+
+	if r.mirrorState != netapp.MirrorStateMirrored {
+		return conflict("VolumesBreakReplication", fmt.Sprintf("replication of volume %v is %v, only mirrored replications can be broken", volumeName, r.mirrorState))
+	}
+	if r.relationshipStatus == netapp.RelationshipStatusTransferring && (body == nil || !to.Bool(body.ForceBreakReplication)) {
+		return conflict("VolumesBreakReplication", fmt.Sprintf("replication of volume %v is transferring", volumeName))
+	}
+
+	r.mirrorState = netapp.MirrorStateBroken
+	r.relationshipStatus = netapp.RelationshipStatusIdle
+	r.pendingSteps = 0
+
+	return nil
+}
+
+func (a volumesAPI) ResyncReplication(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string) error {
+
+	a.b.mu.Lock()
+	defer a.b.mu.Unlock()
+
+	// Resync can be executed on the destination volume or, to reverse-resync, on the source volume
+	id := a.b.volumeID(resourceGroupName, accountName, poolName, volumeName)
+	r := a.b.findReplication(id)
+	if r == nil {
+		return newError("VolumesResyncReplication", http.StatusBadRequest, "VolumeReplicationMissing", fmt.Sprintf("volume %v has no replication", volumeName))
+	}
+
+	if r.mirrorState != netapp.MirrorStateBroken {
+		return conflict("VolumesResyncReplication", fmt.Sprintf("replication of volume %v is %v, only broken replications can be resynced", volumeName, r.mirrorState))
+	}
+
+	a.b.startTransfer(r)
+
+	return nil
+}
+
+func (a volumesAPI) DeleteReplication(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string) error {
+
+	a.b.mu.Lock()
+	defer a.b.mu.Unlock()
+
+	r, err := a.destinationReplication("VolumesDeleteReplication", resourceGroupName, accountName, poolName, volumeName)
+	if err != nil {
+		return err
+	}
+
+	if r.mirrorState == netapp.MirrorStateMirrored {
+		return conflict("VolumesDeleteReplication", fmt.Sprintf("replication of volume %v must be broken before deleting it", volumeName))
+	}
+
+	delete(a.b.replications, strings.ToLower(r.destinationID))
+	if volume, found := a.b.volumes[strings.ToLower(r.destinationID)]; found && volume.DataProtection != nil {
+		dataProtection := *volume.DataProtection
+		dataProtection.Replication = nil
+		volume.DataProtection = &dataProtection
+		a.b.volumes[strings.ToLower(r.destinationID)] = volume
+	}
+
+	return nil
+}
+
+func (a volumesAPI) ReplicationStatus(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string) (netapp.ReplicationStatus, error) {
+
+	a.b.mu.Lock()
+	defer a.b.mu.Unlock()
+
+	id := a.b.volumeID(resourceGroupName, accountName, poolName, volumeName)
+	if _, found := a.b.volumes[strings.ToLower(id)]; !found {
+		return netapp.ReplicationStatus{}, notFound("VolumesReplicationStatusMethod", id)
+	}
+
+	r := a.b.findReplication(id)
+	if r == nil {
+		return netapp.ReplicationStatus{}, newError("VolumesReplicationStatusMethod", http.StatusBadRequest, "VolumeReplicationMissing", fmt.Sprintf("volume %v has no replication", volumeName))
+	}
+
+	a.b.advance(r)
+
+	return netapp.ReplicationStatus{
+		Healthy:            to.BoolPtr(r.mirrorState != netapp.MirrorStateUninitialized || r.relationshipStatus == netapp.RelationshipStatusTransferring),
+		MirrorState:        r.mirrorState,
+		RelationshipStatus: r.relationshipStatus,
+		TotalProgress:      to.StringPtr(r.totalProgress()),
+	}, nil
+}
+
+// destinationReplication returns the replication of a destination volume
+func (a volumesAPI) Revert(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string, body netapp.VolumeRevert) error {
+
+	a.b.mu.Lock()
+	defer a.b.mu.Unlock()
+
+	id := a.b.volumeID(resourceGroupName, accountName, poolName, volumeName)
+	if _, found := a.b.volumes[strings.ToLower(id)]; !found {
+		return notFound("VolumesRevert", id)
+	}
+
+	// Destination volumes are read-only until their replication is broken
+	if r, found := a.b.replications[strings.ToLower(id)]; found && r.mirrorState != netapp.MirrorStateBroken {
+		return conflict("VolumesRevert", fmt.Sprintf("volume %v is a replication destination in %v state, only broken destinations can be reverted", volumeName, r.mirrorState))
+	}
+
+	var reverted *netapp.Snapshot
+	for snapshotID, snapshot := range a.b.snapshots {
+		if strings.HasPrefix(snapshotID, strings.ToLower(id)+"/") && snapshot.SnapshotProperties != nil && strings.EqualFold(to.String(snapshot.SnapshotID), to.String(body.SnapshotID)) {
+			reverted = &snapshot
+			break
+		}
+	}
+	if reverted == nil {
+		return notFound("VolumesRevert", to.String(body.SnapshotID))
+	}
+
+	// Snapshots newer than the reverted one are lost
+	for snapshotID, snapshot := range a.b.snapshots {
+		if strings.HasPrefix(snapshotID, strings.ToLower(id)+"/") && snapshot.Created.After(reverted.Created.Time) {
+			delete(a.b.snapshots, snapshotID)
+		}
+	}
+
+	return nil
+}
+
+func (a volumesAPI) destinationReplication(method, resourceGroupName, accountName, poolName, volumeName string) (*replication, error) {
+
+	id := a.b.volumeID(resourceGroupName, accountName, poolName, volumeName)
+	if _, found := a.b.volumes[strings.ToLower(id)]; !found {
+		return nil, notFound(method, id)
+	}
+
+	r, found := a.b.replications[strings.ToLower(id)]
+	if !found {
+		return nil, newError(method, 400, "VolumeReplicationMissing", fmt.Sprintf("volume %v is not a replication destination volume", volumeName))
+	}
+
+	return r, nil
+}
+
+type snapshotsAPI struct {
+	b *Backend
+}
+
+func (a snapshotsAPI) Create(ctx context.Context, body netapp.Snapshot, resourceGroupName, accountName, poolName, volumeName, snapshotName string) (netapp.Snapshot, error) {
+
+	a.b.mu.Lock()
+	defer a.b.mu.Unlock()
+
+	volumeID := a.b.volumeID(resourceGroupName, accountName, poolName, volumeName)
+	if _, found := a.b.volumes[strings.ToLower(volumeID)]; !found {
+		return netapp.Snapshot{}, notFound("SnapshotsCreate", volumeID)
+	}
+
+	id := a.b.snapshotID(resourceGroupName, accountName, poolName, volumeName, snapshotName)
+	snapshot := newSnapshot(id)
+	snapshot.Location = body.Location
+	a.b.snapshots[strings.ToLower(id)] = snapshot
+
+	return snapshot, nil
+}
+
+func (a snapshotsAPI) Get(ctx context.Context, resourceGroupName, accountName, poolName, volumeName, snapshotName string) (netapp.Snapshot, error) {
+
+	a.b.mu.Lock()
+	defer a.b.mu.Unlock()
+
+	id := a.b.snapshotID(resourceGroupName, accountName, poolName, volumeName, snapshotName)
+	snapshot, found := a.b.snapshots[strings.ToLower(id)]
+	if !found {
+		return netapp.Snapshot{}, notFound("SnapshotsGet", id)
+	}
+	if provisioningState, ok := a.b.nextProvisioningState(id); ok {
+		properties := *snapshot.SnapshotProperties
+		properties.ProvisioningState = provisioningState
+		snapshot.SnapshotProperties = &properties
+	}
+
+	return snapshot, nil
+}
+
+func (a snapshotsAPI) List(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string) ([]netapp.Snapshot, error) {
+
+	a.b.mu.Lock()
+	defer a.b.mu.Unlock()
+
+	volumeID := a.b.volumeID(resourceGroupName, accountName, poolName, volumeName)
+	if _, found := a.b.volumes[strings.ToLower(volumeID)]; !found {
+		return nil, notFound("SnapshotsList", volumeID)
+	}
+
+	snapshots := []netapp.Snapshot{}
+	for id, snapshot := range a.b.snapshots {
+		if strings.HasPrefix(id, strings.ToLower(volumeID)+"/") {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+
+	return snapshots, nil
+}
+
+func (a snapshotsAPI) Delete(ctx context.Context, resourceGroupName, accountName, poolName, volumeName, snapshotName string) error {
+
+	a.b.mu.Lock()
+	defer a.b.mu.Unlock()
+
+	id := a.b.snapshotID(resourceGroupName, accountName, poolName, volumeName, snapshotName)
+	if _, found := a.b.snapshots[strings.ToLower(id)]; !found {
+		return notFound("SnapshotsDelete", id)
+	}
+
+	delete(a.b.snapshots, strings.ToLower(id))
+
+	return nil
+}
+
+type snapshotPoliciesAPI struct {
+	b *Backend
+}
+
+func (a snapshotPoliciesAPI) Create(ctx context.Context, body netapp.SnapshotPolicy, resourceGroupName, accountName, snapshotPolicyName string) (netapp.SnapshotPolicy, error) {
+
+	a.b.mu.Lock()
+	defer a.b.mu.Unlock()
+
+	accountID := a.b.accountID(resourceGroupName, accountName)
+	if _, found := a.b.accounts[strings.ToLower(accountID)]; !found {
+		return netapp.SnapshotPolicy{}, notFound("SnapshotPoliciesCreate", accountID)
+	}
+
+	id := a.b.snapshotPolicyID(resourceGroupName, accountName, snapshotPolicyName)
+	if body.SnapshotPolicyProperties == nil {
+		body.SnapshotPolicyProperties = &netapp.SnapshotPolicyProperties{}
+	}
+	body.ID = to.StringPtr(id)
+	body.Name = to.StringPtr(fmt.Sprintf("%v/%v", accountName, snapshotPolicyName))
+	body.ProvisioningState = to.StringPtr(provisioningStateSucceeded)
+	a.b.snapshotPolicies[strings.ToLower(id)] = body
+
+	return body, nil
+}
+
+func (a snapshotPoliciesAPI) Get(ctx context.Context, resourceGroupName, accountName, snapshotPolicyName string) (netapp.SnapshotPolicy, error) {
+
+	a.b.mu.Lock()
+	defer a.b.mu.Unlock()
+
+	id := a.b.snapshotPolicyID(resourceGroupName, accountName, snapshotPolicyName)
+	snapshotPolicy, found := a.b.snapshotPolicies[strings.ToLower(id)]
+	if !found {
+		return netapp.SnapshotPolicy{}, notFound("SnapshotPoliciesGet", id)
+	}
+
+	return snapshotPolicy, nil
+}
+
+func (a snapshotPoliciesAPI) List(ctx context.Context, resourceGroupName, accountName string) ([]netapp.SnapshotPolicy, error) {
+
+	a.b.mu.Lock()
+	defer a.b.mu.Unlock()
+
+	accountID := a.b.accountID(resourceGroupName, accountName)
+	if _, found := a.b.accounts[strings.ToLower(accountID)]; !found {
+		return nil, notFound("SnapshotPoliciesList", accountID)
+	}
+
+	snapshotPolicies := []netapp.SnapshotPolicy{}
+	for id, snapshotPolicy := range a.b.snapshotPolicies {
+		if strings.HasPrefix(id, strings.ToLower(accountID)+"/") {
+			snapshotPolicies = append(snapshotPolicies, snapshotPolicy)
+		}
+	}
+
+	return snapshotPolicies, nil
+}
+
+func (a snapshotPoliciesAPI) Update(ctx context.Context, body netapp.SnapshotPolicyPatch, resourceGroupName, accountName, snapshotPolicyName string) (netapp.SnapshotPolicy, error) {
+
+	a.b.mu.Lock()
+	defer a.b.mu.Unlock()
+
+	id := a.b.snapshotPolicyID(resourceGroupName, accountName, snapshotPolicyName)
+	snapshotPolicy, found := a.b.snapshotPolicies[strings.ToLower(id)]
+	if !found {
+		return netapp.SnapshotPolicy{}, notFound("SnapshotPoliciesUpdate", id)
+	}
+
+	if body.Tags != nil {
+		snapshotPolicy.Tags = body.Tags
+	}
+	if patch := body.SnapshotPolicyProperties; patch != nil {
+		properties := *snapshotPolicy.SnapshotPolicyProperties
+		if patch.HourlySchedule != nil {
+			properties.HourlySchedule = patch.HourlySchedule
+		}
+		if patch.DailySchedule != nil {
+			properties.DailySchedule = patch.DailySchedule
+		}
+		if patch.WeeklySchedule != nil {
+			properties.WeeklySchedule = patch.WeeklySchedule
+		}
+		if patch.MonthlySchedule != nil {
+			properties.MonthlySchedule = patch.MonthlySchedule
+		}
+		if patch.Enabled != nil {
+			properties.Enabled = patch.Enabled
+		}
+		snapshotPolicy.SnapshotPolicyProperties = &properties
+	}
+	a.b.snapshotPolicies[strings.ToLower(id)] = snapshotPolicy
+
+	return snapshotPolicy, nil
+}
+
+func (a snapshotPoliciesAPI) Delete(ctx context.Context, resourceGroupName, accountName, snapshotPolicyName string) error {
+
+	a.b.mu.Lock()
+	defer a.b.mu.Unlock()
+
+	id := a.b.snapshotPolicyID(resourceGroupName, accountName, snapshotPolicyName)
+	if _, found := a.b.snapshotPolicies[strings.ToLower(id)]; !found {
+		return notFound("SnapshotPoliciesDelete", id)
+	}
+
+	if a.b.policyAssigned(id) {
+		return conflict("SnapshotPoliciesDelete", fmt.Sprintf("snapshot policy %v is still assigned to volumes", snapshotPolicyName))
+	}
+
+	delete(a.b.snapshotPolicies, strings.ToLower(id))
+
+	return nil
+}
+
+type backupPoliciesAPI struct {
+	b *Backend
+}
+
+func (a backupPoliciesAPI) Create(ctx context.Context, body netapp.BackupPolicy, resourceGroupName, accountName, backupPolicyName string) (netapp.BackupPolicy, error) {
+
+	a.b.mu.Lock()
+	defer a.b.mu.Unlock()
+
+	accountID := a.b.accountID(resourceGroupName, accountName)
+	if _, found := a.b.accounts[strings.ToLower(accountID)]; !found {
+		return netapp.BackupPolicy{}, notFound("BackupPoliciesCreate", accountID)
+	}
+
+	id := a.b.backupPolicyID(resourceGroupName, accountName, backupPolicyName)
+	if body.BackupPolicyProperties == nil {
+		body.BackupPolicyProperties = &netapp.BackupPolicyProperties{}
+	}
+	body.ID = to.StringPtr(id)
+	body.Name = to.StringPtr(fmt.Sprintf("%v/%v", accountName, backupPolicyName))
+	body.ProvisioningState = to.StringPtr(provisioningStateSucceeded)
+	a.b.backupPolicies[strings.ToLower(id)] = body
+
+	return body, nil
+}
+
+func (a backupPoliciesAPI) Get(ctx context.Context, resourceGroupName, accountName, backupPolicyName string) (netapp.BackupPolicy, error) {
+
+	a.b.mu.Lock()
+	defer a.b.mu.Unlock()
+
+	id := a.b.backupPolicyID(resourceGroupName, accountName, backupPolicyName)
+	backupPolicy, found := a.b.backupPolicies[strings.ToLower(id)]
+	if !found {
+		return netapp.BackupPolicy{}, notFound("BackupPoliciesGet", id)
+	}
+
+	return backupPolicy, nil
+}
+
+func (a backupPoliciesAPI) List(ctx context.Context, resourceGroupName, accountName string) ([]netapp.BackupPolicy, error) {
+
+	a.b.mu.Lock()
+	defer a.b.mu.Unlock()
+
+	accountID := a.b.accountID(resourceGroupName, accountName)
+	if _, found := a.b.accounts[strings.ToLower(accountID)]; !found {
+		return nil, notFound("BackupPoliciesList", accountID)
+	}
+
+	backupPolicies := []netapp.BackupPolicy{}
+	for id, backupPolicy := range a.b.backupPolicies {
+		if strings.HasPrefix(id, strings.ToLower(accountID)+"/") {
+			backupPolicies = append(backupPolicies, backupPolicy)
+		}
+	}
+
+	return backupPolicies, nil
+}
+
+func (a backupPoliciesAPI) Update(ctx context.Context, body netapp.BackupPolicyPatch, resourceGroupName, accountName, backupPolicyName string) (netapp.BackupPolicy, error) {
+
+	a.b.mu.Lock()
+	defer a.b.mu.Unlock()
+
+	id := a.b.backupPolicyID(resourceGroupName, accountName, backupPolicyName)
+	backupPolicy, found := a.b.backupPolicies[strings.ToLower(id)]
+	if !found {
+		return netapp.BackupPolicy{}, notFound("BackupPoliciesUpdate", id)
+	}
+
+	if body.Tags != nil {
+		backupPolicy.Tags = body.Tags
+	}
+	if patch := body.BackupPolicyProperties; patch != nil {
+		properties := *backupPolicy.BackupPolicyProperties
+		if patch.DailyBackupsToKeep != nil {
+			properties.DailyBackupsToKeep = patch.DailyBackupsToKeep
+		}
+		if patch.WeeklyBackupsToKeep != nil {
+			properties.WeeklyBackupsToKeep = patch.WeeklyBackupsToKeep
+		}
+		if patch.MonthlyBackupsToKeep != nil {
+			properties.MonthlyBackupsToKeep = patch.MonthlyBackupsToKeep
+		}
+		if patch.Enabled != nil {
+			properties.Enabled = patch.Enabled
+		}
+		backupPolicy.BackupPolicyProperties = &properties
+	}
+	a.b.backupPolicies[strings.ToLower(id)] = backupPolicy
+
+	return backupPolicy, nil
+}
+
+func (a backupPoliciesAPI) Delete(ctx context.Context, resourceGroupName, accountName, backupPolicyName string) error {
+
+	a.b.mu.Lock()
+	defer a.b.mu.Unlock()
+
+	id := a.b.backupPolicyID(resourceGroupName, accountName, backupPolicyName)
+	if _, found := a.b.backupPolicies[strings.ToLower(id)]; !found {
+		return notFound("BackupPoliciesDelete", id)
+	}
+
+	if a.b.policyAssigned(id) {
+		return conflict("BackupPoliciesDelete", fmt.Sprintf("backup policy %v is still assigned to volumes", backupPolicyName))
+	}
+
+	delete(a.b.backupPolicies, strings.ToLower(id))
+
+	return nil
+}
+
+type backupsAPI struct {
+	b *Backend
+}
+
+func (a backupsAPI) Create(ctx context.Context, body netapp.Backup, resourceGroupName, accountName, poolName, volumeName, backupName string) (netapp.Backup, error) {
+
+	a.b.mu.Lock()
+	defer a.b.mu.Unlock()
+
+	volumeID := a.b.volumeID(resourceGroupName, accountName, poolName, volumeName)
+	if _, found := a.b.volumes[strings.ToLower(volumeID)]; !found {
+		return netapp.Backup{}, notFound("BackupsCreate", volumeID)
+	}
+
+	id := a.b.backupID(resourceGroupName, accountName, poolName, volumeName, backupName)
+	if body.BackupProperties == nil {
+		body.BackupProperties = &netapp.BackupProperties{}
+	}
+	body.ID = to.StringPtr(id)
+	body.Name = to.StringPtr(fmt.Sprintf("%v/%v/%v/%v", accountName, poolName, volumeName, backupName))
+	body.BackupID = to.StringPtr(backupName)
+	body.BackupType = netapp.BackupTypeManual
+	body.CreationDate = &date.Time{Time: time.Now().UTC()}
+	body.VolumeName = to.StringPtr(volumeName)
+	body.ProvisioningState = to.StringPtr(provisioningStateSucceeded)
+	a.b.backups[strings.ToLower(id)] = body
+
+	return body, nil
+}
+
+func (a backupsAPI) Get(ctx context.Context, resourceGroupName, accountName, poolName, volumeName, backupName string) (netapp.Backup, error) {
+
+	a.b.mu.Lock()
+	defer a.b.mu.Unlock()
+
+	id := a.b.backupID(resourceGroupName, accountName, poolName, volumeName, backupName)
+	backup, found := a.b.backups[strings.ToLower(id)]
+	if !found {
+		return netapp.Backup{}, notFound("BackupsGet", id)
+	}
+
+	return backup, nil
+}
+
+func (a backupsAPI) List(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string) ([]netapp.Backup, error) {
+
+	a.b.mu.Lock()
+	defer a.b.mu.Unlock()
+
+	volumeID := a.b.volumeID(resourceGroupName, accountName, poolName, volumeName)
+	if _, found := a.b.volumes[strings.ToLower(volumeID)]; !found {
+		return nil, notFound("BackupsList", volumeID)
+	}
+
+	backups := []netapp.Backup{}
+	for id, backup := range a.b.backups {
+		if strings.HasPrefix(id, strings.ToLower(volumeID)+"/") {
+			backups = append(backups, backup)
+		}
+	}
+
+	return backups, nil
+}
+
+func (a backupsAPI) Update(ctx context.Context, body netapp.BackupPatch, resourceGroupName, accountName, poolName, volumeName, backupName string) (netapp.Backup, error) {
+
+	a.b.mu.Lock()
+	defer a.b.mu.Unlock()
+
+	id := a.b.backupID(resourceGroupName, accountName, poolName, volumeName, backupName)
+	backup, found := a.b.backups[strings.ToLower(id)]
+	if !found {
+		return netapp.Backup{}, notFound("BackupsUpdate", id)
+	}
+
+	if body.BackupProperties != nil && body.Label != nil {
+		properties := *backup.BackupProperties
+		properties.Label = body.Label
+		backup.BackupProperties = &properties
+	}
+	a.b.backups[strings.ToLower(id)] = backup
+
+	return backup, nil
+}
+
+func (a backupsAPI) Delete(ctx context.Context, resourceGroupName, accountName, poolName, volumeName, backupName string) error {
+
+	a.b.mu.Lock()
+	defer a.b.mu.Unlock()
+
+	id := a.b.backupID(resourceGroupName, accountName, poolName, volumeName, backupName)
+	if _, found := a.b.backups[strings.ToLower(id)]; !found {
+		return notFound("BackupsDelete", id)
+	}
+
+	delete(a.b.backups, strings.ToLower(id))
+
+	return nil
+}
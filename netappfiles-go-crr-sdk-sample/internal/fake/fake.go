@@ -0,0 +1,331 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// This package provides an in-memory implementation of the APIs
+// used by sdkutils, including a cross-region replication state
+// machine, so the sample orchestration can be exercised without
+// Azure.
+
+package fake
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/sdkutils"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/uri"
+
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/netapp/mgmt/netapp"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/Azure/go-autorest/autorest/date"
+	"github.com/Azure/go-autorest/autorest/to"
+)
+
+const (
+	provisioningStateSucceeded = "Succeeded"
+
+	// Number of replication status calls a transfer takes to complete
+	defaultTransferSteps = 2
+)
+
+// replication tracks the relationship between a source volume and its destination volume
+type replication struct {
+	sourceID           string
+	destinationID      string
+	authorized         bool
+	mirrorState        netapp.MirrorState
+	relationshipStatus netapp.RelationshipStatus
+	pendingSteps       int
+	transfers          int
+}
+
+// Backend keeps ANF resources in memory and implements the sdkutils APIs on top of them.
+// Replication moves from Uninitialized to Mirrored after authorization, transfers take
+// TransferSteps replication status calls to complete and each completed transfer adds
+// a snapmirror snapshot to both volumes.
+type Backend struct {
+	TransferSteps int
+
+	mu               sync.Mutex
+	subscriptionID   string
+	resources        map[string]bool
+	accounts         map[string]netapp.Account
+	pools            map[string]netapp.CapacityPool
+	volumes          map[string]netapp.Volume
+	snapshots        map[string]netapp.Snapshot
+	snapshotPolicies map[string]netapp.SnapshotPolicy
+	backupPolicies   map[string]netapp.BackupPolicy
+	backups          map[string]netapp.Backup
+	replications     map[string]*replication
+
+	provisioningStates map[string][]string
+}
+
+// New creates an empty backend for a subscription
+func New(subscriptionID string) *Backend {
+	return &Backend{
+		TransferSteps:    defaultTransferSteps,
+		subscriptionID:   subscriptionID,
+		resources:        make(map[string]bool),
+		accounts:         make(map[string]netapp.Account),
+		pools:            make(map[string]netapp.CapacityPool),
+		volumes:          make(map[string]netapp.Volume),
+		snapshots:        make(map[string]netapp.Snapshot),
+		snapshotPolicies: make(map[string]netapp.SnapshotPolicy),
+		backupPolicies:   make(map[string]netapp.BackupPolicy),
+		backups:          make(map[string]netapp.Backup),
+		replications:     make(map[string]*replication),
+
+		provisioningStates: make(map[string][]string),
+	}
+}
+
+// Service returns an sdkutils service backed by this backend
+func (b *Backend) Service() *sdkutils.Service {
+	return &sdkutils.Service{
+		Resources:        resourcesAPI{b},
+		Accounts:         accountsAPI{b},
+		Pools:            poolsAPI{b},
+		Volumes:          volumesAPI{b},
+		Snapshots:        snapshotsAPI{b},
+		SnapshotPolicies: snapshotPoliciesAPI{b},
+		BackupPolicies:   backupPoliciesAPI{b},
+		Backups:          backupsAPI{b},
+	}
+}
+
+// AddResource registers a non ANF resource, e.g. a subnet, so it can be retrieved by its id
+func (b *Backend) AddResource(resourceID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.resources[strings.ToLower(resourceID)] = true
+}
+
+// SetProvisioningStates makes the next get operations of an account, capacity pool, volume or snapshot
+// report these provisioning states, one per get, the last one is kept for the following gets
+func (b *Backend) SetProvisioningStates(resourceID string, provisioningStates ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.provisioningStates[strings.ToLower(resourceID)] = provisioningStates
+}
+
+// SetSnapshotCreated changes the creation time of a snapshot, e.g. to lay snapshots out over time
+func (b *Backend) SetSnapshotCreated(snapshotID string, created time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if snapshot, found := b.snapshots[strings.ToLower(snapshotID)]; found && snapshot.SnapshotProperties != nil {
+		properties := *snapshot.SnapshotProperties
+		properties.Created = &date.Time{Time: created.UTC()}
+		snapshot.SnapshotProperties = &properties
+		b.snapshots[strings.ToLower(snapshotID)] = snapshot
+	}
+}
+
+// ReplicationState returns the mirror state and relationship status of the replication a volume takes part in
+func (b *Backend) ReplicationState(volumeID string) (netapp.MirrorState, netapp.RelationshipStatus, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r := b.findReplication(volumeID)
+	if r == nil {
+		return "", "", false
+	}
+	return r.mirrorState, r.relationshipStatus, true
+}
+
+// ResourceIDs returns the ids of all ANF resources currently in the backend
+func (b *Backend) ResourceIDs() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var ids []string
+	for _, account := range b.accounts {
+		ids = append(ids, to.String(account.ID))
+	}
+	for _, pool := range b.pools {
+		ids = append(ids, to.String(pool.ID))
+	}
+	for _, volume := range b.volumes {
+		ids = append(ids, to.String(volume.ID))
+	}
+	for _, snapshot := range b.snapshots {
+		ids = append(ids, to.String(snapshot.ID))
+	}
+	for _, snapshotPolicy := range b.snapshotPolicies {
+		ids = append(ids, to.String(snapshotPolicy.ID))
+	}
+	for _, backupPolicy := range b.backupPolicies {
+		ids = append(ids, to.String(backupPolicy.ID))
+	}
+	for _, backup := range b.backups {
+		ids = append(ids, to.String(backup.ID))
+	}
+	return ids
+}
+
+func (b *Backend) accountID(resourceGroupName, accountName string) string {
+	return uri.AccountID(b.subscriptionID, resourceGroupName, accountName).String()
+}
+
+func (b *Backend) poolID(resourceGroupName, accountName, poolName string) string {
+	return uri.CapacityPoolID(b.subscriptionID, resourceGroupName, accountName, poolName).String()
+}
+
+func (b *Backend) volumeID(resourceGroupName, accountName, poolName, volumeName string) string {
+	return uri.VolumeID(b.subscriptionID, resourceGroupName, accountName, poolName, volumeName).String()
+}
+
+func (b *Backend) snapshotID(resourceGroupName, accountName, poolName, volumeName, snapshotName string) string {
+	return uri.SnapshotID(b.subscriptionID, resourceGroupName, accountName, poolName, volumeName, snapshotName).String()
+}
+
+func (b *Backend) snapshotPolicyID(resourceGroupName, accountName, snapshotPolicyName string) string {
+	return uri.SnapshotPolicyID(b.subscriptionID, resourceGroupName, accountName, snapshotPolicyName).String()
+}
+
+func (b *Backend) backupPolicyID(resourceGroupName, accountName, backupPolicyName string) string {
+	return uri.BackupPolicyID(b.subscriptionID, resourceGroupName, accountName, backupPolicyName).String()
+}
+
+func (b *Backend) backupID(resourceGroupName, accountName, poolName, volumeName, backupName string) string {
+	return uri.BackupID(b.subscriptionID, resourceGroupName, accountName, poolName, volumeName, backupName).String()
+}
+
+// policyAssigned checks if a snapshot or backup policy is assigned to any volume
+func (b *Backend) policyAssigned(policyID string) bool {
+	for _, volume := range b.volumes {
+		if volume.VolumeProperties == nil || volume.DataProtection == nil {
+			continue
+		}
+		dataProtection := volume.DataProtection
+		if dataProtection.Snapshot != nil && strings.EqualFold(to.String(dataProtection.Snapshot.SnapshotPolicyID), policyID) {
+			return true
+		}
+		if dataProtection.Backup != nil && strings.EqualFold(to.String(dataProtection.Backup.BackupPolicyID), policyID) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextProvisioningState returns the provisioning state set for the next get operation of a resource, if any
+func (b *Backend) nextProvisioningState(id string) (*string, bool) {
+	provisioningStates := b.provisioningStates[strings.ToLower(id)]
+	if len(provisioningStates) == 0 {
+		return nil, false
+	}
+	if len(provisioningStates) > 1 {
+		b.provisioningStates[strings.ToLower(id)] = provisioningStates[1:]
+	}
+	return to.StringPtr(provisioningStates[0]), true
+}
+
+// snapshotExists checks if there is a snapshot with a snapshot id (UUID) under an account
+func (b *Backend) snapshotExists(accountID, snapshotID string) bool {
+	for id, snapshot := range b.snapshots {
+		if strings.HasPrefix(id, strings.ToLower(accountID)+"/") && snapshot.SnapshotProperties != nil && strings.EqualFold(to.String(snapshot.SnapshotID), snapshotID) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasChildren checks if there is any resource of a collection under a parent id
+func hasChildren(parentID string, ids []string) bool {
+	for _, id := range ids {
+		if strings.HasPrefix(strings.ToLower(id), strings.ToLower(parentID)+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// findReplication returns the replication a volume takes part in, as source or destination
+func (b *Backend) findReplication(volumeID string) *replication {
+	for _, r := range b.replications {
+		if strings.EqualFold(r.sourceID, volumeID) || strings.EqualFold(r.destinationID, volumeID) {
+			return r
+		}
+	}
+	return nil
+}
+
+// startTransfer moves a replication into a transfer that completes after TransferSteps status calls
+func (b *Backend) startTransfer(r *replication) {
+	r.relationshipStatus = netapp.RelationshipStatusTransferring
+	r.pendingSteps = b.TransferSteps
+	b.advance(r)
+}
+
+// advance progresses an ongoing transfer, once completed the replication is mirrored and idle
+func (b *Backend) advance(r *replication) {
+
+	if r.relationshipStatus != netapp.RelationshipStatusTransferring {
+		return
+	}
+	if r.pendingSteps > 0 {
+		r.pendingSteps--
+		return
+	}
+
+	r.mirrorState = netapp.MirrorStateMirrored
+	r.relationshipStatus = netapp.RelationshipStatusIdle
+	r.transfers++
+
+	snapshotName := fmt.Sprintf("snapmirror.%08d_%v", r.transfers, time.Now().UTC().Format("2006-01-02_150405"))
+	for _, volumeID := range []string{r.sourceID, r.destinationID} {
+		id := fmt.Sprintf("%v/snapshots/%v", volumeID, snapshotName)
+		b.snapshots[strings.ToLower(id)] = newSnapshot(id)
+	}
+}
+
+// totalProgress returns a transfer progress, in bytes, based on the completed transfers
+func (r *replication) totalProgress() string {
+	return fmt.Sprintf("%d", r.transfers*1048576)
+}
+
+// newSnapshot builds a snapshot named after its hierarchy, like ARM does
+func newSnapshot(id string) netapp.Snapshot {
+	return netapp.Snapshot{
+		ID:   to.StringPtr(id),
+		Name: to.StringPtr(fmt.Sprintf("%v/%v/%v/%v", uri.GetAnfAccount(id), uri.GetAnfCapacityPool(id), uri.GetAnfVolume(id), uri.GetAnfSnapshot(id))),
+		SnapshotProperties: &netapp.SnapshotProperties{
+			SnapshotID:        to.StringPtr(newUUID()),
+			Created:           &date.Time{Time: time.Now().UTC()},
+			ProvisioningState: to.StringPtr(provisioningStateSucceeded),
+		},
+	}
+}
+
+// newUUID builds a random (version 4) UUID, like the ids ARM gives to snapshots
+func newUUID() string {
+	uuid := make([]byte, 16)
+	rand.Read(uuid)
+	uuid[6] = uuid[6]&0x0f | 0x40
+	uuid[8] = uuid[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:])
+}
+
+// newError builds an error with an HTTP status code, the same way SDK clients report failed responses
+func newError(method string, statusCode int, code, message string) error {
+	return autorest.DetailedError{
+		Original:    &azure.ServiceError{Code: code, Message: message},
+		PackageType: "fake",
+		Method:      method,
+		StatusCode:  statusCode,
+		Message:     fmt.Sprintf("%v: %v", code, message),
+	}
+}
+
+func notFound(method, resourceID string) error {
+	return newError(method, http.StatusNotFound, "ResourceNotFound", fmt.Sprintf("resource %v not found", resourceID))
+}
+
+func conflict(method, message string) error {
+	return newError(method, http.StatusConflict, "Conflict", message)
+}
@@ -0,0 +1,776 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package fake
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/uri"
+
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/netapp/mgmt/netapp"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/Azure/go-autorest/autorest/to"
+)
+
+const (
+	// Number of times a long running operation reports InProgress before completing
+	defaultPollingSteps = 1
+)
+
+// Failure is a scripted failure returned by the server for the requests it matches
+type Failure struct {
+	Method       string // HTTP method, empty matches any method
+	PathContains string // Case insensitive part of the request path, empty matches any path
+	StatusCode   int    // HTTP status code of the response, not used by asynchronous failures
+	Code         string // Error code, e.g. VolumeReplicationMissing
+	Message      string // Error message
+	Async        bool   // Request is accepted but its long running operation fails
+	Times        int    // Number of matching requests that fail, 0 means once
+}
+
+// operation is a long running operation tracked by the server
+type operation struct {
+	pending  int
+	err      *azure.ServiceError
+	location bool
+}
+
+// Server is a local stand-in for Azure Resource Manager serving the Microsoft.NetApp endpoints used by this sample,
+// long running operations follow the Azure-AsyncOperation protocol so SDK clients can be pointed at it with their base URI.
+// It also serves an Azure AD token endpoint, so an authentication file can use it as active directory endpoint.
+type Server struct {
+	*httptest.Server
+	PollingSteps    int
+	LocationPolling bool // Accepted deletes and actions are tracked with a Location header instead of Azure-AsyncOperation
+
+	backend    *Backend
+	mu         sync.Mutex
+	failures   []*Failure
+	operations map[string]*operation
+	operation  int
+}
+
+// NewServer starts a server backed by a backend
+func NewServer(backend *Backend) *Server {
+	server := NewUnstartedServer(backend)
+	server.Start()
+	return server
+}
+
+// NewUnstartedServer returns a server that is not started yet, e.g. to listen on a specific address
+func NewUnstartedServer(backend *Backend) *Server {
+	server := &Server{
+		PollingSteps: defaultPollingSteps,
+		backend:      backend,
+		operations:   make(map[string]*operation),
+	}
+	server.Server = httptest.NewUnstartedServer(http.HandlerFunc(server.serveHTTP))
+	return server
+}
+
+// AddFailure scripts a failure for the next requests matching it
+func (s *Server) AddFailure(failure Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if failure.Times <= 0 {
+		failure.Times = 1
+	}
+	s.failures = append(s.failures, &failure)
+}
+
+// nextFailure returns and consumes the first scripted failure matching a request
+func (s *Server) nextFailure(r *http.Request) *Failure {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, failure := range s.failures {
+		if (failure.Method == "" || strings.EqualFold(failure.Method, r.Method)) &&
+			strings.Contains(strings.ToLower(r.URL.Path), strings.ToLower(failure.PathContains)) {
+			failure.Times--
+			if failure.Times == 0 {
+				s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			}
+			return failure
+		}
+	}
+	return nil
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	// Azure AD token endpoint: /{tenant}/oauth2/token
+	if len(segments) == 3 && strings.EqualFold(segments[1], "oauth2") && strings.EqualFold(segments[2], "token") {
+		s.serveToken(w, r)
+		return
+	}
+
+	// Long running operations: /operations/{id}
+	if len(segments) == 2 && segments[0] == "operations" {
+		s.serveOperation(w, segments[1])
+		return
+	}
+
+	if failure := s.nextFailure(r); failure != nil {
+		if failure.Async && r.Method != http.MethodGet {
+			s.accept(w, r, nil, &azure.ServiceError{Code: failure.Code, Message: failure.Message})
+		} else {
+			writeError(w, failure.StatusCode, failure.Code, failure.Message)
+		}
+		return
+	}
+
+	// ARM resources: /subscriptions/{subscription}/resourceGroups/{resourceGroup}/providers/{namespace}/...
+	if len(segments) < 6 || !strings.EqualFold(segments[0], "subscriptions") || !strings.EqualFold(segments[2], "resourceGroups") || !strings.EqualFold(segments[4], "providers") {
+		writeError(w, http.StatusNotFound, "NotFound", fmt.Sprintf("unsupported path %v", r.URL.Path))
+		return
+	}
+
+	resourceGroupName := segments[3]
+	if !strings.EqualFold(segments[5], "Microsoft.NetApp") {
+		s.serveGenericResource(w, r, resourceGroupName, segments[5], segments[6:])
+		return
+	}
+
+	s.serveNetApp(w, r, resourceGroupName, segments[6:])
+}
+
+// serveNetApp serves netAppAccounts/{account}[/capacityPools/{pool}[/volumes/{volume}[/snapshots[/{snapshot}] or /{action}]]],
+// account policies and volume backups
+func (s *Server) serveNetApp(w http.ResponseWriter, r *http.Request, resourceGroupName string, segments []string) {
+
+	ctx := r.Context()
+	collections := []string{"netAppAccounts", "capacityPools", "volumes", "snapshots"}
+
+	// netAppAccounts/{account}/snapshotPolicies[/{policy}] and netAppAccounts/{account}/backupPolicies[/{policy}]
+	if (len(segments) == 3 || len(segments) == 4) && strings.EqualFold(segments[0], collections[0]) &&
+		(strings.EqualFold(segments[2], uri.SnapshotPoliciesType) || strings.EqualFold(segments[2], uri.BackupPoliciesType)) {
+		s.servePolicy(w, r, resourceGroupName, segments[1], segments[2], segments[3:])
+		return
+	}
+
+	// netAppAccounts/{account}/capacityPools/{pool}/volumes/{volume}/backups[/{backup}]
+	if (len(segments) == 7 || len(segments) == 8) && strings.EqualFold(segments[6], uri.BackupsType) {
+		s.serveBackup(w, r, resourceGroupName, segments[1], segments[3], segments[5], segments[7:])
+		return
+	}
+
+	var names []string
+	for i := 0; i < len(segments); i += 2 {
+		if i/2 >= len(collections) || !strings.EqualFold(segments[i], collections[i/2]) {
+			if i == 6 && i+1 == len(segments) {
+				s.serveVolumeAction(w, r, resourceGroupName, names, segments[i])
+				return
+			}
+			writeError(w, http.StatusNotFound, "NotFound", fmt.Sprintf("unsupported path %v", r.URL.Path))
+			return
+		}
+		if i+1 == len(segments) {
+			if i == 6 && r.Method == http.MethodGet {
+				snapshots, err := snapshotsAPI{s.backend}.List(ctx, resourceGroupName, names[0], names[1], names[2])
+				s.writeList(w, snapshots, err)
+				return
+			}
+			writeError(w, http.StatusNotFound, "NotFound", fmt.Sprintf("unsupported path %v", r.URL.Path))
+			return
+		}
+		names = append(names, segments[i+1])
+	}
+
+	switch len(names) {
+	case 1:
+		s.serveAccount(w, r, resourceGroupName, names[0])
+	case 2:
+		s.servePool(w, r, resourceGroupName, names[0], names[1])
+	case 3:
+		s.serveVolume(w, r, resourceGroupName, names[0], names[1], names[2])
+	case 4:
+		s.serveSnapshot(w, r, resourceGroupName, names[0], names[1], names[2], names[3])
+	default:
+		writeError(w, http.StatusNotFound, "NotFound", fmt.Sprintf("unsupported path %v", r.URL.Path))
+	}
+}
+
+func (s *Server) serveAccount(w http.ResponseWriter, r *http.Request, resourceGroupName, accountName string) {
+
+	api := accountsAPI{s.backend}
+
+	switch r.Method {
+	case http.MethodGet:
+		account, err := api.Get(r.Context(), resourceGroupName, accountName)
+		s.writeResource(w, http.StatusOK, account, err)
+	case http.MethodPut:
+		var body netapp.Account
+		if !readBody(w, r, &body) {
+			return
+		}
+		account, err := api.CreateOrUpdate(r.Context(), body, resourceGroupName, accountName)
+		s.acceptResource(w, r, account, err)
+	case http.MethodDelete:
+		s.accept(w, r, api.Delete(r.Context(), resourceGroupName, accountName), nil)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", r.Method)
+	}
+}
+
+func (s *Server) servePool(w http.ResponseWriter, r *http.Request, resourceGroupName, accountName, poolName string) {
+
+	api := poolsAPI{s.backend}
+
+	switch r.Method {
+	case http.MethodGet:
+		pool, err := api.Get(r.Context(), resourceGroupName, accountName, poolName)
+		s.writeResource(w, http.StatusOK, pool, err)
+	case http.MethodPut:
+		var body netapp.CapacityPool
+		if !readBody(w, r, &body) {
+			return
+		}
+		pool, err := api.CreateOrUpdate(r.Context(), body, resourceGroupName, accountName, poolName)
+		s.acceptResource(w, r, pool, err)
+	case http.MethodDelete:
+		s.accept(w, r, api.Delete(r.Context(), resourceGroupName, accountName, poolName), nil)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", r.Method)
+	}
+}
+
+func (s *Server) serveVolume(w http.ResponseWriter, r *http.Request, resourceGroupName, accountName, poolName, volumeName string) {
+
+	api := volumesAPI{s.backend}
+
+	switch r.Method {
+	case http.MethodGet:
+		volume, err := api.Get(r.Context(), resourceGroupName, accountName, poolName, volumeName)
+		s.writeResource(w, http.StatusOK, volume, err)
+	case http.MethodPut:
+		var body netapp.Volume
+		if !readBody(w, r, &body) {
+			return
+		}
+		volume, err := api.CreateOrUpdate(r.Context(), body, resourceGroupName, accountName, poolName, volumeName)
+		s.acceptResource(w, r, volume, err)
+	case http.MethodPatch:
+		var body netapp.VolumePatch
+		if !readBody(w, r, &body) {
+			return
+		}
+		volume, err := api.Update(r.Context(), body, resourceGroupName, accountName, poolName, volumeName)
+		s.acceptResource(w, r, volume, err)
+	case http.MethodDelete:
+		s.accept(w, r, api.Delete(r.Context(), resourceGroupName, accountName, poolName, volumeName), nil)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", r.Method)
+	}
+}
+
+// serveVolumeAction serves replication related operations of a volume
+func (s *Server) serveVolumeAction(w http.ResponseWriter, r *http.Request, resourceGroupName string, names []string, action string) {
+
+	api := volumesAPI{s.backend}
+	ctx := r.Context()
+	accountName, poolName, volumeName := names[0], names[1], names[2]
+
+	if strings.EqualFold(action, "replicationStatus") && r.Method == http.MethodGet {
+		replicationStatus, err := api.ReplicationStatus(ctx, resourceGroupName, accountName, poolName, volumeName)
+		if err != nil {
+			writeBackendError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, replicationStatus)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", r.Method)
+		return
+	}
+
+	var err error
+	switch strings.ToLower(action) {
+	case "authorizereplication":
+		var body netapp.AuthorizeRequest
+		if !readBody(w, r, &body) {
+			return
+		}
+		err = api.AuthorizeReplication(ctx, resourceGroupName, accountName, poolName, volumeName, body)
+	case "breakreplication":
+		var body netapp.BreakReplicationRequest
+		if !readBody(w, r, &body) {
+			return
+		}
+		err = api.BreakReplication(ctx, resourceGroupName, accountName, poolName, volumeName, &body)
+	case "resyncreplication":
+		err = api.ResyncReplication(ctx, resourceGroupName, accountName, poolName, volumeName)
+	case "deletereplication":
+		err = api.DeleteReplication(ctx, resourceGroupName, accountName, poolName, volumeName)
+	case "revert":
+		var body netapp.VolumeRevert
+		if !readBody(w, r, &body) {
+			return
+		}
+		err = api.Revert(ctx, resourceGroupName, accountName, poolName, volumeName, body)
+	default:
+		writeError(w, http.StatusNotFound, "NotFound", fmt.Sprintf("unsupported volume action %v", action))
+		return
+	}
+
+	s.accept(w, r, err, nil)
+}
+
+func (s *Server) serveSnapshot(w http.ResponseWriter, r *http.Request, resourceGroupName, accountName, poolName, volumeName, snapshotName string) {
+
+	api := snapshotsAPI{s.backend}
+
+	switch r.Method {
+	case http.MethodGet:
+		snapshot, err := api.Get(r.Context(), resourceGroupName, accountName, poolName, volumeName, snapshotName)
+		s.writeResource(w, http.StatusOK, snapshot, err)
+	case http.MethodPut:
+		var body netapp.Snapshot
+		if !readBody(w, r, &body) {
+			return
+		}
+		snapshot, err := api.Create(r.Context(), body, resourceGroupName, accountName, poolName, volumeName, snapshotName)
+		s.acceptResource(w, r, snapshot, err)
+	case http.MethodDelete:
+		s.accept(w, r, api.Delete(r.Context(), resourceGroupName, accountName, poolName, volumeName, snapshotName), nil)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", r.Method)
+	}
+}
+
+// servePolicy serves the snapshot or backup policies of an account, names holds the policy name unless the collection is listed
+func (s *Server) servePolicy(w http.ResponseWriter, r *http.Request, resourceGroupName, accountName, collection string, names []string) {
+
+	ctx := r.Context()
+	snapshotPolicies := strings.EqualFold(collection, uri.SnapshotPoliciesType)
+
+	if len(names) == 0 {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", r.Method)
+			return
+		}
+		if snapshotPolicies {
+			policies, err := snapshotPoliciesAPI{s.backend}.List(ctx, resourceGroupName, accountName)
+			s.writeList(w, policies, err)
+		} else {
+			policies, err := backupPoliciesAPI{s.backend}.List(ctx, resourceGroupName, accountName)
+			s.writeList(w, policies, err)
+		}
+		return
+	}
+
+	policyName := names[0]
+
+	if snapshotPolicies {
+		api := snapshotPoliciesAPI{s.backend}
+		switch r.Method {
+		case http.MethodGet:
+			policy, err := api.Get(ctx, resourceGroupName, accountName, policyName)
+			s.writeResource(w, http.StatusOK, policy, err)
+		case http.MethodPut:
+			// Snapshot policies are created synchronously
+			var body netapp.SnapshotPolicy
+			if !readBody(w, r, &body) {
+				return
+			}
+			policy, err := api.Create(ctx, body, resourceGroupName, accountName, policyName)
+			s.writeResource(w, http.StatusCreated, policy, err)
+		case http.MethodPatch:
+			var body netapp.SnapshotPolicyPatch
+			if !readBody(w, r, &body) {
+				return
+			}
+			policy, err := api.Update(ctx, body, resourceGroupName, accountName, policyName)
+			s.acceptResource(w, r, policy, err)
+		case http.MethodDelete:
+			s.accept(w, r, api.Delete(ctx, resourceGroupName, accountName, policyName), nil)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", r.Method)
+		}
+		return
+	}
+
+	api := backupPoliciesAPI{s.backend}
+	switch r.Method {
+	case http.MethodGet:
+		policy, err := api.Get(ctx, resourceGroupName, accountName, policyName)
+		s.writeResource(w, http.StatusOK, policy, err)
+	case http.MethodPut:
+		var body netapp.BackupPolicy
+		if !readBody(w, r, &body) {
+			return
+		}
+		policy, err := api.Create(ctx, body, resourceGroupName, accountName, policyName)
+		s.acceptResource(w, r, policy, err)
+	case http.MethodPatch:
+		var body netapp.BackupPolicyPatch
+		if !readBody(w, r, &body) {
+			return
+		}
+		policy, err := api.Update(ctx, body, resourceGroupName, accountName, policyName)
+		s.acceptResource(w, r, policy, err)
+	case http.MethodDelete:
+		s.accept(w, r, api.Delete(ctx, resourceGroupName, accountName, policyName), nil)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", r.Method)
+	}
+}
+
+// serveBackup serves the backups of a volume, names holds the backup name unless the collection is listed
+func (s *Server) serveBackup(w http.ResponseWriter, r *http.Request, resourceGroupName, accountName, poolName, volumeName string, names []string) {
+
+	ctx := r.Context()
+	api := backupsAPI{s.backend}
+
+	if len(names) == 0 {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", r.Method)
+			return
+		}
+		backups, err := api.List(ctx, resourceGroupName, accountName, poolName, volumeName)
+		s.writeList(w, backups, err)
+		return
+	}
+
+	backupName := names[0]
+
+	switch r.Method {
+	case http.MethodGet:
+		backup, err := api.Get(ctx, resourceGroupName, accountName, poolName, volumeName, backupName)
+		s.writeResource(w, http.StatusOK, backup, err)
+	case http.MethodPut:
+		var body netapp.Backup
+		if !readBody(w, r, &body) {
+			return
+		}
+		backup, err := api.Create(ctx, body, resourceGroupName, accountName, poolName, volumeName, backupName)
+		s.acceptResource(w, r, backup, err)
+	case http.MethodPatch:
+		var body netapp.BackupPatch
+		if !readBody(w, r, &body) {
+			return
+		}
+		backup, err := api.Update(ctx, body, resourceGroupName, accountName, poolName, volumeName, backupName)
+		s.acceptResource(w, r, backup, err)
+	case http.MethodDelete:
+		s.accept(w, r, api.Delete(ctx, resourceGroupName, accountName, poolName, volumeName, backupName), nil)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", r.Method)
+	}
+}
+
+// serveGenericResource serves GET of non ANF resources registered in the backend, e.g. subnets
+func (s *Server) serveGenericResource(w http.ResponseWriter, r *http.Request, resourceGroupName, namespace string, segments []string) {
+
+	if r.Method != http.MethodGet || (len(segments) != 2 && len(segments) != 4) {
+		writeError(w, http.StatusNotFound, "NotFound", fmt.Sprintf("unsupported path %v", r.URL.Path))
+		return
+	}
+
+	var parentResourcePath string
+	if len(segments) == 4 {
+		parentResourcePath = strings.Join(segments[:2], "/")
+		segments = segments[2:]
+	}
+
+	resource, err := resourcesAPI{s.backend}.Get(r.Context(), resourceGroupName, namespace, parentResourcePath, segments[0], segments[1], r.URL.Query().Get("api-version"))
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"id": to.String(resource.ID), "name": to.String(resource.Name)})
+}
+
+// serveToken returns a token that is valid for one hour, credentials are not checked
+func (s *Server) serveToken(w http.ResponseWriter, r *http.Request) {
+	now := time.Now().Unix()
+	writeJSON(w, http.StatusOK, map[string]string{
+		"access_token": "fake-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   "3600",
+		"expires_on":   strconv.FormatInt(now+3600, 10),
+		"not_before":   strconv.FormatInt(now, 10),
+		"resource":     r.FormValue("resource"),
+	})
+}
+
+// serveOperation reports the status of a long running operation
+func (s *Server) serveOperation(w http.ResponseWriter, id string) {
+
+	s.mu.Lock()
+	op, found := s.operations[id]
+	if found && op.pending > 0 {
+		op.pending--
+		s.mu.Unlock()
+		w.Header().Set("Retry-After", "0")
+		if op.location {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "InProgress"})
+		return
+	}
+	delete(s.operations, id)
+	s.mu.Unlock()
+
+	if !found {
+		writeError(w, http.StatusNotFound, "NotFound", fmt.Sprintf("operation %v not found", id))
+		return
+	}
+
+	// Location protocol reports completion with the status code, SDK clients take a failure from the provisioning state
+	if op.location {
+		if op.err != nil {
+			writeJSON(w, http.StatusOK, map[string]interface{}{
+				"properties": map[string]string{"provisioningState": "Failed"},
+				"error":      op.err,
+			})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if op.err != nil {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"status": "Failed", "error": op.err})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "Succeeded"})
+}
+
+// acceptResource answers a create or update request with the resource and a long running operation
+func (s *Server) acceptResource(w http.ResponseWriter, r *http.Request, resource interface{}, err error) {
+
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+
+	body, err := resourceJSON(resource)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "InternalServerError", err.Error())
+		return
+	}
+
+	s.startOperation(w, r, nil)
+	writeJSON(w, http.StatusCreated, body)
+}
+
+// accept answers an action or delete request with a long running operation, that fails if asyncErr is set
+func (s *Server) accept(w http.ResponseWriter, r *http.Request, err error, asyncErr *azure.ServiceError) {
+
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+
+	s.startOperation(w, r, asyncErr)
+	statusCode := http.StatusAccepted
+	if r.Method == http.MethodPut {
+		statusCode = http.StatusCreated
+	}
+	writeJSON(w, statusCode, map[string]string{})
+}
+
+// startOperation registers a long running operation and sets its headers,
+// creations always use Azure-AsyncOperation since SDK clients only follow Location for accepted requests
+func (s *Server) startOperation(w http.ResponseWriter, r *http.Request, asyncErr *azure.ServiceError) {
+
+	s.mu.Lock()
+	s.operation++
+	id := strconv.Itoa(s.operation)
+	location := s.LocationPolling && r.Method != http.MethodPut
+	s.operations[id] = &operation{pending: s.PollingSteps, err: asyncErr, location: location}
+	s.mu.Unlock()
+
+	operationURL := fmt.Sprintf("http://%v/operations/%v", r.Host, id)
+	if location {
+		w.Header().Set("Location", operationURL)
+	} else {
+		w.Header().Set("Azure-AsyncOperation", operationURL)
+	}
+	w.Header().Set("Retry-After", "0")
+}
+
+// writeResource answers with a resource or with the error returned by the backend
+func (s *Server) writeResource(w http.ResponseWriter, statusCode int, resource interface{}, err error) {
+
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+
+	body, err := resourceJSON(resource)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "InternalServerError", err.Error())
+		return
+	}
+
+	writeJSON(w, statusCode, body)
+}
+
+// writeList answers with a list of resources, e.g. []netapp.Snapshot
+func (s *Server) writeList(w http.ResponseWriter, resources interface{}, err error) {
+
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+
+	value := []map[string]interface{}{}
+	items := reflect.ValueOf(resources)
+	for i := 0; i < items.Len(); i++ {
+		body, err := resourceJSON(items.Index(i).Interface())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "InternalServerError", err.Error())
+			return
+		}
+		value = append(value, body)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"value": value})
+}
+
+// resourceJSON serializes a resource including the read-only properties, which are left out by SDK models
+func resourceJSON(resource interface{}) (map[string]interface{}, error) {
+
+	resourceJSON, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	body := map[string]interface{}{}
+	err = json.Unmarshal(resourceJSON, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	properties, _ := body["properties"].(map[string]interface{})
+	if properties == nil {
+		properties = map[string]interface{}{}
+		body["properties"] = properties
+	}
+
+	switch resource := resource.(type) {
+	case netapp.Account:
+		body["id"], body["name"] = to.String(resource.ID), to.String(resource.Name)
+		if resource.AccountProperties != nil {
+			properties["provisioningState"] = to.String(resource.ProvisioningState)
+		}
+	case netapp.CapacityPool:
+		body["id"], body["name"] = to.String(resource.ID), to.String(resource.Name)
+		if resource.PoolProperties != nil {
+			properties["provisioningState"] = to.String(resource.ProvisioningState)
+		}
+	case netapp.Volume:
+		body["id"], body["name"] = to.String(resource.ID), to.String(resource.Name)
+		if resource.VolumeProperties != nil {
+			properties["provisioningState"] = to.String(resource.ProvisioningState)
+			if resource.MountTargets != nil {
+				var mountTargets []map[string]string
+				for _, mountTarget := range *resource.MountTargets {
+					mountTargets = append(mountTargets, map[string]string{"ipAddress": to.String(mountTarget.IPAddress)})
+				}
+				properties["mountTargets"] = mountTargets
+			}
+		}
+	case netapp.Snapshot:
+		body["id"], body["name"] = to.String(resource.ID), to.String(resource.Name)
+		if resource.SnapshotProperties != nil {
+			properties["provisioningState"] = to.String(resource.ProvisioningState)
+			properties["snapshotId"] = to.String(resource.SnapshotID)
+			if resource.Created != nil {
+				properties["created"] = resource.Created.Format(time.RFC3339Nano)
+			}
+		}
+	case netapp.SnapshotPolicy:
+		body["id"], body["name"] = to.String(resource.ID), to.String(resource.Name)
+		if resource.SnapshotPolicyProperties != nil {
+			properties["provisioningState"] = to.String(resource.ProvisioningState)
+		}
+	case netapp.BackupPolicy:
+		body["id"], body["name"] = to.String(resource.ID), to.String(resource.Name)
+		if resource.BackupPolicyProperties != nil {
+			properties["provisioningState"] = to.String(resource.ProvisioningState)
+		}
+	case netapp.Backup:
+		body["id"], body["name"] = to.String(resource.ID), to.String(resource.Name)
+		if resource.BackupProperties != nil {
+			properties["provisioningState"] = to.String(resource.ProvisioningState)
+			properties["backupId"] = to.String(resource.BackupID)
+			properties["backupType"] = resource.BackupType
+			properties["volumeName"] = to.String(resource.VolumeName)
+			if resource.CreationDate != nil {
+				properties["creationDate"] = resource.CreationDate.Format(time.RFC3339)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported resource type %T", resource)
+	}
+
+	return body, nil
+}
+
+// readBody unmarshals a request body, answering with an error if it is not valid
+func readBody(w http.ResponseWriter, r *http.Request, body interface{}) bool {
+
+	requestBody, err := ioutil.ReadAll(r.Body)
+	if err == nil && len(requestBody) > 0 {
+		err = json.Unmarshal(requestBody, body)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidRequestContent", err.Error())
+		return false
+	}
+
+	return true
+}
+
+// writeBackendError answers with the status code and error code of a backend error
+func writeBackendError(w http.ResponseWriter, err error) {
+
+	statusCode := http.StatusInternalServerError
+	var detailedError autorest.DetailedError
+	if errors.As(err, &detailedError) {
+		if code, ok := detailedError.StatusCode.(int); ok {
+			statusCode = code
+		}
+	}
+
+	var serviceError *azure.ServiceError
+	if errors.As(err, &serviceError) {
+		writeError(w, statusCode, serviceError.Code, serviceError.Message)
+		return
+	}
+
+	writeError(w, statusCode, "InternalServerError", err.Error())
+}
+
+func writeError(w http.ResponseWriter, statusCode int, code, message string) {
+	writeJSON(w, statusCode, map[string]interface{}{
+		"error": map[string]string{"code": code, "message": message},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(body)
+}
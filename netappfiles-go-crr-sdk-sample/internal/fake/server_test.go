@@ -0,0 +1,183 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package fake
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/poll"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/sdkutils"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/uri"
+
+	"github.com/Azure/go-autorest/autorest"
+)
+
+const (
+	testSubscriptionID    = "00000000-0000-0000-0000-000000000000"
+	testResourceGroupName = "anf-rg"
+	testAccountName       = "account"
+)
+
+var testAccountID = uri.AccountID(testSubscriptionID, testResourceGroupName, testAccountName).String()
+
+// newTestServer starts a server and builds the SDK clients of this sample pointing at it
+func newTestServer(t *testing.T) (*Server, *Backend, *sdkutils.ClientFactory) {
+
+	t.Helper()
+
+	initialWait, retryDuration := poll.InitialWait, sdkutils.RetryDuration
+	poll.InitialWait, sdkutils.RetryDuration = time.Millisecond, time.Millisecond
+	t.Cleanup(func() { poll.InitialWait, sdkutils.RetryDuration = initialWait, retryDuration })
+
+	backend := New(testSubscriptionID)
+	server := NewServer(backend)
+	t.Cleanup(server.Close)
+
+	return server, backend, sdkutils.NewClientFactoryWithBaseURI(server.URL, autorest.NullAuthorizer{}, testSubscriptionID)
+}
+
+// statusCode returns the HTTP status code of an SDK error, 0 if there is none
+func statusCode(err error) int {
+	var detailedError autorest.DetailedError
+	if errors.As(err, &detailedError) {
+		if code, ok := detailedError.StatusCode.(int); ok {
+			return code
+		}
+	}
+	return 0
+}
+
+// hasResource checks if the backend has a resource
+func hasResource(backend *Backend, resourceID string) bool {
+	for _, id := range backend.ResourceIDs() {
+		if strings.EqualFold(id, resourceID) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestServerLongRunningOperations(t *testing.T) {
+
+	tests := []struct {
+		name            string
+		locationPolling bool
+	}{
+		{name: "Azure-AsyncOperation"},
+		{name: "Location", locationPolling: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			server, backend, clients := newTestServer(t)
+			server.PollingSteps = 3
+			server.LocationPolling = test.locationPolling
+			service := sdkutils.NewService(clients)
+			ctx := context.Background()
+
+			if _, err := service.CreateAnfAccount(ctx, "westus", testResourceGroupName, testAccountName, nil, nil); err != nil {
+				t.Fatalf("cannot create account: %v", err)
+			}
+			if !hasResource(backend, testAccountID) {
+				t.Fatalf("account %v was not created", testAccountID)
+			}
+
+			// Operation accepted by the server but failing while polling
+			server.AddFailure(Failure{Method: http.MethodDelete, PathContains: testAccountName, Code: "AccountBusy", Message: "account is busy", Async: true})
+			err := service.DeleteAnfAccount(ctx, testResourceGroupName, testAccountName)
+			if err == nil || !strings.Contains(err.Error(), "AccountBusy") {
+				t.Fatalf("delete with a failing operation returned %v, want an AccountBusy error", err)
+			}
+			if !hasResource(backend, testAccountID) {
+				t.Fatalf("failed delete removed account %v", testAccountID)
+			}
+
+			if err := service.DeleteAnfAccount(ctx, testResourceGroupName, testAccountName); err != nil {
+				t.Fatalf("cannot delete account: %v", err)
+			}
+			if hasResource(backend, testAccountID) {
+				t.Fatalf("account %v was not deleted", testAccountID)
+			}
+		})
+	}
+}
+
+func TestServerFailures(t *testing.T) {
+
+	// attempts is the number of responses with the status code the SDK clients read before returning it,
+	// throttling and server side errors are retried by status code and conflicts by the resource provider registration check
+	tests := []struct {
+		statusCode int
+		code       string
+		attempts   int
+		transient  bool
+	}{
+		{statusCode: http.StatusTooManyRequests, code: "TooManyRequests", attempts: autorest.DefaultRetryAttempts + 1, transient: true},
+		{statusCode: http.StatusInternalServerError, code: "InternalServerError", attempts: autorest.DefaultRetryAttempts + 1, transient: true},
+		{statusCode: http.StatusConflict, code: "Conflict", attempts: autorest.DefaultRetryAttempts},
+	}
+
+	for _, test := range tests {
+		t.Run(test.code, func(t *testing.T) {
+
+			server, backend, clients := newTestServer(t)
+			service := sdkutils.NewService(clients)
+			accounts := clients.Accounts()
+			ctx := context.Background()
+
+			// Failures are retried by the SDK client until attempts are exhausted
+			server.AddFailure(Failure{Method: http.MethodPut, PathContains: testAccountName, StatusCode: test.statusCode, Code: test.code, Message: "injected", Times: test.attempts - 1})
+			if _, err := service.CreateAnfAccount(ctx, "westus", testResourceGroupName, testAccountName, nil, nil); err != nil {
+				t.Fatalf("create did not retry %v: %v", test.statusCode, err)
+			}
+			if !hasResource(backend, testAccountID) {
+				t.Fatalf("account %v was not created", testAccountID)
+			}
+
+			server.AddFailure(Failure{Method: http.MethodGet, PathContains: testAccountName, StatusCode: test.statusCode, Code: test.code, Message: "injected", Times: test.attempts})
+			_, err := accounts.Get(ctx, testResourceGroupName, testAccountName)
+			if got := statusCode(err); got != test.statusCode {
+				t.Fatalf("get returned %v with status code %v, want %v", err, got, test.statusCode)
+			}
+			if _, err := accounts.Get(ctx, testResourceGroupName, testAccountName); err != nil {
+				t.Fatalf("get after the injected failures returned %v", err)
+			}
+
+			// Waiting retries transient failures returned by the SDK client and stops on any other one
+			server.AddFailure(Failure{Method: http.MethodGet, PathContains: testAccountName, StatusCode: test.statusCode, Code: test.code, Message: "injected", Times: 2 * test.attempts})
+			err = service.WaitForANFResource(ctx, testAccountID, 1, 5, false)
+			if test.transient && err != nil {
+				t.Fatalf("wait did not retry %v: %v", test.statusCode, err)
+			}
+			if !test.transient && (err == nil || !strings.Contains(err.Error(), test.code)) {
+				t.Fatalf("wait returned %v, want a %v error", err, test.code)
+			}
+		})
+	}
+}
+
+func TestServerNotFound(t *testing.T) {
+
+	_, _, clients := newTestServer(t)
+	service := sdkutils.NewService(clients)
+
+	// Service methods wrap SDK errors, status code is still available to callers
+	_, err := service.GetAnfVolume(context.Background(), testResourceGroupName, testAccountName, "pool", "volume")
+	if !sdkutils.IsNotFoundError(err) {
+		t.Fatalf("get of a missing volume returned %v, want a not found error", err)
+	}
+
+	_, err = service.GetResourceByID(context.Background(), uri.SubnetID(testSubscriptionID, testResourceGroupName, "vnet", "subnet").String(), "2019-09-01")
+	if !sdkutils.IsNotFoundError(err) {
+		t.Fatalf("get of a missing subnet returned %v, want a not found error", err)
+	}
+}
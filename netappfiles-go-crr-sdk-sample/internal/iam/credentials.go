@@ -0,0 +1,384 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package iam
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/adal"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/Azure/go-autorest/autorest/azure/auth"
+	"github.com/Azure/go-autorest/autorest/azure/cli"
+	"github.com/Azure/go-autorest/autorest/to"
+)
+
+const (
+	// Environment variables read by the credentials, named like the Azure SDKs do
+	authLocationEnv              = "AZURE_AUTH_LOCATION"
+	tenantIDEnv                  = "AZURE_TENANT_ID"
+	clientIDEnv                  = "AZURE_CLIENT_ID"
+	clientSecretEnv              = "AZURE_CLIENT_SECRET"
+	clientCertificatePathEnv     = "AZURE_CLIENT_CERTIFICATE_PATH"
+	clientCertificatePasswordEnv = "AZURE_CLIENT_CERTIFICATE_PASSWORD"
+	federatedTokenFileEnv        = "AZURE_FEDERATED_TOKEN_FILE"
+	authorityHostEnv             = "AZURE_AUTHORITY_HOST"
+	subscriptionIDEnv            = "AZURE_SUBSCRIPTION_ID"
+	identityEndpointEnv          = "IDENTITY_ENDPOINT"
+	msiEndpointEnv               = "MSI_ENDPOINT"
+
+	// Environment variable that selects a single credential by name instead of the chain
+	credentialEnv = "ANF_CREDENTIAL"
+
+	clientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+)
+
+// Settings are the values read by the credentials, by default from the environment variables above
+type Settings struct {
+	Credential                string // Selects a single credential by name instead of the chain
+	AuthLocation              string
+	TenantID                  string
+	ClientID                  string
+	ClientSecret              string
+	ClientCertificatePath     string
+	ClientCertificatePassword string
+	FederatedTokenFile        string
+	SubscriptionID            string
+}
+
+// SettingsFromEnvironment returns the settings found in the environment variables
+func SettingsFromEnvironment() Settings {
+	return Settings{
+		Credential:                os.Getenv(credentialEnv),
+		AuthLocation:              os.Getenv(authLocationEnv),
+		TenantID:                  os.Getenv(tenantIDEnv),
+		ClientID:                  os.Getenv(clientIDEnv),
+		ClientSecret:              os.Getenv(clientSecretEnv),
+		ClientCertificatePath:     os.Getenv(clientCertificatePathEnv),
+		ClientCertificatePassword: os.Getenv(clientCertificatePasswordEnv),
+		FederatedTokenFile:        os.Getenv(federatedTokenFileEnv),
+		SubscriptionID:            os.Getenv(subscriptionIDEnv),
+	}
+}
+
+// Override returns the settings with the non empty values of overrides, e.g. the ones of a replication side
+func (s Settings) Override(overrides Settings) Settings {
+
+	for _, field := range []struct {
+		value    *string
+		override string
+	}{
+		{&s.Credential, overrides.Credential},
+		{&s.AuthLocation, overrides.AuthLocation},
+		{&s.TenantID, overrides.TenantID},
+		{&s.ClientID, overrides.ClientID},
+		{&s.ClientSecret, overrides.ClientSecret},
+		{&s.ClientCertificatePath, overrides.ClientCertificatePath},
+		{&s.ClientCertificatePassword, overrides.ClientCertificatePassword},
+		{&s.FederatedTokenFile, overrides.FederatedTokenFile},
+		{&s.SubscriptionID, overrides.SubscriptionID},
+	} {
+		if field.override != "" {
+			*field.value = field.override
+		}
+	}
+
+	return s
+}
+
+// Credential is a source of Azure AD tokens that can be part of a Chain
+type Credential interface {
+	// Name identifies the credential, it can be used in ANF_CREDENTIAL to select it
+	Name() string
+	// Configured checks if the settings have what the credential needs, without getting a token
+	Configured() bool
+	// Authorizer returns an authorizer for the token audience of a cloud and the subscription known by the credential, if any
+	Authorizer(environment azure.Environment) (autorest.Authorizer, string, error)
+}
+
+// Chain is a list of credentials in precedence order, the first configured one is used
+type Chain struct {
+	Credentials []Credential
+	Settings    Settings
+}
+
+// NewChain returns the credentials supported by this sample using the provided settings, in precedence order:
+// SDK authentication file, environment client secret, environment client certificate,
+// workload identity federation, managed identity and Azure CLI.
+func NewChain(settings Settings) Chain {
+	return Chain{
+		Credentials: []Credential{
+			authFileCredential{settings},
+			clientSecretCredential{settings},
+			clientCertificateCredential{settings},
+			workloadIdentityCredential{settings},
+			managedIdentityCredential{settings},
+			azureCLICredential{settings},
+		},
+		Settings: settings,
+	}
+}
+
+// DefaultChain returns the credentials supported by this sample using the settings of the environment variables
+func DefaultChain() Chain {
+	return NewChain(SettingsFromEnvironment())
+}
+
+// Authorizer returns the authorizer of the first configured credential, or of the credential named in the settings.
+// The subscription comes from the settings when set, otherwise from the credential.
+func (c Chain) Authorizer(environment azure.Environment) (autorest.Authorizer, string, error) {
+
+	credential, err := c.selectCredential(c.Settings.Credential)
+	if err != nil {
+		return nil, "", err
+	}
+
+	authorizer, subscriptionID, err := credential.Authorizer(environment)
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot get authorizer from %v credential: %v", credential.Name(), err)
+	}
+
+	if c.Settings.SubscriptionID != "" {
+		subscriptionID = c.Settings.SubscriptionID
+	}
+	if subscriptionID == "" {
+		return nil, "", fmt.Errorf("no subscription found for %v credential, set %v", credential.Name(), subscriptionIDEnv)
+	}
+
+	return authorizer, subscriptionID, nil
+}
+
+// selectCredential returns the credential with a name, or the first configured one if name is empty
+func (c Chain) selectCredential(name string) (Credential, error) {
+
+	var names []string
+	for _, credential := range c.Credentials {
+		names = append(names, credential.Name())
+		if name != "" && !strings.EqualFold(credential.Name(), name) {
+			continue
+		}
+		if credential.Configured() {
+			return credential, nil
+		}
+		if name != "" {
+			return nil, fmt.Errorf("%v credential is selected but not configured", credential.Name())
+		}
+	}
+
+	if name != "" {
+		return nil, fmt.Errorf("unknown credential %v, valid credentials are: %v", name, names)
+	}
+
+	return nil, fmt.Errorf("no credential configured, tried: %v", names)
+}
+
+// authFileCredential uses the file created by az ad sp create-for-rbac --sdk-auth, located by AZURE_AUTH_LOCATION
+type authFileCredential struct {
+	settings Settings
+}
+
+func (authFileCredential) Name() string {
+	return "file"
+}
+
+func (c authFileCredential) Configured() bool {
+	return c.settings.AuthLocation != ""
+}
+
+// Authorizer uses the service principal of the file against the cloud endpoints, when no cloud is selected
+// the caller is expected to build the environment from the endpoints of the file
+func (c authFileCredential) Authorizer(environment azure.Environment) (autorest.Authorizer, string, error) {
+
+	info, err := readAuthJSON(c.settings.AuthLocation)
+	if err != nil {
+		return nil, "", err
+	}
+	if info.ClientID == nil || info.ClientSecret == nil || info.TenantID == nil {
+		return nil, "", fmt.Errorf("clientId, clientSecret and tenantId are required in %v", c.settings.AuthLocation)
+	}
+
+	config := auth.NewClientCredentialsConfig(*info.ClientID, *info.ClientSecret, *info.TenantID)
+	config.Resource = environment.TokenAudience
+	config.AADEndpoint = activeDirectoryEndpoint(environment)
+
+	authorizer, err := config.Authorizer()
+	if err != nil {
+		return nil, "", err
+	}
+
+	return authorizer, to.String(info.SubscriptionID), nil
+}
+
+// clientSecretCredential uses a service principal secret from AZURE_TENANT_ID, AZURE_CLIENT_ID and AZURE_CLIENT_SECRET
+type clientSecretCredential struct {
+	settings Settings
+}
+
+func (clientSecretCredential) Name() string {
+	return "secret"
+}
+
+func (c clientSecretCredential) Configured() bool {
+	return c.settings.TenantID != "" && c.settings.ClientID != "" && c.settings.ClientSecret != ""
+}
+
+func (c clientSecretCredential) Authorizer(environment azure.Environment) (autorest.Authorizer, string, error) {
+
+	config := auth.NewClientCredentialsConfig(c.settings.ClientID, c.settings.ClientSecret, c.settings.TenantID)
+	config.Resource = environment.TokenAudience
+	config.AADEndpoint = activeDirectoryEndpoint(environment)
+
+	authorizer, err := config.Authorizer()
+	return authorizer, "", err
+}
+
+// clientCertificateCredential uses a service principal certificate from AZURE_TENANT_ID, AZURE_CLIENT_ID,
+// AZURE_CLIENT_CERTIFICATE_PATH and optionally AZURE_CLIENT_CERTIFICATE_PASSWORD, the certificate is a PKCS#12 file
+type clientCertificateCredential struct {
+	settings Settings
+}
+
+func (clientCertificateCredential) Name() string {
+	return "certificate"
+}
+
+func (c clientCertificateCredential) Configured() bool {
+	return c.settings.TenantID != "" && c.settings.ClientID != "" && c.settings.ClientCertificatePath != ""
+}
+
+func (c clientCertificateCredential) Authorizer(environment azure.Environment) (autorest.Authorizer, string, error) {
+
+	config := auth.NewClientCertificateConfig(c.settings.ClientCertificatePath, c.settings.ClientCertificatePassword, c.settings.ClientID, c.settings.TenantID)
+	config.Resource = environment.TokenAudience
+	config.AADEndpoint = activeDirectoryEndpoint(environment)
+
+	authorizer, err := config.Authorizer()
+	return authorizer, "", err
+}
+
+// workloadIdentityCredential exchanges a federated token, e.g. a Kubernetes service account token, from
+// AZURE_FEDERATED_TOKEN_FILE for an Azure AD token of the application in AZURE_CLIENT_ID and AZURE_TENANT_ID
+type workloadIdentityCredential struct {
+	settings Settings
+}
+
+func (workloadIdentityCredential) Name() string {
+	return "workloadidentity"
+}
+
+func (c workloadIdentityCredential) Configured() bool {
+	return c.settings.TenantID != "" && c.settings.ClientID != "" && c.settings.FederatedTokenFile != ""
+}
+
+func (c workloadIdentityCredential) Authorizer(environment azure.Environment) (autorest.Authorizer, string, error) {
+
+	oauthConfig, err := adal.NewOAuthConfig(activeDirectoryEndpoint(environment), c.settings.TenantID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	token, err := adal.NewServicePrincipalTokenWithSecret(*oauthConfig, c.settings.ClientID, environment.TokenAudience, federatedTokenSecret{path: c.settings.FederatedTokenFile})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return autorest.NewBearerAuthorizer(token), "", nil
+}
+
+// federatedTokenSecret sends the federated token as client assertion, the file is read on every
+// refresh because the token is rotated by the platform
+type federatedTokenSecret struct {
+	path string
+}
+
+func (s federatedTokenSecret) SetAuthenticationValues(spt *adal.ServicePrincipalToken, values *url.Values) error {
+
+	assertion, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("cannot read federated token file: %v", err)
+	}
+
+	values.Set("client_assertion_type", clientAssertionType)
+	values.Set("client_assertion", strings.TrimSpace(string(assertion)))
+	return nil
+}
+
+// managedIdentityCredential uses the managed identity of the host, the user assigned one in AZURE_CLIENT_ID if set.
+// It is configured when a managed identity endpoint is set in the environment, as App Service and Functions do, or when
+// it is selected by name, e.g. on a virtual machine using the instance metadata service, which is not probed.
+type managedIdentityCredential struct {
+	settings Settings
+}
+
+func (managedIdentityCredential) Name() string {
+	return "managedidentity"
+}
+
+func (c managedIdentityCredential) Configured() bool {
+	return os.Getenv(identityEndpointEnv) != "" || os.Getenv(msiEndpointEnv) != "" || strings.EqualFold(c.settings.Credential, c.Name())
+}
+
+func (c managedIdentityCredential) Authorizer(environment azure.Environment) (autorest.Authorizer, string, error) {
+
+	config := auth.NewMSIConfig()
+	config.Resource = environment.TokenAudience
+	config.ClientID = c.settings.ClientID
+
+	authorizer, err := config.Authorizer()
+	return authorizer, "", err
+}
+
+// azureCLICredential uses the account logged in with az login, along with its default subscription,
+// the Azure CLI must be set to the same cloud with az cloud set
+type azureCLICredential struct {
+	settings Settings
+}
+
+func (azureCLICredential) Name() string {
+	return "cli"
+}
+
+func (azureCLICredential) Configured() bool {
+	_, err := exec.LookPath("az")
+	return err == nil
+}
+
+func (azureCLICredential) Authorizer(environment azure.Environment) (autorest.Authorizer, string, error) {
+
+	authorizer, err := auth.NewAuthorizerFromCLIWithResource(environment.TokenAudience)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Default subscription is optional, AZURE_SUBSCRIPTION_ID can be used instead
+	var subscriptionID string
+	profilePath, err := cli.ProfilePath()
+	if err == nil {
+		profile, err := cli.LoadProfile(profilePath)
+		if err == nil {
+			for _, subscription := range profile.Subscriptions {
+				if subscription.IsDefault {
+					subscriptionID = subscription.ID
+				}
+			}
+		}
+	}
+
+	return authorizer, subscriptionID, nil
+}
+
+// activeDirectoryEndpoint returns the Azure AD endpoint from AZURE_AUTHORITY_HOST, defaults to the cloud one
+func activeDirectoryEndpoint(environment azure.Environment) string {
+	if authorityHost := os.Getenv(authorityHostEnv); authorityHost != "" {
+		return authorityHost
+	}
+	return environment.ActiveDirectoryEndpoint
+}
@@ -0,0 +1,216 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package iam
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest/azure"
+)
+
+// clearEnvironment unsets the environment variables read by the credentials, with an empty PATH the Azure CLI is not found
+func clearEnvironment(t *testing.T) {
+
+	t.Helper()
+
+	for _, name := range []string{identityEndpointEnv, msiEndpointEnv, authorityHostEnv, "PATH"} {
+		t.Setenv(name, "")
+	}
+}
+
+// installAzureCLI puts an az executable on the PATH
+func installAzureCLI(t *testing.T) {
+
+	t.Helper()
+
+	directory := t.TempDir()
+	err := ioutil.WriteFile(filepath.Join(directory, "az"), []byte("#!/bin/sh\n"), 0755)
+	if err != nil {
+		t.Fatalf("cannot write az: %v", err)
+	}
+	t.Setenv("PATH", directory)
+}
+
+// writeAuthFile writes an SDK authentication file
+func writeAuthFile(t *testing.T, content string) string {
+
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "azureauth.json")
+	err := ioutil.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		t.Fatalf("cannot write authentication file: %v", err)
+	}
+	return path
+}
+
+func TestNewChainOrder(t *testing.T) {
+
+	var names []string
+	for _, credential := range NewChain(Settings{}).Credentials {
+		names = append(names, credential.Name())
+	}
+
+	want := []string{"file", "secret", "certificate", "workloadidentity", "managedidentity", "cli"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("chain is %v, want %v", names, want)
+	}
+}
+
+func TestSelectCredential(t *testing.T) {
+
+	file := Settings{AuthLocation: "azureauth.json"}
+	secret := Settings{TenantID: "tenant", ClientID: "client", ClientSecret: "secret"}
+	certificate := Settings{TenantID: "tenant", ClientID: "client", ClientCertificatePath: "client.pfx"}
+	workloadIdentity := Settings{TenantID: "tenant", ClientID: "client", FederatedTokenFile: "token"}
+	all := file.Override(secret).Override(certificate).Override(workloadIdentity)
+
+	tests := []struct {
+		name        string
+		settings    Settings
+		environment map[string]string
+		azureCLI    bool
+		want        string
+		wantErr     string
+	}{
+		// The first configured credential of the chain is used
+		{name: "file first", settings: all, environment: map[string]string{identityEndpointEnv: "http://localhost"}, azureCLI: true, want: "file"},
+		{name: "secret before certificate", settings: secret.Override(certificate).Override(workloadIdentity), azureCLI: true, want: "secret"},
+		{name: "certificate before workload identity", settings: certificate.Override(workloadIdentity), azureCLI: true, want: "certificate"},
+		{name: "workload identity before managed identity", settings: workloadIdentity, environment: map[string]string{identityEndpointEnv: "http://localhost"}, azureCLI: true, want: "workloadidentity"},
+		{name: "managed identity endpoint", environment: map[string]string{identityEndpointEnv: "http://localhost"}, azureCLI: true, want: "managedidentity"},
+		{name: "msi endpoint", environment: map[string]string{msiEndpointEnv: "http://localhost"}, azureCLI: true, want: "managedidentity"},
+		{name: "azure cli last", settings: Settings{TenantID: "tenant", ClientID: "client"}, azureCLI: true, want: "cli"},
+		{name: "nothing configured", settings: Settings{TenantID: "tenant"}, wantErr: "no credential configured, tried: [file secret certificate workloadidentity managedidentity cli]"},
+
+		// A credential selected by name skips the ones before it
+		{name: "selected", settings: all.Override(Settings{Credential: "certificate"}), want: "certificate"},
+		{name: "selected ignores case", settings: all.Override(Settings{Credential: "CLI"}), azureCLI: true, want: "cli"},
+		{name: "managed identity selected without endpoint", settings: Settings{Credential: "managedidentity"}, want: "managedidentity"},
+		{name: "selected but not configured", settings: file.Override(Settings{Credential: "secret"}), wantErr: "secret credential is selected but not configured"},
+		{name: "selected cli not installed", settings: Settings{Credential: "cli"}, wantErr: "cli credential is selected but not configured"},
+		{name: "unknown", settings: all.Override(Settings{Credential: "browser"}), wantErr: "unknown credential browser"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			clearEnvironment(t)
+			for name, value := range test.environment {
+				t.Setenv(name, value)
+			}
+			if test.azureCLI {
+				installAzureCLI(t)
+			}
+
+			chain := NewChain(test.settings)
+			credential, err := chain.selectCredential(chain.Settings.Credential)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("selected %v, error %v, want %q", credential, err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("cannot select credential: %v", err)
+			}
+			if credential.Name() != test.want {
+				t.Errorf("selected %v, want %v", credential.Name(), test.want)
+			}
+		})
+	}
+}
+
+func TestSettingsOverride(t *testing.T) {
+
+	settings := Settings{Credential: "secret", TenantID: "tenant", ClientID: "client", ClientSecret: "secret", SubscriptionID: "subscription"}
+
+	// Empty values keep the settings, others replace them
+	got := settings.Override(Settings{ClientID: "secondary-client", SubscriptionID: "secondary-subscription"})
+
+	want := Settings{Credential: "secret", TenantID: "tenant", ClientID: "secondary-client", ClientSecret: "secret", SubscriptionID: "secondary-subscription"}
+	if got != want {
+		t.Errorf("overridden settings are %+v, want %+v", got, want)
+	}
+	if settings.ClientID != "client" {
+		t.Errorf("Override() changed the original settings")
+	}
+}
+
+func TestChainAuthorizer(t *testing.T) {
+
+	clearEnvironment(t)
+
+	authFile := writeAuthFile(t, `{"clientId": "client", "clientSecret": "secret", "tenantId": "tenant", "subscriptionId": "file-subscription"}`)
+	incompleteAuthFile := writeAuthFile(t, `{"clientId": "client", "tenantId": "tenant"}`)
+
+	tests := []struct {
+		name               string
+		settings           Settings
+		wantSubscriptionID string
+		wantErr            string
+	}{
+		{
+			name:               "subscription of the credential",
+			settings:           Settings{AuthLocation: authFile},
+			wantSubscriptionID: "file-subscription",
+		},
+		{
+			name:               "subscription of the settings first",
+			settings:           Settings{AuthLocation: authFile, SubscriptionID: "subscription"},
+			wantSubscriptionID: "subscription",
+		},
+		{
+			name:     "no subscription",
+			settings: Settings{TenantID: "tenant", ClientID: "client", ClientSecret: "secret"},
+			wantErr:  "no subscription found for secret credential, set AZURE_SUBSCRIPTION_ID",
+		},
+		{
+			name:     "credential error",
+			settings: Settings{AuthLocation: incompleteAuthFile, SubscriptionID: "subscription"},
+			wantErr:  "cannot get authorizer from file credential: clientId, clientSecret and tenantId are required",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			authorizer, subscriptionID, err := NewChain(test.settings).Authorizer(azure.PublicCloud)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("Authorizer() returned error %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authorizer() returned error: %v", err)
+			}
+			if authorizer == nil {
+				t.Errorf("Authorizer() returned no authorizer")
+			}
+			if subscriptionID != test.wantSubscriptionID {
+				t.Errorf("subscription is %v, want %v", subscriptionID, test.wantSubscriptionID)
+			}
+		})
+	}
+}
+
+func TestActiveDirectoryEndpoint(t *testing.T) {
+
+	clearEnvironment(t)
+	if got := activeDirectoryEndpoint(azure.USGovernmentCloud); got != azure.USGovernmentCloud.ActiveDirectoryEndpoint {
+		t.Errorf("endpoint is %v, want the one of the cloud %v", got, azure.USGovernmentCloud.ActiveDirectoryEndpoint)
+	}
+
+	t.Setenv(authorityHostEnv, "https://login.example.com/")
+	if got := activeDirectoryEndpoint(azure.USGovernmentCloud); got != "https://login.example.com/" {
+		t.Errorf("endpoint is %v, want the one of %v", got, authorityHostEnv)
+	}
+}
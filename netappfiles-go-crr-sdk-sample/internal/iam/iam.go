@@ -1,51 +1,46 @@
-// Copyright (c) Microsoft and contributors.  All rights reserved.
-//
-// This source code is licensed under the MIT license found in the
-// LICENSE file in the root directory of this source tree.
-
-// Sample package that is used to obtain an authorizer token
-// and to return unmarshall the Azure authentication file
-// created by az ad sp create create-for-rbac command-line
-// into an AzureAuthInfo object.
-
-package iam
-
-import (
-	"encoding/json"
-	"fmt"
-	"io/ioutil"
-	"os"
-
-	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/models"
-	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/utils"
-
-	"github.com/Azure/go-autorest/autorest"
-	"github.com/Azure/go-autorest/autorest/azure/auth"
-)
-
-// GetAuthorizer gets an authorization token to be used within ANF client
-func GetAuthorizer() (autorest.Authorizer, string, error) {
-
-	// Getting information from authentication file
-	info, err := readAuthJSON(os.Getenv("AZURE_AUTH_LOCATION"))
-
-	authorizer, err := auth.NewAuthorizerFromFile(*info.ResourceManagerEndpointURL)
-	if err != nil {
-		utils.ConsoleOutput(fmt.Sprintf("%v", err))
-		return nil, "", err
-	}
-
-	return authorizer, *info.SubscriptionID, nil
-}
-
-// readAuthJSON reads the Azure Authentication json file json file and unmarshals it.
-func readAuthJSON(path string) (*models.AzureAuthInfo, error) {
-	infoJSON, err := ioutil.ReadFile(path)
-	if err != nil {
-		utils.ConsoleOutput(fmt.Sprintf("failed to read file: %v", err))
-		return &models.AzureAuthInfo{}, err
-	}
-	var authInfo models.AzureAuthInfo
-	json.Unmarshal(infoJSON, &authInfo)
-	return &authInfo, nil
-}
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Sample package that is used to obtain an authorizer token
+// from a chain of credentials (SDK authentication file, environment
+// variables, workload identity, managed identity or Azure CLI) and
+// to unmarshall the Azure authentication file created by
+// az ad sp create create-for-rbac command-line into an AzureAuthInfo object.
+
+package iam
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/models"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/utils"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure"
+)
+
+// GetAuthorizer gets an authorization token for the cloud environment to be used within ANF client, along with
+// the subscription, from the first configured credential of the chain built with the provided settings
+func GetAuthorizer(environment azure.Environment, settings Settings) (autorest.Authorizer, string, error) {
+
+	return NewChain(settings).Authorizer(environment)
+}
+
+// readAuthJSON reads the Azure Authentication json file json file and unmarshals it.
+func readAuthJSON(path string) (*models.AzureAuthInfo, error) {
+	infoJSON, err := ioutil.ReadFile(path)
+	if err != nil {
+		utils.ConsoleOutput(fmt.Sprintf("failed to read file: %v", err))
+		return &models.AzureAuthInfo{}, err
+	}
+	var authInfo models.AzureAuthInfo
+	err = json.Unmarshal(infoJSON, &authInfo)
+	if err != nil {
+		return &models.AzureAuthInfo{}, fmt.Errorf("failed to parse authentication file: %v", err)
+	}
+	return &authInfo, nil
+}
@@ -1,30 +1,91 @@
-// Copyright (c) Microsoft and contributors.  All rights reserved.
-//
-// This source code is licensed under the MIT license found in the
-// LICENSE file in the root directory of this source tree.
-
-// Structs for objects used throughout this sample.
-
-package models
-
-// AzureAuthInfo object definition
-type AzureAuthInfo struct {
-	ClientID                       *string
-	ClientSecret                   *string
-	SubscriptionID                 *string
-	TenantID                       *string
-	ActiveDirectoryEndpointURL     *string
-	ResourceManagerEndpointURL     *string
-	ActiveDirectoryGraphResourceID *string
-	SQLManagementEndpointURL       *string
-	GalleryEndpointURL             *string
-	ManagementEndpointURL          *string
-}
-
-// AzureBasicInfo object definition
-type AzureBasicInfo struct {
-	SubscriptionID             *string
-	TenantID                   *string
-	ResourceManagerEndpointURL *string
-	ManagementEndpointURL      *string
-}
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Structs for objects used throughout this sample.
+
+package models
+
+// AzureAuthInfo object definition
+type AzureAuthInfo struct {
+	ClientID                       *string
+	ClientSecret                   *string
+	SubscriptionID                 *string
+	TenantID                       *string
+	ActiveDirectoryEndpointURL     *string
+	ResourceManagerEndpointURL     *string
+	ActiveDirectoryGraphResourceID *string
+	SQLManagementEndpointURL       *string
+	GalleryEndpointURL             *string
+	ManagementEndpointURL          *string
+}
+
+// AzureBasicInfo object definition
+type AzureBasicInfo struct {
+	SubscriptionID             *string
+	TenantID                   *string
+	ActiveDirectoryEndpointURL *string
+	ResourceManagerEndpointURL *string
+	ManagementEndpointURL      *string
+}
+
+// Properties - properties to be used when defining primary and secondary anf resources
+type Properties struct {
+	Location              string      `json:"location" yaml:"location"`
+	ResourceGroupName     string      `json:"resourceGroupName" yaml:"resourceGroupName"`
+	VnetResourceGroupName string      `json:"vnetResourceGroupName" yaml:"vnetResourceGroupName"`
+	VnetName              string      `json:"vnetName" yaml:"vnetName"`
+	SubnetName            string      `json:"subnetName" yaml:"subnetName"`
+	AnfAccountName        string      `json:"anfAccountName" yaml:"anfAccountName"`
+	CapacityPoolName      string      `json:"capacityPoolName" yaml:"capacityPoolName"`
+	VolumeName            string      `json:"volumeName" yaml:"volumeName"`
+	ServiceLevel          string      `json:"serviceLevel" yaml:"serviceLevel"`                         // Valid service levels are Standard, Premium and Ultra
+	SubscriptionID        string      `json:"subscriptionId,omitempty" yaml:"subscriptionId,omitempty"` // Subscription of this side, defaults to the one of the credential
+	Credential            *Credential `json:"credential,omitempty" yaml:"credential,omitempty"`         // Credential of this side, defaults to the one configured in the environment
+	VolumeID              string      `json:"-" yaml:"-"`                                               // This will be populated after resource is created
+	CapacityPoolID        string      `json:"-" yaml:"-"`                                               // This will be populated after resource is created
+	AccountID             string      `json:"-" yaml:"-"`                                               // This will be populated after resource is created
+	SnapshotPolicyID      string      `json:"-" yaml:"-"`                                               // This will be populated after resource is created
+}
+
+// Credential object definition, overrides the credential environment variables for one side, e.g. when it lives in another tenant.
+// Secrets are not kept in the topology file, they are read from the environment variables named here.
+type Credential struct {
+	Name                              string `json:"name,omitempty" yaml:"name,omitempty"` // Valid credentials are file, secret, certificate, workloadidentity, managedidentity and cli
+	AuthLocation                      string `json:"authLocation,omitempty" yaml:"authLocation,omitempty"`
+	TenantID                          string `json:"tenantId,omitempty" yaml:"tenantId,omitempty"`
+	ClientID                          string `json:"clientId,omitempty" yaml:"clientId,omitempty"`
+	ClientSecretVariable              string `json:"clientSecretVariable,omitempty" yaml:"clientSecretVariable,omitempty"`
+	ClientCertificatePath             string `json:"clientCertificatePath,omitempty" yaml:"clientCertificatePath,omitempty"`
+	ClientCertificatePasswordVariable string `json:"clientCertificatePasswordVariable,omitempty" yaml:"clientCertificatePasswordVariable,omitempty"`
+	FederatedTokenFile                string `json:"federatedTokenFile,omitempty" yaml:"federatedTokenFile,omitempty"`
+}
+
+// Topology object definition, describes both sides of a cross-region replication pair
+type Topology struct {
+	Primary             *Properties     `json:"primary" yaml:"primary"`
+	Secondary           *Properties     `json:"secondary" yaml:"secondary"`
+	ReplicationSchedule string          `json:"replicationSchedule,omitempty" yaml:"replicationSchedule,omitempty"` // Valid schedules are 10minutely, hourly and daily, defaults to hourly
+	RPOThreshold        string          `json:"rpoThreshold,omitempty" yaml:"rpoThreshold,omitempty"`               // Maximum replication lag before alerting, e.g. 90m, defaults to twice the replication schedule interval
+	SnapshotPolicy      *SnapshotPolicy `json:"snapshotPolicy,omitempty" yaml:"snapshotPolicy,omitempty"`           // Local snapshot schedules created on both accounts, none if not set
+}
+
+// SnapshotPolicy object definition, created with the same name on both accounts and attached to the source volume
+type SnapshotPolicy struct {
+	Name                string            `json:"name" yaml:"name"`
+	Hourly              *SnapshotSchedule `json:"hourly,omitempty" yaml:"hourly,omitempty"`
+	Daily               *SnapshotSchedule `json:"daily,omitempty" yaml:"daily,omitempty"`
+	Weekly              *SnapshotSchedule `json:"weekly,omitempty" yaml:"weekly,omitempty"`
+	Monthly             *SnapshotSchedule `json:"monthly,omitempty" yaml:"monthly,omitempty"`
+	AttachToDestination bool              `json:"attachToDestination,omitempty" yaml:"attachToDestination,omitempty"` // Also attaches the policy to the destination volume once failover makes it writable
+}
+
+// SnapshotSchedule object definition, hour is not used by hourly schedules, day only by weekly and daysOfMonth only by monthly ones
+type SnapshotSchedule struct {
+	SnapshotsToKeep int32  `json:"snapshotsToKeep" yaml:"snapshotsToKeep"`
+	Minute          int32  `json:"minute" yaml:"minute"`
+	Hour            int32  `json:"hour,omitempty" yaml:"hour,omitempty"`
+	Day             string `json:"day,omitempty" yaml:"day,omitempty"`                 // Comma separated week days, e.g. Monday,Friday
+	DaysOfMonth     string `json:"daysOfMonth,omitempty" yaml:"daysOfMonth,omitempty"` // Comma separated days of the month, e.g. 1,15
+}
@@ -0,0 +1,133 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// This package provides pluggable notifiers used to raise
+// replication lag alerts, alerts can be written to stdout,
+// posted to a webhook or appended to a file.
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// LagExceeded - alert raised when replication lag goes over threshold
+	LagExceeded string = "LagExceeded"
+	// LagUnknown - alert raised when replication lag cannot be worked out, e.g. no transfer was observed yet
+	LagUnknown string = "LagUnknown"
+	// ReplicationUnhealthy - alert raised when the replication is not healthy or its status cannot be retrieved
+	ReplicationUnhealthy string = "ReplicationUnhealthy"
+	// LagRecovered - alert raised when replication is healthy again and its lag goes back under threshold
+	LagRecovered string = "LagRecovered"
+)
+
+// Alert object definition
+type Alert struct {
+	Type         string    `json:"type"`
+	VolumeID     string    `json:"volumeId"`
+	Lag          string    `json:"lag"`
+	Threshold    string    `json:"threshold"`
+	LastTransfer time.Time `json:"lastTransfer"`
+	Message      string    `json:"message"`
+	Time         time.Time `json:"time"`
+}
+
+// Notifier sends alerts to a destination
+type Notifier interface {
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// StdoutNotifier writes alerts as json lines to stdout
+type StdoutNotifier struct{}
+
+// NewStdoutNotifier creates a notifier that writes to stdout
+func NewStdoutNotifier() *StdoutNotifier {
+	return &StdoutNotifier{}
+}
+
+// Notify writes an alert to stdout
+func (n *StdoutNotifier) Notify(ctx context.Context, alert Alert) error {
+	return json.NewEncoder(os.Stdout).Encode(alert)
+}
+
+// WebhookNotifier posts alerts as json to a webhook url
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookNotifier creates a notifier that posts to a webhook url
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:    url,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Notify posts an alert to the webhook url
+func (n *WebhookNotifier) Notify(ctx context.Context, alert Alert) error {
+
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert: %v", err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %v", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := n.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to post alert to webhook: %v", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("webhook returned status code %v", response.StatusCode)
+	}
+
+	return nil
+}
+
+// FileNotifier appends alerts as json lines to a file
+type FileNotifier struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileNotifier creates a notifier that appends to a file
+func NewFileNotifier(path string) *FileNotifier {
+	return &FileNotifier{path: path}
+}
+
+// Notify appends an alert to the file
+func (n *FileNotifier) Notify(ctx context.Context, alert Alert) error {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	file, err := os.OpenFile(n.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open alerts file: %v", err)
+	}
+	defer file.Close()
+
+	err = json.NewEncoder(file).Encode(alert)
+	if err != nil {
+		return fmt.Errorf("failed to write alert to file: %v", err)
+	}
+
+	return nil
+}
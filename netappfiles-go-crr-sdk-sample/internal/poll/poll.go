@@ -0,0 +1,135 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// This package provides a polling engine with exponential backoff
+// and jitter that honours context deadlines and cancellation, it is
+// shared by every wait operation of this sample.
+
+package poll
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+var (
+	// ErrAttemptsExceeded is returned when condition is not met within the allowed attempts
+	ErrAttemptsExceeded = errors.New("exceeded number of attempts")
+
+	// InitialWait is the wait before the first attempt of backoffs built by NewBackoff, tests lower it to run against the fake backend
+	InitialWait = 5 * time.Second
+)
+
+// Backoff object definition
+type Backoff struct {
+	Initial    time.Duration // Wait before the first attempt
+	Max        time.Duration // Maximum wait between attempts
+	Multiplier float64       // Factor applied to the wait after each attempt
+	Jitter     float64       // Random fraction (0 to 1) added or removed from each wait
+	Attempts   int           // Maximum number of attempts, 0 means until context is done
+}
+
+// ConditionFunc checks if polling is done. Returning an error stops polling with that error,
+// unless it is marked as transient with Retry.
+type ConditionFunc func(ctx context.Context) (done bool, err error)
+
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+// Retry marks an error as transient, polling continues and the error is reported if polling gives up
+func Retry(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &retryableError{err: err}
+}
+
+// NewBackoff returns a backoff starting at InitialWait, doubling up to maxInterval, for the provided number of attempts
+func NewBackoff(maxInterval time.Duration, attempts int) Backoff {
+
+	initial := InitialWait
+	if maxInterval < initial {
+		initial = maxInterval
+	}
+
+	return Backoff{
+		Initial:    initial,
+		Max:        maxInterval,
+		Multiplier: 2,
+		Jitter:     0.2,
+		Attempts:   attempts,
+	}
+}
+
+// Until waits and checks condition until it is done, it returns a non transient error,
+// attempts are exceeded or context is done
+func Until(ctx context.Context, backoff Backoff, condition ConditionFunc) error {
+
+	var lastErr error
+	wait := backoff.Initial
+
+	for attempt := 1; backoff.Attempts == 0 || attempt <= backoff.Attempts; attempt++ {
+
+		timer := time.NewTimer(withJitter(wait, backoff.Jitter))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return withLastError(ctx.Err(), lastErr)
+		case <-timer.C:
+		}
+
+		done, err := condition(ctx)
+		if done {
+			return nil
+		}
+		if err != nil {
+			var retryable *retryableError
+			if !errors.As(err, &retryable) {
+				return err
+			}
+			lastErr = retryable.err
+		}
+
+		wait = backoff.next(wait)
+	}
+
+	return withLastError(fmt.Errorf("%w: %v", ErrAttemptsExceeded, backoff.Attempts), lastErr)
+}
+
+// next returns the wait following a wait, grown by the multiplier up to the maximum wait
+func (b Backoff) next(wait time.Duration) time.Duration {
+	wait = time.Duration(float64(wait) * b.Multiplier)
+	if b.Max > 0 && wait > b.Max {
+		wait = b.Max
+	}
+	return wait
+}
+
+func withJitter(wait time.Duration, jitter float64) time.Duration {
+	if jitter <= 0 || wait <= 0 {
+		return wait
+	}
+	delta := (rand.Float64()*2 - 1) * jitter * float64(wait)
+	return wait + time.Duration(delta)
+}
+
+func withLastError(err, lastErr error) error {
+	if lastErr == nil {
+		return err
+	}
+	return fmt.Errorf("%w, last error: %v", err, lastErr)
+}
@@ -0,0 +1,167 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package poll
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackoffNext(t *testing.T) {
+
+	backoff := Backoff{Initial: time.Millisecond, Max: 10 * time.Millisecond, Multiplier: 2}
+
+	// Wait doubles until it reaches the maximum and stays there
+	want := []time.Duration{2, 4, 8, 10, 10}
+	wait := backoff.Initial
+	for i, expected := range want {
+		wait = backoff.next(wait)
+		if wait != expected*time.Millisecond {
+			t.Fatalf("wait %v is %v, want %v", i+1, wait, expected*time.Millisecond)
+		}
+	}
+
+	// Without maximum wait keeps growing
+	backoff.Max = 0
+	if wait = backoff.next(time.Second); wait != 2*time.Second {
+		t.Errorf("uncapped wait after 1s is %v, want 2s", wait)
+	}
+}
+
+func TestNewBackoff(t *testing.T) {
+
+	tests := []struct {
+		maxInterval time.Duration
+		initial     time.Duration
+	}{
+		{maxInterval: time.Minute, initial: InitialWait},
+		{maxInterval: time.Second, initial: time.Second},
+	}
+
+	for _, test := range tests {
+		backoff := NewBackoff(test.maxInterval, 3)
+		if backoff.Initial != test.initial || backoff.Max != test.maxInterval || backoff.Attempts != 3 {
+			t.Errorf("backoff for %v is %+v, want initial %v", test.maxInterval, backoff, test.initial)
+		}
+	}
+}
+
+func TestWithJitter(t *testing.T) {
+
+	wait := 100 * time.Millisecond
+	if got := withJitter(wait, 0); got != wait {
+		t.Errorf("wait without jitter is %v, want %v", got, wait)
+	}
+
+	// Jittered waits stay within the jitter fraction of the wait and are not all the same
+	seen := map[time.Duration]bool{}
+	for i := 0; i < 1000; i++ {
+		got := withJitter(wait, 0.2)
+		if got < 80*time.Millisecond || got > 120*time.Millisecond {
+			t.Fatalf("jittered wait %v is outside [80ms, 120ms]", got)
+		}
+		seen[got] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("jittered waits are all %v", wait)
+	}
+}
+
+func TestUntilWaitsBetweenAttempts(t *testing.T) {
+
+	backoff := Backoff{Initial: time.Millisecond, Max: 4 * time.Millisecond, Multiplier: 2, Attempts: 5}
+
+	var attempts []time.Time
+	start := time.Now()
+	err := Until(context.Background(), backoff, func(ctx context.Context) (bool, error) {
+		attempts = append(attempts, time.Now())
+		return len(attempts) == 5, nil
+	})
+	if err != nil {
+		t.Fatalf("polling returned %v", err)
+	}
+
+	// Timers never fire early, each attempt comes at least the backoff wait after the previous one
+	want := []time.Duration{1, 2, 4, 4, 4}
+	previous := start
+	for i, attempt := range attempts {
+		if gap := attempt.Sub(previous); gap < want[i]*time.Millisecond {
+			t.Errorf("attempt %v came %v after the previous one, want at least %v", i+1, gap, want[i]*time.Millisecond)
+		}
+		previous = attempt
+	}
+}
+
+func TestUntilAttemptsExceeded(t *testing.T) {
+
+	backoff := Backoff{Initial: time.Millisecond, Max: time.Millisecond, Multiplier: 2, Attempts: 3}
+
+	var calls int
+	err := Until(context.Background(), backoff, func(ctx context.Context) (bool, error) {
+		calls++
+		return false, Retry(errors.New("still provisioning"))
+	})
+	if !errors.Is(err, ErrAttemptsExceeded) {
+		t.Fatalf("polling returned %v, want %v", err, ErrAttemptsExceeded)
+	}
+	if !strings.Contains(err.Error(), "still provisioning") {
+		t.Errorf("error %q does not report the last transient error", err)
+	}
+	if calls != 3 {
+		t.Errorf("condition was checked %v times, want 3", calls)
+	}
+}
+
+func TestUntilStopsOnError(t *testing.T) {
+
+	backoff := Backoff{Initial: time.Millisecond, Multiplier: 2, Attempts: 5}
+	conditionErr := errors.New("forbidden")
+
+	var calls int
+	err := Until(context.Background(), backoff, func(ctx context.Context) (bool, error) {
+		calls++
+		return false, conditionErr
+	})
+	if !errors.Is(err, conditionErr) || calls != 1 {
+		t.Fatalf("polling returned %v after %v checks, want %v after 1 check", err, calls, conditionErr)
+	}
+}
+
+func TestUntilContextDone(t *testing.T) {
+
+	// Cancellation stops the wait before the first attempt
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	err := Until(ctx, Backoff{Initial: time.Hour, Multiplier: 2}, func(ctx context.Context) (bool, error) {
+		t.Errorf("condition checked after cancellation")
+		return true, nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("polling returned %v, want %v", err, context.Canceled)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("polling stopped %v after cancellation", elapsed)
+	}
+
+	// Deadline stops unlimited attempts and reports the last transient error
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err = Until(ctx, Backoff{Initial: time.Millisecond, Max: 2 * time.Millisecond, Multiplier: 2}, func(ctx context.Context) (bool, error) {
+		return false, Retry(errors.New("throttled"))
+	})
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "throttled") {
+		t.Fatalf("polling returned %v, want %v with the last transient error", err, context.DeadlineExceeded)
+	}
+}
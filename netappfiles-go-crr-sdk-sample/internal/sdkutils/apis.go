@@ -0,0 +1,446 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package sdkutils
+
+import (
+	"context"
+
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/netapp/mgmt/netapp"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/resources"
+)
+
+// ResourcesAPI is the generic resource operation used by this sample
+type ResourcesAPI interface {
+	Get(ctx context.Context, resourceGroupName, resourceProviderNamespace, parentResourcePath, resourceType, resourceName, APIVersion string) (resources.GenericResource, error)
+}
+
+// AccountsAPI are the ANF account operations used by this sample, long running operations return once completed
+type AccountsAPI interface {
+	CreateOrUpdate(ctx context.Context, body netapp.Account, resourceGroupName, accountName string) (netapp.Account, error)
+	Get(ctx context.Context, resourceGroupName, accountName string) (netapp.Account, error)
+	Delete(ctx context.Context, resourceGroupName, accountName string) error
+}
+
+// PoolsAPI are the ANF capacity pool operations used by this sample, long running operations return once completed
+type PoolsAPI interface {
+	CreateOrUpdate(ctx context.Context, body netapp.CapacityPool, resourceGroupName, accountName, poolName string) (netapp.CapacityPool, error)
+	Get(ctx context.Context, resourceGroupName, accountName, poolName string) (netapp.CapacityPool, error)
+	Delete(ctx context.Context, resourceGroupName, accountName, poolName string) error
+}
+
+// VolumesAPI are the ANF volume and replication operations used by this sample, long running operations return once completed
+type VolumesAPI interface {
+	CreateOrUpdate(ctx context.Context, body netapp.Volume, resourceGroupName, accountName, poolName, volumeName string) (netapp.Volume, error)
+	Get(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string) (netapp.Volume, error)
+	Update(ctx context.Context, body netapp.VolumePatch, resourceGroupName, accountName, poolName, volumeName string) (netapp.Volume, error)
+	Delete(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string) error
+	AuthorizeReplication(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string, body netapp.AuthorizeRequest) error
+	BreakReplication(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string, body *netapp.BreakReplicationRequest) error
+	ResyncReplication(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string) error
+	DeleteReplication(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string) error
+	ReplicationStatus(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string) (netapp.ReplicationStatus, error)
+	Revert(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string, body netapp.VolumeRevert) error
+}
+
+// SnapshotsAPI are the ANF snapshot operations used by this sample, long running operations return once completed
+type SnapshotsAPI interface {
+	Create(ctx context.Context, body netapp.Snapshot, resourceGroupName, accountName, poolName, volumeName, snapshotName string) (netapp.Snapshot, error)
+	Get(ctx context.Context, resourceGroupName, accountName, poolName, volumeName, snapshotName string) (netapp.Snapshot, error)
+	List(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string) ([]netapp.Snapshot, error)
+	Delete(ctx context.Context, resourceGroupName, accountName, poolName, volumeName, snapshotName string) error
+}
+
+// SnapshotPoliciesAPI are the ANF snapshot policy operations used by this sample, long running operations return once completed
+type SnapshotPoliciesAPI interface {
+	Create(ctx context.Context, body netapp.SnapshotPolicy, resourceGroupName, accountName, snapshotPolicyName string) (netapp.SnapshotPolicy, error)
+	Get(ctx context.Context, resourceGroupName, accountName, snapshotPolicyName string) (netapp.SnapshotPolicy, error)
+	List(ctx context.Context, resourceGroupName, accountName string) ([]netapp.SnapshotPolicy, error)
+	Update(ctx context.Context, body netapp.SnapshotPolicyPatch, resourceGroupName, accountName, snapshotPolicyName string) (netapp.SnapshotPolicy, error)
+	Delete(ctx context.Context, resourceGroupName, accountName, snapshotPolicyName string) error
+}
+
+// BackupPoliciesAPI are the ANF backup policy operations used by this sample, long running operations return once completed
+type BackupPoliciesAPI interface {
+	Create(ctx context.Context, body netapp.BackupPolicy, resourceGroupName, accountName, backupPolicyName string) (netapp.BackupPolicy, error)
+	Get(ctx context.Context, resourceGroupName, accountName, backupPolicyName string) (netapp.BackupPolicy, error)
+	List(ctx context.Context, resourceGroupName, accountName string) ([]netapp.BackupPolicy, error)
+	Update(ctx context.Context, body netapp.BackupPolicyPatch, resourceGroupName, accountName, backupPolicyName string) (netapp.BackupPolicy, error)
+	Delete(ctx context.Context, resourceGroupName, accountName, backupPolicyName string) error
+}
+
+// BackupsAPI are the ANF volume backup operations used by this sample, long running operations return once completed
+type BackupsAPI interface {
+	Create(ctx context.Context, body netapp.Backup, resourceGroupName, accountName, poolName, volumeName, backupName string) (netapp.Backup, error)
+	Get(ctx context.Context, resourceGroupName, accountName, poolName, volumeName, backupName string) (netapp.Backup, error)
+	List(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string) ([]netapp.Backup, error)
+	Update(ctx context.Context, body netapp.BackupPatch, resourceGroupName, accountName, poolName, volumeName, backupName string) (netapp.Backup, error)
+	Delete(ctx context.Context, resourceGroupName, accountName, poolName, volumeName, backupName string) error
+}
+
+// accountsClient implements AccountsAPI with the SDK client, waiting for long running operations
+type accountsClient struct {
+	netapp.AccountsClient
+}
+
+func (c accountsClient) CreateOrUpdate(ctx context.Context, body netapp.Account, resourceGroupName, accountName string) (netapp.Account, error) {
+
+	future, err := c.AccountsClient.CreateOrUpdate(ctx, body, resourceGroupName, accountName)
+	if err != nil {
+		return netapp.Account{}, err
+	}
+
+	err = future.WaitForCompletionRef(ctx, c.Client)
+	if err != nil {
+		return netapp.Account{}, err
+	}
+
+	return future.Result(c.AccountsClient)
+}
+
+func (c accountsClient) Delete(ctx context.Context, resourceGroupName, accountName string) error {
+
+	future, err := c.AccountsClient.Delete(ctx, resourceGroupName, accountName)
+	if err != nil {
+		return err
+	}
+
+	return future.WaitForCompletionRef(ctx, c.Client)
+}
+
+// poolsClient implements PoolsAPI with the SDK client, waiting for long running operations
+type poolsClient struct {
+	netapp.PoolsClient
+}
+
+func (c poolsClient) CreateOrUpdate(ctx context.Context, body netapp.CapacityPool, resourceGroupName, accountName, poolName string) (netapp.CapacityPool, error) {
+
+	future, err := c.PoolsClient.CreateOrUpdate(ctx, body, resourceGroupName, accountName, poolName)
+	if err != nil {
+		return netapp.CapacityPool{}, err
+	}
+
+	err = future.WaitForCompletionRef(ctx, c.Client)
+	if err != nil {
+		return netapp.CapacityPool{}, err
+	}
+
+	return future.Result(c.PoolsClient)
+}
+
+func (c poolsClient) Delete(ctx context.Context, resourceGroupName, accountName, poolName string) error {
+
+	future, err := c.PoolsClient.Delete(ctx, resourceGroupName, accountName, poolName)
+	if err != nil {
+		return err
+	}
+
+	return future.WaitForCompletionRef(ctx, c.Client)
+}
+
+// volumesClient implements VolumesAPI with the SDK client, waiting for long running operations
+type volumesClient struct {
+	netapp.VolumesClient
+}
+
+func (c volumesClient) CreateOrUpdate(ctx context.Context, body netapp.Volume, resourceGroupName, accountName, poolName, volumeName string) (netapp.Volume, error) {
+
+	future, err := c.VolumesClient.CreateOrUpdate(ctx, body, resourceGroupName, accountName, poolName, volumeName)
+	if err != nil {
+		return netapp.Volume{}, err
+	}
+
+	err = future.WaitForCompletionRef(ctx, c.Client)
+	if err != nil {
+		return netapp.Volume{}, err
+	}
+
+	return future.Result(c.VolumesClient)
+}
+
+func (c volumesClient) Update(ctx context.Context, body netapp.VolumePatch, resourceGroupName, accountName, poolName, volumeName string) (netapp.Volume, error) {
+
+	future, err := c.VolumesClient.Update(ctx, body, resourceGroupName, accountName, poolName, volumeName)
+	if err != nil {
+		return netapp.Volume{}, err
+	}
+
+	err = future.WaitForCompletionRef(ctx, c.Client)
+	if err != nil {
+		return netapp.Volume{}, err
+	}
+
+	return future.Result(c.VolumesClient)
+}
+
+func (c volumesClient) Delete(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string) error {
+
+	future, err := c.VolumesClient.Delete(ctx, resourceGroupName, accountName, poolName, volumeName)
+	if err != nil {
+		return err
+	}
+
+	return future.WaitForCompletionRef(ctx, c.Client)
+}
+
+func (c volumesClient) AuthorizeReplication(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string, body netapp.AuthorizeRequest) error {
+
+	future, err := c.VolumesClient.AuthorizeReplication(ctx, resourceGroupName, accountName, poolName, volumeName, body)
+	if err != nil {
+		return err
+	}
+
+	return future.WaitForCompletionRef(ctx, c.Client)
+}
+
+func (c volumesClient) BreakReplication(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string, body *netapp.BreakReplicationRequest) error {
+
+	future, err := c.VolumesClient.BreakReplication(ctx, resourceGroupName, accountName, poolName, volumeName, body)
+	if err != nil {
+		return err
+	}
+
+	return future.WaitForCompletionRef(ctx, c.Client)
+}
+
+func (c volumesClient) ResyncReplication(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string) error {
+
+	future, err := c.VolumesClient.ResyncReplication(ctx, resourceGroupName, accountName, poolName, volumeName)
+	if err != nil {
+		return err
+	}
+
+	return future.WaitForCompletionRef(ctx, c.Client)
+}
+
+func (c volumesClient) DeleteReplication(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string) error {
+
+	future, err := c.VolumesClient.DeleteReplication(ctx, resourceGroupName, accountName, poolName, volumeName)
+	if err != nil {
+		return err
+	}
+
+	return future.WaitForCompletionRef(ctx, c.Client)
+}
+
+func (c volumesClient) ReplicationStatus(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string) (netapp.ReplicationStatus, error) {
+	return c.VolumesClient.ReplicationStatusMethod(ctx, resourceGroupName, accountName, poolName, volumeName)
+}
+
+func (c volumesClient) Revert(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string, body netapp.VolumeRevert) error {
+
+	future, err := c.VolumesClient.Revert(ctx, resourceGroupName, accountName, poolName, volumeName, body)
+	if err != nil {
+		return err
+	}
+
+	return future.WaitForCompletionRef(ctx, c.Client)
+}
+
+// snapshotsClient implements SnapshotsAPI with the SDK client, waiting for long running operations
+type snapshotsClient struct {
+	netapp.SnapshotsClient
+}
+
+func (c snapshotsClient) Create(ctx context.Context, body netapp.Snapshot, resourceGroupName, accountName, poolName, volumeName, snapshotName string) (netapp.Snapshot, error) {
+
+	future, err := c.SnapshotsClient.Create(ctx, body, resourceGroupName, accountName, poolName, volumeName, snapshotName)
+	if err != nil {
+		return netapp.Snapshot{}, err
+	}
+
+	err = future.WaitForCompletionRef(ctx, c.Client)
+	if err != nil {
+		return netapp.Snapshot{}, err
+	}
+
+	return future.Result(c.SnapshotsClient)
+}
+
+func (c snapshotsClient) List(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string) ([]netapp.Snapshot, error) {
+
+	snapshotList, err := c.SnapshotsClient.List(ctx, resourceGroupName, accountName, poolName, volumeName)
+	if err != nil {
+		return nil, err
+	}
+
+	if snapshotList.Value == nil {
+		return []netapp.Snapshot{}, nil
+	}
+
+	return *snapshotList.Value, nil
+}
+
+func (c snapshotsClient) Delete(ctx context.Context, resourceGroupName, accountName, poolName, volumeName, snapshotName string) error {
+
+	future, err := c.SnapshotsClient.Delete(ctx, resourceGroupName, accountName, poolName, volumeName, snapshotName)
+	if err != nil {
+		return err
+	}
+
+	return future.WaitForCompletionRef(ctx, c.Client)
+}
+
+// snapshotPoliciesClient implements SnapshotPoliciesAPI with the SDK client, waiting for long running operations
+type snapshotPoliciesClient struct {
+	netapp.SnapshotPoliciesClient
+}
+
+func (c snapshotPoliciesClient) List(ctx context.Context, resourceGroupName, accountName string) ([]netapp.SnapshotPolicy, error) {
+
+	snapshotPolicyList, err := c.SnapshotPoliciesClient.List(ctx, resourceGroupName, accountName)
+	if err != nil {
+		return nil, err
+	}
+
+	if snapshotPolicyList.Value == nil {
+		return []netapp.SnapshotPolicy{}, nil
+	}
+
+	return *snapshotPolicyList.Value, nil
+}
+
+func (c snapshotPoliciesClient) Update(ctx context.Context, body netapp.SnapshotPolicyPatch, resourceGroupName, accountName, snapshotPolicyName string) (netapp.SnapshotPolicy, error) {
+
+	future, err := c.SnapshotPoliciesClient.Update(ctx, body, resourceGroupName, accountName, snapshotPolicyName)
+	if err != nil {
+		return netapp.SnapshotPolicy{}, err
+	}
+
+	err = future.WaitForCompletionRef(ctx, c.Client)
+	if err != nil {
+		return netapp.SnapshotPolicy{}, err
+	}
+
+	return future.Result(c.SnapshotPoliciesClient)
+}
+
+func (c snapshotPoliciesClient) Delete(ctx context.Context, resourceGroupName, accountName, snapshotPolicyName string) error {
+
+	future, err := c.SnapshotPoliciesClient.Delete(ctx, resourceGroupName, accountName, snapshotPolicyName)
+	if err != nil {
+		return err
+	}
+
+	return future.WaitForCompletionRef(ctx, c.Client)
+}
+
+// backupPoliciesClient implements BackupPoliciesAPI with the SDK client, waiting for long running operations
+type backupPoliciesClient struct {
+	netapp.BackupPoliciesClient
+}
+
+func (c backupPoliciesClient) Create(ctx context.Context, body netapp.BackupPolicy, resourceGroupName, accountName, backupPolicyName string) (netapp.BackupPolicy, error) {
+
+	future, err := c.BackupPoliciesClient.Create(ctx, resourceGroupName, accountName, backupPolicyName, body)
+	if err != nil {
+		return netapp.BackupPolicy{}, err
+	}
+
+	err = future.WaitForCompletionRef(ctx, c.Client)
+	if err != nil {
+		return netapp.BackupPolicy{}, err
+	}
+
+	return future.Result(c.BackupPoliciesClient)
+}
+
+func (c backupPoliciesClient) List(ctx context.Context, resourceGroupName, accountName string) ([]netapp.BackupPolicy, error) {
+
+	backupPolicyList, err := c.BackupPoliciesClient.List(ctx, resourceGroupName, accountName)
+	if err != nil {
+		return nil, err
+	}
+
+	if backupPolicyList.Value == nil {
+		return []netapp.BackupPolicy{}, nil
+	}
+
+	return *backupPolicyList.Value, nil
+}
+
+func (c backupPoliciesClient) Update(ctx context.Context, body netapp.BackupPolicyPatch, resourceGroupName, accountName, backupPolicyName string) (netapp.BackupPolicy, error) {
+
+	future, err := c.BackupPoliciesClient.Update(ctx, resourceGroupName, accountName, backupPolicyName, body)
+	if err != nil {
+		return netapp.BackupPolicy{}, err
+	}
+
+	err = future.WaitForCompletionRef(ctx, c.Client)
+	if err != nil {
+		return netapp.BackupPolicy{}, err
+	}
+
+	return future.Result(c.BackupPoliciesClient)
+}
+
+func (c backupPoliciesClient) Delete(ctx context.Context, resourceGroupName, accountName, backupPolicyName string) error {
+
+	future, err := c.BackupPoliciesClient.Delete(ctx, resourceGroupName, accountName, backupPolicyName)
+	if err != nil {
+		return err
+	}
+
+	return future.WaitForCompletionRef(ctx, c.Client)
+}
+
+// backupsClient implements BackupsAPI with the SDK client, waiting for long running operations
+type backupsClient struct {
+	netapp.BackupsClient
+}
+
+func (c backupsClient) Create(ctx context.Context, body netapp.Backup, resourceGroupName, accountName, poolName, volumeName, backupName string) (netapp.Backup, error) {
+
+	future, err := c.BackupsClient.Create(ctx, resourceGroupName, accountName, poolName, volumeName, backupName, body)
+	if err != nil {
+		return netapp.Backup{}, err
+	}
+
+	err = future.WaitForCompletionRef(ctx, c.Client)
+	if err != nil {
+		return netapp.Backup{}, err
+	}
+
+	return future.Result(c.BackupsClient)
+}
+
+func (c backupsClient) List(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string) ([]netapp.Backup, error) {
+
+	backupList, err := c.BackupsClient.List(ctx, resourceGroupName, accountName, poolName, volumeName)
+	if err != nil {
+		return nil, err
+	}
+
+	if backupList.Value == nil {
+		return []netapp.Backup{}, nil
+	}
+
+	return *backupList.Value, nil
+}
+
+func (c backupsClient) Update(ctx context.Context, body netapp.BackupPatch, resourceGroupName, accountName, poolName, volumeName, backupName string) (netapp.Backup, error) {
+
+	future, err := c.BackupsClient.Update(ctx, resourceGroupName, accountName, poolName, volumeName, backupName, &body)
+	if err != nil {
+		return netapp.Backup{}, err
+	}
+
+	err = future.WaitForCompletionRef(ctx, c.Client)
+	if err != nil {
+		return netapp.Backup{}, err
+	}
+
+	return future.Result(c.BackupsClient)
+}
+
+func (c backupsClient) Delete(ctx context.Context, resourceGroupName, accountName, poolName, volumeName, backupName string) error {
+
+	future, err := c.BackupsClient.Delete(ctx, resourceGroupName, accountName, poolName, volumeName, backupName)
+	if err != nil {
+		return err
+	}
+
+	return future.WaitForCompletionRef(ctx, c.Client)
+}
@@ -0,0 +1,122 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package sdkutils
+
+import (
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/netapp/mgmt/netapp"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/resources"
+	"github.com/Azure/go-autorest/autorest"
+)
+
+// RetryDuration is the base delay of SDK clients before retrying throttled requests and server side errors,
+// tests lower it to run against the fake ARM server
+var RetryDuration = autorest.DefaultRetryDuration
+
+// ClientFactory builds the ARM clients of a subscription once and shares them, along with a single
+// authorizer, across all SDK calls of this sample. Authorizers created by the iam package refresh
+// their tokens on their own before they expire, so clients can be kept for the whole execution.
+type ClientFactory struct {
+	subscriptionID   string
+	resources        resources.Client
+	accounts         netapp.AccountsClient
+	pools            netapp.PoolsClient
+	volumes          netapp.VolumesClient
+	snapshots        netapp.SnapshotsClient
+	snapshotPolicies netapp.SnapshotPoliciesClient
+	backupPolicies   netapp.BackupPoliciesClient
+	backups          netapp.BackupsClient
+}
+
+// NewClientFactory creates the clients of a subscription using the provided authorizer
+func NewClientFactory(authorizer autorest.Authorizer, subscriptionID string) *ClientFactory {
+	return NewClientFactoryWithBaseURI(netapp.DefaultBaseURI, authorizer, subscriptionID)
+}
+
+// NewClientFactoryWithBaseURI creates the clients of a subscription using the provided authorizer and
+// resource manager endpoint, e.g. a local fake ARM server. An empty base URI means the public cloud endpoint.
+func NewClientFactoryWithBaseURI(baseURI string, authorizer autorest.Authorizer, subscriptionID string) *ClientFactory {
+
+	baseURI = strings.TrimSuffix(baseURI, "/")
+	if baseURI == "" {
+		baseURI = netapp.DefaultBaseURI
+	}
+
+	factory := ClientFactory{
+		subscriptionID:   subscriptionID,
+		resources:        resources.NewClientWithBaseURI(baseURI, subscriptionID),
+		accounts:         netapp.NewAccountsClientWithBaseURI(baseURI, subscriptionID),
+		pools:            netapp.NewPoolsClientWithBaseURI(baseURI, subscriptionID),
+		volumes:          netapp.NewVolumesClientWithBaseURI(baseURI, subscriptionID),
+		snapshots:        netapp.NewSnapshotsClientWithBaseURI(baseURI, subscriptionID),
+		snapshotPolicies: netapp.NewSnapshotPoliciesClientWithBaseURI(baseURI, subscriptionID),
+		backupPolicies:   netapp.NewBackupPoliciesClientWithBaseURI(baseURI, subscriptionID),
+		backups:          netapp.NewBackupsClientWithBaseURI(baseURI, subscriptionID),
+	}
+
+	for _, client := range []*autorest.Client{
+		&factory.resources.Client,
+		&factory.accounts.Client,
+		&factory.pools.Client,
+		&factory.volumes.Client,
+		&factory.snapshots.Client,
+		&factory.snapshotPolicies.Client,
+		&factory.backupPolicies.Client,
+		&factory.backups.Client,
+	} {
+		client.Authorizer = authorizer
+		client.RetryDuration = RetryDuration
+		client.AddToUserAgent(userAgent)
+	}
+
+	return &factory
+}
+
+// SubscriptionID returns the subscription the clients were created for
+func (f *ClientFactory) SubscriptionID() string {
+	return f.subscriptionID
+}
+
+// Resources returns the generic resources client
+func (f *ClientFactory) Resources() resources.Client {
+	return f.resources
+}
+
+// Accounts returns the ANF accounts client
+func (f *ClientFactory) Accounts() netapp.AccountsClient {
+	return f.accounts
+}
+
+// Pools returns the ANF capacity pools client
+func (f *ClientFactory) Pools() netapp.PoolsClient {
+	return f.pools
+}
+
+// Volumes returns the ANF volumes client
+func (f *ClientFactory) Volumes() netapp.VolumesClient {
+	return f.volumes
+}
+
+// Snapshots returns the ANF snapshots client
+func (f *ClientFactory) Snapshots() netapp.SnapshotsClient {
+	return f.snapshots
+}
+
+// SnapshotPolicies returns the ANF snapshot policies client
+func (f *ClientFactory) SnapshotPolicies() netapp.SnapshotPoliciesClient {
+	return f.snapshotPolicies
+}
+
+// BackupPolicies returns the ANF backup policies client
+func (f *ClientFactory) BackupPolicies() netapp.BackupPoliciesClient {
+	return f.backupPolicies
+}
+
+// Backups returns the ANF backups client
+func (f *ClientFactory) Backups() netapp.BackupsClient {
+	return f.backups
+}
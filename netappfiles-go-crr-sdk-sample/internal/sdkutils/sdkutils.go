@@ -1,723 +1,1324 @@
-// Copyright (c) Microsoft and contributors.  All rights reserved.
-//
-// This source code is licensed under the MIT license found in the
-// LICENSE file in the root directory of this source tree.
-
-// This package centralizes any function that directly
-// using any of the Azure's (with exception of authentication related ones)
-// available SDK packages.
-
-package sdkutils
-
-import (
-	"context"
-	"fmt"
-	"strings"
-	"time"
-
-	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/iam"
-	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/uri"
-	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/utils"
-
-	"github.com/Azure/azure-sdk-for-go/profiles/latest/netapp/mgmt/netapp"
-	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/resources"
-	"github.com/Azure/go-autorest/autorest/to"
-)
-
-const (
-	userAgent = "anf-go-sdk-sample-agent"
-	nfsv3     = "NFSv3"
-	nfsv41    = "NFSv4.1"
-	cifs      = "CIFS"
-)
-
-var (
-	validProtocols = []string{nfsv3, nfsv41, cifs}
-)
-
-func validateAnfServiceLevel(serviceLevel string) (validatedServiceLevel netapp.ServiceLevel, err error) {
-
-	var svcLevel netapp.ServiceLevel
-
-	switch strings.ToLower(serviceLevel) {
-	case "ultra":
-		svcLevel = netapp.ServiceLevelUltra
-	case "premium":
-		svcLevel = netapp.ServiceLevelPremium
-	case "standard":
-		svcLevel = netapp.ServiceLevelStandard
-	default:
-		return "", fmt.Errorf("invalid service level, supported service levels are: %v", netapp.PossibleServiceLevelValues())
-	}
-
-	return svcLevel, nil
-}
-
-func getResourcesClient() (resources.Client, error) {
-
-	authorizer, subscriptionID, err := iam.GetAuthorizer()
-	if err != nil {
-		return resources.Client{}, err
-	}
-
-	client := resources.NewClient(subscriptionID)
-	client.Authorizer = authorizer
-	client.AddToUserAgent(userAgent)
-
-	return client, nil
-}
-
-func getAccountsClient() (netapp.AccountsClient, error) {
-
-	authorizer, subscriptionID, err := iam.GetAuthorizer()
-	if err != nil {
-		return netapp.AccountsClient{}, err
-	}
-
-	client := netapp.NewAccountsClient(subscriptionID)
-	client.Authorizer = authorizer
-	client.AddToUserAgent(userAgent)
-
-	return client, nil
-}
-
-func getPoolsClient() (netapp.PoolsClient, error) {
-
-	authorizer, subscriptionID, err := iam.GetAuthorizer()
-	if err != nil {
-		return netapp.PoolsClient{}, err
-	}
-
-	client := netapp.NewPoolsClient(subscriptionID)
-	client.Authorizer = authorizer
-	client.AddToUserAgent(userAgent)
-
-	return client, nil
-}
-
-func getVolumesClient() (netapp.VolumesClient, error) {
-
-	authorizer, subscriptionID, err := iam.GetAuthorizer()
-	if err != nil {
-		return netapp.VolumesClient{}, err
-	}
-
-	client := netapp.NewVolumesClient(subscriptionID)
-	client.Authorizer = authorizer
-	client.AddToUserAgent(userAgent)
-
-	return client, nil
-}
-
-func getSnapshotsClient() (netapp.SnapshotsClient, error) {
-
-	authorizer, subscriptionID, err := iam.GetAuthorizer()
-	if err != nil {
-		return netapp.SnapshotsClient{}, err
-	}
-
-	client := netapp.NewSnapshotsClient(subscriptionID)
-	client.Authorizer = authorizer
-	client.AddToUserAgent(userAgent)
-
-	return client, nil
-}
-
-// GetResourceByID gets a generic resource
-func GetResourceByID(ctx context.Context, resourceID, APIVersion string) (resources.GenericResource, error) {
-
-	resourcesClient, err := getResourcesClient()
-	if err != nil {
-		return resources.GenericResource{}, err
-	}
-
-	parentResource := ""
-	resourceGroup := uri.GetResourceGroup(resourceID)
-	resourceProvider := uri.GetResourceValue(resourceID, "providers")
-	resourceName := uri.GetResourceName(resourceID)
-	resourceType := uri.GetResourceValue(resourceID, resourceProvider)
-
-	if strings.Contains(resourceID, "/subnets/") {
-		parentResourceName := uri.GetResourceValue(resourceID, resourceType)
-		parentResource = fmt.Sprintf("%v/%v", resourceType, parentResourceName)
-		resourceType = "subnets"
-	}
-
-	return resourcesClient.Get(
-		ctx,
-		resourceGroup,
-		resourceProvider,
-		parentResource,
-		resourceType,
-		resourceName,
-		APIVersion,
-	)
-}
-
-// CreateAnfAccount creates an ANF Account resource
-func CreateAnfAccount(ctx context.Context, location, resourceGroupName, accountName string, activeDirectories []netapp.ActiveDirectory, tags map[string]*string) (netapp.Account, error) {
-
-	accountClient, err := getAccountsClient()
-	if err != nil {
-		return netapp.Account{}, err
-	}
-
-	accountProperties := netapp.AccountProperties{}
-
-	if activeDirectories != nil {
-		accountProperties = netapp.AccountProperties{
-			ActiveDirectories: &activeDirectories,
-		}
-	}
-
-	future, err := accountClient.CreateOrUpdate(
-		ctx,
-		netapp.Account{
-			Location:          to.StringPtr(location),
-			Tags:              tags,
-			AccountProperties: &accountProperties,
-		},
-		resourceGroupName,
-		accountName,
-	)
-	if err != nil {
-		return netapp.Account{}, fmt.Errorf("cannot create account: %v", err)
-	}
-
-	err = future.WaitForCompletionRef(ctx, accountClient.Client)
-	if err != nil {
-		return netapp.Account{}, fmt.Errorf("cannot get the account create or update future response: %v", err)
-	}
-
-	return future.Result(accountClient)
-}
-
-// CreateAnfCapacityPool creates an ANF Capacity Pool within ANF Account
-func CreateAnfCapacityPool(ctx context.Context, location, resourceGroupName, accountName, poolName, serviceLevel string, sizeBytes int64, tags map[string]*string) (netapp.CapacityPool, error) {
-
-	poolClient, err := getPoolsClient()
-	if err != nil {
-		return netapp.CapacityPool{}, err
-	}
-
-	svcLevel, err := validateAnfServiceLevel(serviceLevel)
-	if err != nil {
-		return netapp.CapacityPool{}, err
-	}
-
-	future, err := poolClient.CreateOrUpdate(
-		ctx,
-		netapp.CapacityPool{
-			Location: to.StringPtr(location),
-			Tags:     tags,
-			PoolProperties: &netapp.PoolProperties{
-				ServiceLevel: svcLevel,
-				Size:         to.Int64Ptr(sizeBytes),
-			},
-		},
-		resourceGroupName,
-		accountName,
-		poolName,
-	)
-
-	if err != nil {
-		return netapp.CapacityPool{}, fmt.Errorf("cannot create pool: %v", err)
-	}
-
-	err = future.WaitForCompletionRef(ctx, poolClient.Client)
-	if err != nil {
-		return netapp.CapacityPool{}, fmt.Errorf("cannot get the pool create or update future response: %v", err)
-	}
-
-	return future.Result(poolClient)
-}
-
-// CreateAnfVolume creates an ANF volume within a Capacity Pool
-func CreateAnfVolume(ctx context.Context, location, resourceGroupName, accountName, poolName, volumeName, serviceLevel, subnetID, snapshotID string, protocolTypes []string, volumeUsageQuota int64, unixReadOnly, unixReadWrite bool, tags map[string]*string, dataProtectionObject netapp.VolumePropertiesDataProtection) (netapp.Volume, error) {
-
-	_, found := utils.FindInSlice(validProtocols, protocolTypes[0])
-	if !found {
-		return netapp.Volume{}, fmt.Errorf("invalid protocol type, valid protocol types are: %v", validProtocols)
-	}
-
-	svcLevel, err := validateAnfServiceLevel(serviceLevel)
-	if err != nil {
-		return netapp.Volume{}, err
-	}
-
-	volumeClient, err := getVolumesClient()
-	if err != nil {
-		return netapp.Volume{}, err
-	}
-
-	exportPolicy := netapp.VolumePropertiesExportPolicy{}
-
-	if _, found := utils.FindInSlice(protocolTypes, cifs); !found {
-		exportPolicy = netapp.VolumePropertiesExportPolicy{
-			Rules: &[]netapp.ExportPolicyRule{
-				{
-					AllowedClients: to.StringPtr("0.0.0.0/0"),
-					Cifs:           to.BoolPtr(map[bool]bool{true: true, false: false}[protocolTypes[0] == cifs]),
-					Nfsv3:          to.BoolPtr(map[bool]bool{true: true, false: false}[protocolTypes[0] == nfsv3]),
-					Nfsv41:         to.BoolPtr(map[bool]bool{true: true, false: false}[protocolTypes[0] == nfsv41]),
-					RuleIndex:      to.Int32Ptr(1),
-					UnixReadOnly:   to.BoolPtr(unixReadOnly),
-					UnixReadWrite:  to.BoolPtr(unixReadWrite),
-				},
-			},
-		}
-	}
-
-	var volumeType string
-	emptyDataProtection := netapp.VolumePropertiesDataProtection{}
-	if dataProtectionObject != emptyDataProtection {
-		volumeType = "DataProtection"
-	}
-
-	volumeProperties := netapp.VolumeProperties{
-		SnapshotID:     map[bool]*string{true: to.StringPtr(snapshotID), false: nil}[snapshotID != ""],
-		ExportPolicy:   map[bool]*netapp.VolumePropertiesExportPolicy{true: &exportPolicy, false: nil}[protocolTypes[0] != cifs],
-		ProtocolTypes:  &protocolTypes,
-		ServiceLevel:   svcLevel,
-		SubnetID:       to.StringPtr(subnetID),
-		UsageThreshold: to.Int64Ptr(volumeUsageQuota),
-		CreationToken:  to.StringPtr(volumeName),
-		DataProtection: &dataProtectionObject,
-		VolumeType:     &volumeType,
-	}
-
-	future, err := volumeClient.CreateOrUpdate(
-		ctx,
-		netapp.Volume{
-			Location:         to.StringPtr(location),
-			Tags:             tags,
-			VolumeProperties: &volumeProperties,
-		},
-		resourceGroupName,
-		accountName,
-		poolName,
-		volumeName,
-	)
-
-	if err != nil {
-		return netapp.Volume{}, fmt.Errorf("cannot create volume: %v", err)
-	}
-
-	err = future.WaitForCompletionRef(ctx, volumeClient.Client)
-	if err != nil {
-		return netapp.Volume{}, fmt.Errorf("cannot get the volume create or update future response: %v", err)
-	}
-
-	return future.Result(volumeClient)
-}
-
-// UpdateAnfVolume update an ANF volume
-func UpdateAnfVolume(ctx context.Context, location, resourceGroupName, accountName, poolName, volumeName string, volumePropertiesPatch netapp.VolumePatchProperties, tags map[string]*string) (netapp.VolumesUpdateFuture, error) {
-
-	volumeClient, err := getVolumesClient()
-	if err != nil {
-		return netapp.VolumesUpdateFuture{}, err
-	}
-
-	volume, err := volumeClient.Update(
-		ctx,
-		netapp.VolumePatch{
-			Location:              to.StringPtr(location),
-			Tags:                  tags,
-			VolumePatchProperties: &volumePropertiesPatch,
-		},
-		resourceGroupName,
-		accountName,
-		poolName,
-		volumeName,
-	)
-
-	if err != nil {
-		return netapp.VolumesUpdateFuture{}, fmt.Errorf("cannot update volume: %v", err)
-	}
-
-	return volume, nil
-}
-
-// AuthorizeReplication - authorizes volume replication
-func AuthorizeReplication(ctx context.Context, resourceGroupName, accountName, poolName, volumeName, remoteVolumeResourceID string) error {
-
-	volumeClient, err := getVolumesClient()
-	if err != nil {
-		return err
-	}
-
-	future, err := volumeClient.AuthorizeReplication(
-		ctx,
-		resourceGroupName,
-		accountName,
-		poolName,
-		volumeName,
-		netapp.AuthorizeRequest{
-			RemoteVolumeResourceID: to.StringPtr(remoteVolumeResourceID),
-		},
-	)
-
-	if err != nil {
-		return fmt.Errorf("cannot authorize volume replication: %v", err)
-	}
-
-	err = future.WaitForCompletionRef(ctx, volumeClient.Client)
-	if err != nil {
-		return fmt.Errorf("cannot get authorize volume replication future response: %v", err)
-	}
-
-	return nil
-}
-
-// BreakAnfVolumeReplication - breaks volume replication
-func BreakAnfVolumeReplication(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string) error {
-
-	volumeClient, err := getVolumesClient()
-	if err != nil {
-		return err
-	}
-
-	future, err := volumeClient.BreakReplication(
-		ctx,
-		resourceGroupName,
-		accountName,
-		poolName,
-		volumeName,
-		&netapp.BreakReplicationRequest{},
-	)
-
-	if err != nil {
-		return fmt.Errorf("cannot break volume replication: %v", err)
-	}
-
-	err = future.WaitForCompletionRef(ctx, volumeClient.Client)
-	if err != nil {
-		return fmt.Errorf("cannot get break volume replication future response: %v", err)
-	}
-
-	return nil
-}
-
-// DeleteAnfVolumeReplication - authorizes volume replication
-func DeleteAnfVolumeReplication(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string) error {
-
-	volumeClient, err := getVolumesClient()
-	if err != nil {
-		return err
-	}
-
-	future, err := volumeClient.DeleteReplication(
-		ctx,
-		resourceGroupName,
-		accountName,
-		poolName,
-		volumeName,
-	)
-
-	if err != nil {
-		return fmt.Errorf("cannot delete volume replication: %v", err)
-	}
-
-	err = future.WaitForCompletionRef(ctx, volumeClient.Client)
-	if err != nil {
-		return fmt.Errorf("cannot get delete volume replication future response: %v", err)
-	}
-
-	return nil
-}
-
-// CreateAnfSnapshot creates a Snapshot from an ANF volume
-func CreateAnfSnapshot(ctx context.Context, location, resourceGroupName, accountName, poolName, volumeName, snapshotName string, tags map[string]*string) (netapp.Snapshot, error) {
-
-	snapshotClient, err := getSnapshotsClient()
-	if err != nil {
-		return netapp.Snapshot{}, err
-	}
-
-	future, err := snapshotClient.Create(
-		ctx,
-		netapp.Snapshot{
-			Location: to.StringPtr(location),
-		},
-		resourceGroupName,
-		accountName,
-		poolName,
-		volumeName,
-		snapshotName,
-	)
-
-	if err != nil {
-		return netapp.Snapshot{}, fmt.Errorf("cannot create snapshot: %v", err)
-	}
-
-	err = future.WaitForCompletionRef(ctx, snapshotClient.Client)
-	if err != nil {
-		return netapp.Snapshot{}, fmt.Errorf("cannot get the snapshot create or update future response: %v", err)
-	}
-
-	return future.Result(snapshotClient)
-}
-
-// DeleteAnfSnapshot deletes a Snapshot from an ANF volume
-func DeleteAnfSnapshot(ctx context.Context, resourceGroupName, accountName, poolName, volumeName, snapshotName string) error {
-
-	snapshotClient, err := getSnapshotsClient()
-	if err != nil {
-		return err
-	}
-
-	future, err := snapshotClient.Delete(
-		ctx,
-		resourceGroupName,
-		accountName,
-		poolName,
-		volumeName,
-		snapshotName,
-	)
-
-	if err != nil {
-		return fmt.Errorf("cannot delete snapshot: %v", err)
-	}
-
-	err = future.WaitForCompletionRef(ctx, snapshotClient.Client)
-	if err != nil {
-		return fmt.Errorf("cannot get the snapshot delete future response: %v", err)
-	}
-
-	return nil
-}
-
-// DeleteAnfVolume deletes a volume
-func DeleteAnfVolume(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string) error {
-
-	volumesClient, err := getVolumesClient()
-	if err != nil {
-		return err
-	}
-
-	future, err := volumesClient.Delete(
-		ctx,
-		resourceGroupName,
-		accountName,
-		poolName,
-		volumeName,
-	)
-
-	if err != nil {
-		return fmt.Errorf("cannot delete volume: %v", err)
-	}
-
-	err = future.WaitForCompletionRef(ctx, volumesClient.Client)
-	if err != nil {
-		return fmt.Errorf("cannot get the volume delete future response: %v", err)
-	}
-
-	return nil
-}
-
-// DeleteAnfCapacityPool deletes a capacity pool
-func DeleteAnfCapacityPool(ctx context.Context, resourceGroupName, accountName, poolName string) error {
-
-	poolsClient, err := getPoolsClient()
-	if err != nil {
-		return err
-	}
-
-	future, err := poolsClient.Delete(
-		ctx,
-		resourceGroupName,
-		accountName,
-		poolName,
-	)
-
-	if err != nil {
-		return fmt.Errorf("cannot delete capacity pool: %v", err)
-	}
-
-	err = future.WaitForCompletionRef(ctx, poolsClient.Client)
-	if err != nil {
-		return fmt.Errorf("cannot get the capacity pool delete future response: %v", err)
-	}
-
-	return nil
-}
-
-// DeleteAnfAccount deletes an account
-func DeleteAnfAccount(ctx context.Context, resourceGroupName, accountName string) error {
-
-	accountsClient, err := getAccountsClient()
-	if err != nil {
-		return err
-	}
-
-	future, err := accountsClient.Delete(
-		ctx,
-		resourceGroupName,
-		accountName,
-	)
-
-	if err != nil {
-		return fmt.Errorf("cannot delete account: %v", err)
-	}
-
-	err = future.WaitForCompletionRef(ctx, accountsClient.Client)
-	if err != nil {
-		return fmt.Errorf("cannot get the account delete future response: %v", err)
-	}
-
-	return nil
-}
-
-// WaitForNoANFResource waits for a specified resource to don't exist anymore following a deletion.
-// This is due to a known issue related to ARM Cache where the state of the resource is still cached within ARM infrastructure
-// reporting that it still exists so looping into a get process will return 404 as soon as the cached state expires
-func WaitForNoANFResource(ctx context.Context, resourceID string, intervalInSec int, retries int, checkForReplication bool) error {
-
-	var err error
-
-	for i := 0; i < retries; i++ {
-		time.Sleep(time.Duration(intervalInSec) * time.Second)
-		if uri.IsAnfSnapshot(resourceID) {
-			client, _ := getSnapshotsClient()
-			_, err = client.Get(
-				ctx,
-				uri.GetResourceGroup(resourceID),
-				uri.GetAnfAccount(resourceID),
-				uri.GetAnfCapacityPool(resourceID),
-				uri.GetAnfVolume(resourceID),
-				uri.GetAnfSnapshot(resourceID),
-			)
-		} else if uri.IsAnfVolume(resourceID) {
-			client, _ := getVolumesClient()
-			if !checkForReplication {
-				_, err = client.Get(
-					ctx,
-					uri.GetResourceGroup(resourceID),
-					uri.GetAnfAccount(resourceID),
-					uri.GetAnfCapacityPool(resourceID),
-					uri.GetAnfVolume(resourceID),
-				)
-			} else {
-				_, err = client.ReplicationStatusMethod(
-					ctx,
-					uri.GetResourceGroup(resourceID),
-					uri.GetAnfAccount(resourceID),
-					uri.GetAnfCapacityPool(resourceID),
-					uri.GetAnfVolume(resourceID),
-				)
-			}
-		} else if uri.IsAnfCapacityPool(resourceID) {
-			client, _ := getPoolsClient()
-			_, err = client.Get(
-				ctx,
-				uri.GetResourceGroup(resourceID),
-				uri.GetAnfAccount(resourceID),
-				uri.GetAnfCapacityPool(resourceID),
-			)
-		} else if uri.IsAnfAccount(resourceID) {
-			client, _ := getAccountsClient()
-			_, err = client.Get(
-				ctx,
-				uri.GetResourceGroup(resourceID),
-				uri.GetAnfAccount(resourceID),
-			)
-		}
-
-		// In this case error is expected
-		if err != nil {
-			return nil
-		}
-	}
-
-	return fmt.Errorf("exceeded number of retries: %v", retries)
-}
-
-// WaitForANFResource waits for a specified resource to be fully ready following a creation operation.
-func WaitForANFResource(ctx context.Context, resourceID string, intervalInSec int, retries int, checkForReplication bool) error {
-
-	var err error
-
-	for i := 0; i < retries; i++ {
-		time.Sleep(time.Duration(intervalInSec) * time.Second)
-		if uri.IsAnfSnapshot(resourceID) {
-			client, _ := getSnapshotsClient()
-			_, err = client.Get(
-				ctx,
-				uri.GetResourceGroup(resourceID),
-				uri.GetAnfAccount(resourceID),
-				uri.GetAnfCapacityPool(resourceID),
-				uri.GetAnfVolume(resourceID),
-				uri.GetAnfSnapshot(resourceID),
-			)
-		} else if uri.IsAnfVolume(resourceID) {
-			client, _ := getVolumesClient()
-			if !checkForReplication {
-				_, err = client.Get(
-					ctx,
-					uri.GetResourceGroup(resourceID),
-					uri.GetAnfAccount(resourceID),
-					uri.GetAnfCapacityPool(resourceID),
-					uri.GetAnfVolume(resourceID),
-				)
-			} else {
-				_, err = client.ReplicationStatusMethod(
-					ctx,
-					uri.GetResourceGroup(resourceID),
-					uri.GetAnfAccount(resourceID),
-					uri.GetAnfCapacityPool(resourceID),
-					uri.GetAnfVolume(resourceID),
-				)
-			}
-		} else if uri.IsAnfCapacityPool(resourceID) {
-			client, _ := getPoolsClient()
-			_, err = client.Get(
-				ctx,
-				uri.GetResourceGroup(resourceID),
-				uri.GetAnfAccount(resourceID),
-				uri.GetAnfCapacityPool(resourceID),
-			)
-		} else if uri.IsAnfAccount(resourceID) {
-			client, _ := getAccountsClient()
-			_, err = client.Get(
-				ctx,
-				uri.GetResourceGroup(resourceID),
-				uri.GetAnfAccount(resourceID),
-			)
-		}
-
-		// In this case, we exit when there is no error
-		if err == nil {
-			return nil
-		}
-	}
-
-	return fmt.Errorf("resource still not found after number of retries: %v, error: %v", retries, err)
-}
-
-// WaitForMirrorState waits for a volume to have a particular mirror state
-func WaitForMirrorState(ctx context.Context, volumeID string, anticipatedMirrorState netapp.MirrorState, intervalInSec int, retries int) error {
-
-	var err error
-	var currentReplicationStatus netapp.ReplicationStatus
-
-	client, _ := getVolumesClient()
-
-	for i := 0; i < retries; i++ {
-		time.Sleep(time.Duration(intervalInSec) * time.Second)
-
-		currentReplicationStatus, err = client.ReplicationStatusMethod(
-			ctx,
-			uri.GetResourceGroup(volumeID),
-			uri.GetAnfAccount(volumeID),
-			uri.GetAnfCapacityPool(volumeID),
-			uri.GetAnfVolume(volumeID),
-		)
-		if currentReplicationStatus.MirrorState == anticipatedMirrorState {
-			break
-		}
-	}
-
-	return fmt.Errorf("volume still not at %v state after number of retries: %v, error: %v", anticipatedMirrorState, retries, err)
-
-}
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// This package centralizes any function that directly
+// using any of the Azure's (with exception of authentication related ones)
+// available SDK packages.
+
+package sdkutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/poll"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/uri"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/utils"
+
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/netapp/mgmt/netapp"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/resources"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/Azure/go-autorest/autorest/to"
+)
+
+const (
+	userAgent = "anf-go-sdk-sample-agent"
+	nfsv3     = "NFSv3"
+	nfsv41    = "NFSv4.1"
+	cifs      = "CIFS"
+
+	provisioningStateSucceeded = "Succeeded"
+	provisioningStateFailed    = "Failed"
+
+	// Service error code of replication operations on a volume without replication
+	replicationMissingCode = "VolumeReplicationMissing"
+)
+
+var (
+	validProtocols = []string{nfsv3, nfsv41, cifs}
+)
+
+func validateAnfServiceLevel(serviceLevel string) (validatedServiceLevel netapp.ServiceLevel, err error) {
+
+	var svcLevel netapp.ServiceLevel
+
+	switch strings.ToLower(serviceLevel) {
+	case "ultra":
+		svcLevel = netapp.ServiceLevelUltra
+	case "premium":
+		svcLevel = netapp.ServiceLevelPremium
+	case "standard":
+		svcLevel = netapp.ServiceLevelStandard
+	default:
+		return "", fmt.Errorf("invalid service level, supported service levels are: %v", netapp.PossibleServiceLevelValues())
+	}
+
+	return svcLevel, nil
+}
+
+func validateReplicationSchedule(replicationSchedule string) (validatedReplicationSchedule netapp.ReplicationSchedule, err error) {
+
+	for _, schedule := range netapp.PossibleReplicationScheduleValues() {
+		if strings.EqualFold(strings.TrimPrefix(string(schedule), "_"), strings.TrimPrefix(replicationSchedule, "_")) {
+			return schedule, nil
+		}
+	}
+
+	return "", fmt.Errorf("invalid replication schedule, supported replication schedules are: %v", netapp.PossibleReplicationScheduleValues())
+}
+
+// Service performs the Azure operations used by this sample through narrow APIs,
+// so the real SDK clients can be replaced by other implementations (e.g. in-memory fakes)
+type Service struct {
+	Resources        ResourcesAPI
+	Accounts         AccountsAPI
+	Pools            PoolsAPI
+	Volumes          VolumesAPI
+	Snapshots        SnapshotsAPI
+	SnapshotPolicies SnapshotPoliciesAPI
+	BackupPolicies   BackupPoliciesAPI
+	Backups          BackupsAPI
+}
+
+// NewService creates a service backed by the SDK clients of a client factory
+func NewService(clients *ClientFactory) *Service {
+	return &Service{
+		Resources:        clients.Resources(),
+		Accounts:         accountsClient{clients.Accounts()},
+		Pools:            poolsClient{clients.Pools()},
+		Volumes:          volumesClient{clients.Volumes()},
+		Snapshots:        snapshotsClient{clients.Snapshots()},
+		SnapshotPolicies: snapshotPoliciesClient{clients.SnapshotPolicies()},
+		BackupPolicies:   backupPoliciesClient{clients.BackupPolicies()},
+		Backups:          backupsClient{clients.Backups()},
+	}
+}
+
+// GetResourceByID gets a generic resource
+func (s *Service) GetResourceByID(ctx context.Context, resourceID, APIVersion string) (resources.GenericResource, error) {
+
+	resourcesClient := s.Resources
+
+	id, err := uri.ParseResourceID(resourceID)
+	if err != nil {
+		return resources.GenericResource{}, err
+	}
+	if id.Provider == "" {
+		return resources.GenericResource{}, fmt.Errorf("resource id %v has no resource provider", resourceID)
+	}
+
+	// Child resources, e.g. subnets, are retrieved with the path of their parent
+	var parentResource []string
+	for i := 0; i < len(id.Types)-1; i++ {
+		parentResource = append(parentResource, id.Types[i], id.Names[i])
+	}
+
+	return resourcesClient.Get(
+		ctx,
+		id.ResourceGroupName,
+		id.Provider,
+		strings.Join(parentResource, "/"),
+		id.Types[len(id.Types)-1],
+		id.Name(),
+		APIVersion,
+	)
+}
+
+// CreateAnfAccount creates an ANF Account resource
+func (s *Service) CreateAnfAccount(ctx context.Context, location, resourceGroupName, accountName string, activeDirectories []netapp.ActiveDirectory, tags map[string]*string) (netapp.Account, error) {
+
+	accountClient := s.Accounts
+
+	accountProperties := netapp.AccountProperties{}
+
+	if activeDirectories != nil {
+		accountProperties = netapp.AccountProperties{
+			ActiveDirectories: &activeDirectories,
+		}
+	}
+
+	result, err := accountClient.CreateOrUpdate(
+		ctx,
+		netapp.Account{
+			Location:          to.StringPtr(location),
+			Tags:              tags,
+			AccountProperties: &accountProperties,
+		},
+		resourceGroupName,
+		accountName,
+	)
+	if err != nil {
+		return netapp.Account{}, fmt.Errorf("cannot create account: %w", err)
+	}
+
+	return result, nil
+}
+
+// CreateAnfCapacityPool creates an ANF Capacity Pool within ANF Account
+func (s *Service) CreateAnfCapacityPool(ctx context.Context, location, resourceGroupName, accountName, poolName, serviceLevel string, sizeBytes int64, tags map[string]*string) (netapp.CapacityPool, error) {
+
+	poolClient := s.Pools
+
+	svcLevel, err := validateAnfServiceLevel(serviceLevel)
+	if err != nil {
+		return netapp.CapacityPool{}, err
+	}
+
+	result, err := poolClient.CreateOrUpdate(
+		ctx,
+		netapp.CapacityPool{
+			Location: to.StringPtr(location),
+			Tags:     tags,
+			PoolProperties: &netapp.PoolProperties{
+				ServiceLevel: svcLevel,
+				Size:         to.Int64Ptr(sizeBytes),
+			},
+		},
+		resourceGroupName,
+		accountName,
+		poolName,
+	)
+
+	if err != nil {
+		return netapp.CapacityPool{}, fmt.Errorf("cannot create pool: %w", err)
+	}
+
+	return result, nil
+}
+
+// CreateAnfVolume creates an ANF volume within a Capacity Pool
+func (s *Service) CreateAnfVolume(ctx context.Context, location, resourceGroupName, accountName, poolName, volumeName, serviceLevel, subnetID, snapshotID string, protocolTypes []string, volumeUsageQuota int64, unixReadOnly, unixReadWrite bool, tags map[string]*string, dataProtectionObject netapp.VolumePropertiesDataProtection) (netapp.Volume, error) {
+
+	_, found := utils.FindInSlice(validProtocols, protocolTypes[0])
+	if !found {
+		return netapp.Volume{}, fmt.Errorf("invalid protocol type, valid protocol types are: %v", validProtocols)
+	}
+
+	svcLevel, err := validateAnfServiceLevel(serviceLevel)
+	if err != nil {
+		return netapp.Volume{}, err
+	}
+
+	volumeClient := s.Volumes
+
+	exportPolicy := netapp.VolumePropertiesExportPolicy{}
+
+	if _, found := utils.FindInSlice(protocolTypes, cifs); !found {
+		exportPolicy = netapp.VolumePropertiesExportPolicy{
+			Rules: &[]netapp.ExportPolicyRule{
+				{
+					AllowedClients: to.StringPtr("0.0.0.0/0"),
+					Cifs:           to.BoolPtr(map[bool]bool{true: true, false: false}[protocolTypes[0] == cifs]),
+					Nfsv3:          to.BoolPtr(map[bool]bool{true: true, false: false}[protocolTypes[0] == nfsv3]),
+					Nfsv41:         to.BoolPtr(map[bool]bool{true: true, false: false}[protocolTypes[0] == nfsv41]),
+					RuleIndex:      to.Int32Ptr(1),
+					UnixReadOnly:   to.BoolPtr(unixReadOnly),
+					UnixReadWrite:  to.BoolPtr(unixReadWrite),
+				},
+			},
+		}
+	}
+
+	// Only replication destinations are data protection volumes, snapshot or backup policies alone keep a regular volume
+	var volumeType string
+	if dataProtectionObject.Replication != nil {
+		volumeType = "DataProtection"
+	}
+
+	volumeProperties := netapp.VolumeProperties{
+		SnapshotID:     map[bool]*string{true: to.StringPtr(snapshotID), false: nil}[snapshotID != ""],
+		ExportPolicy:   map[bool]*netapp.VolumePropertiesExportPolicy{true: &exportPolicy, false: nil}[protocolTypes[0] != cifs],
+		ProtocolTypes:  &protocolTypes,
+		ServiceLevel:   svcLevel,
+		SubnetID:       to.StringPtr(subnetID),
+		UsageThreshold: to.Int64Ptr(volumeUsageQuota),
+		CreationToken:  to.StringPtr(volumeName),
+		DataProtection: &dataProtectionObject,
+		VolumeType:     &volumeType,
+	}
+
+	result, err := volumeClient.CreateOrUpdate(
+		ctx,
+		netapp.Volume{
+			Location:         to.StringPtr(location),
+			Tags:             tags,
+			VolumeProperties: &volumeProperties,
+		},
+		resourceGroupName,
+		accountName,
+		poolName,
+		volumeName,
+	)
+
+	if err != nil {
+		return netapp.Volume{}, fmt.Errorf("cannot create volume: %w", err)
+	}
+
+	return result, nil
+}
+
+// GetAnfVolume gets an ANF volume
+func (s *Service) GetAnfVolume(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string) (netapp.Volume, error) {
+
+	volumeClient := s.Volumes
+
+	volume, err := volumeClient.Get(
+		ctx,
+		resourceGroupName,
+		accountName,
+		poolName,
+		volumeName,
+	)
+
+	if err != nil {
+		return netapp.Volume{}, fmt.Errorf("cannot get volume: %w", err)
+	}
+
+	return volume, nil
+}
+
+// UpdateAnfVolume update an ANF volume
+func (s *Service) UpdateAnfVolume(ctx context.Context, location, resourceGroupName, accountName, poolName, volumeName string, volumePropertiesPatch netapp.VolumePatchProperties, tags map[string]*string) (netapp.Volume, error) {
+
+	volumeClient := s.Volumes
+
+	volume, err := volumeClient.Update(
+		ctx,
+		netapp.VolumePatch{
+			Location:              to.StringPtr(location),
+			Tags:                  tags,
+			VolumePatchProperties: &volumePropertiesPatch,
+		},
+		resourceGroupName,
+		accountName,
+		poolName,
+		volumeName,
+	)
+
+	if err != nil {
+		return netapp.Volume{}, fmt.Errorf("cannot update volume: %w", err)
+	}
+
+	return volume, nil
+}
+
+// SetAnfVolumeSnapshotPolicy attaches a snapshot policy to an ANF volume, an empty policy id detaches the current one
+func (s *Service) SetAnfVolumeSnapshotPolicy(ctx context.Context, location, resourceGroupName, accountName, poolName, volumeName, snapshotPolicyID string) (netapp.Volume, error) {
+
+	volumeClient := s.Volumes
+
+	volume, err := volumeClient.Update(
+		ctx,
+		netapp.VolumePatch{
+			Location: to.StringPtr(location),
+			VolumePatchProperties: &netapp.VolumePatchProperties{
+				DataProtection: &netapp.VolumePatchPropertiesDataProtection{
+					Snapshot: &netapp.VolumeSnapshotProperties{
+						SnapshotPolicyID: to.StringPtr(snapshotPolicyID),
+					},
+				},
+			},
+		},
+		resourceGroupName,
+		accountName,
+		poolName,
+		volumeName,
+	)
+
+	if err != nil {
+		return netapp.Volume{}, fmt.Errorf("cannot set volume snapshot policy: %w", err)
+	}
+
+	return volume, nil
+}
+
+// GetReplicationDataProtectionObject - builds the data protection object of a destination volume replicating from a remote volume
+func GetReplicationDataProtectionObject(remoteVolumeRegion, remoteVolumeResourceID, replicationSchedule string) (netapp.VolumePropertiesDataProtection, error) {
+
+	schedule, err := validateReplicationSchedule(replicationSchedule)
+	if err != nil {
+		return netapp.VolumePropertiesDataProtection{}, err
+	}
+
+	return netapp.VolumePropertiesDataProtection{
+		Replication: &netapp.ReplicationObject{
+			EndpointType:           netapp.EndpointTypeDst,
+			RemoteVolumeRegion:     to.StringPtr(remoteVolumeRegion),
+			RemoteVolumeResourceID: to.StringPtr(remoteVolumeResourceID),
+			ReplicationSchedule:    schedule,
+		},
+	}, nil
+}
+
+// UpdateAnfVolumeReplicationSchedule - changes the replication schedule of a destination volume.
+// Volume patch model of this API version has no replication properties, so the volume is read and submitted again with the new schedule.
+func (s *Service) UpdateAnfVolumeReplicationSchedule(ctx context.Context, resourceGroupName, accountName, poolName, volumeName, replicationSchedule string) (netapp.Volume, error) {
+
+	schedule, err := validateReplicationSchedule(replicationSchedule)
+	if err != nil {
+		return netapp.Volume{}, err
+	}
+
+	volumeClient := s.Volumes
+
+	volume, err := volumeClient.Get(
+		ctx,
+		resourceGroupName,
+		accountName,
+		poolName,
+		volumeName,
+	)
+	if err != nil {
+		return netapp.Volume{}, fmt.Errorf("cannot get volume: %w", err)
+	}
+
+	if volume.VolumeProperties == nil || volume.DataProtection == nil || volume.DataProtection.Replication == nil || volume.DataProtection.Replication.EndpointType != netapp.EndpointTypeDst {
+		return netapp.Volume{}, fmt.Errorf("volume %v is not a replication destination volume", volumeName)
+	}
+
+	volume.DataProtection.Replication.ReplicationSchedule = schedule
+
+	result, err := volumeClient.CreateOrUpdate(
+		ctx,
+		volume,
+		resourceGroupName,
+		accountName,
+		poolName,
+		volumeName,
+	)
+	if err != nil {
+		return netapp.Volume{}, fmt.Errorf("cannot update volume replication schedule: %w", err)
+	}
+
+	return result, nil
+}
+
+// AuthorizeReplication - authorizes volume replication
+func (s *Service) AuthorizeReplication(ctx context.Context, resourceGroupName, accountName, poolName, volumeName, remoteVolumeResourceID string) error {
+
+	volumeClient := s.Volumes
+
+	err := volumeClient.AuthorizeReplication(
+		ctx,
+		resourceGroupName,
+		accountName,
+		poolName,
+		volumeName,
+		netapp.AuthorizeRequest{
+			RemoteVolumeResourceID: to.StringPtr(remoteVolumeResourceID),
+		},
+	)
+
+	if err != nil {
+		return fmt.Errorf("cannot authorize volume replication: %w", err)
+	}
+
+	return nil
+}
+
+// BreakAnfVolumeReplication - breaks volume replication
+func (s *Service) BreakAnfVolumeReplication(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string) error {
+
+	volumeClient := s.Volumes
+
+	err := volumeClient.BreakReplication(
+		ctx,
+		resourceGroupName,
+		accountName,
+		poolName,
+		volumeName,
+		&netapp.BreakReplicationRequest{},
+	)
+
+	if err != nil {
+		return fmt.Errorf("cannot break volume replication: %w", err)
+	}
+
+	return nil
+}
+
+// RevertAnfVolume - reverts a volume to one of its snapshots, identified by its snapshot id (UUID), snapshots newer than it are deleted
+func (s *Service) RevertAnfVolume(ctx context.Context, resourceGroupName, accountName, poolName, volumeName, snapshotID string) error {
+
+	volumeClient := s.Volumes
+
+	err := volumeClient.Revert(
+		ctx,
+		resourceGroupName,
+		accountName,
+		poolName,
+		volumeName,
+		netapp.VolumeRevert{
+			SnapshotID: to.StringPtr(snapshotID),
+		},
+	)
+
+	if err != nil {
+		return fmt.Errorf("cannot revert volume: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteAnfVolumeReplication - authorizes volume replication
+func (s *Service) DeleteAnfVolumeReplication(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string) error {
+
+	volumeClient := s.Volumes
+
+	err := volumeClient.DeleteReplication(
+		ctx,
+		resourceGroupName,
+		accountName,
+		poolName,
+		volumeName,
+	)
+
+	if err != nil {
+		return fmt.Errorf("cannot delete volume replication: %w", err)
+	}
+
+	return nil
+}
+
+// ResyncAnfVolumeReplication - resyncs volume replication, if executed on the source volume it reverse-resyncs from destination to source
+func (s *Service) ResyncAnfVolumeReplication(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string) error {
+
+	volumeClient := s.Volumes
+
+	err := volumeClient.ResyncReplication(
+		ctx,
+		resourceGroupName,
+		accountName,
+		poolName,
+		volumeName,
+	)
+
+	if err != nil {
+		return fmt.Errorf("cannot resync volume replication: %w", err)
+	}
+
+	return nil
+}
+
+// GetAnfVolumeReplicationStatus - gets the replication status of a volume
+func (s *Service) GetAnfVolumeReplicationStatus(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string) (netapp.ReplicationStatus, error) {
+
+	volumeClient := s.Volumes
+
+	replicationStatus, err := volumeClient.ReplicationStatus(
+		ctx,
+		resourceGroupName,
+		accountName,
+		poolName,
+		volumeName,
+	)
+
+	if err != nil {
+		return netapp.ReplicationStatus{}, fmt.Errorf("cannot get volume replication status: %w", err)
+	}
+
+	return replicationStatus, nil
+}
+
+// CreateAnfSnapshot creates a Snapshot from an ANF volume
+func (s *Service) CreateAnfSnapshot(ctx context.Context, location, resourceGroupName, accountName, poolName, volumeName, snapshotName string, tags map[string]*string) (netapp.Snapshot, error) {
+
+	snapshotClient := s.Snapshots
+
+	result, err := snapshotClient.Create(
+		ctx,
+		netapp.Snapshot{
+			Location: to.StringPtr(location),
+		},
+		resourceGroupName,
+		accountName,
+		poolName,
+		volumeName,
+		snapshotName,
+	)
+
+	if err != nil {
+		return netapp.Snapshot{}, fmt.Errorf("cannot create snapshot: %w", err)
+	}
+
+	return result, nil
+}
+
+// ListAnfSnapshots lists Snapshots of an ANF volume
+func (s *Service) ListAnfSnapshots(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string) ([]netapp.Snapshot, error) {
+
+	snapshotClient := s.Snapshots
+
+	snapshotList, err := snapshotClient.List(
+		ctx,
+		resourceGroupName,
+		accountName,
+		poolName,
+		volumeName,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("cannot list snapshots: %w", err)
+	}
+
+	return snapshotList, nil
+}
+
+// DeleteAnfSnapshot deletes a Snapshot from an ANF volume
+func (s *Service) DeleteAnfSnapshot(ctx context.Context, resourceGroupName, accountName, poolName, volumeName, snapshotName string) error {
+
+	snapshotClient := s.Snapshots
+
+	err := snapshotClient.Delete(
+		ctx,
+		resourceGroupName,
+		accountName,
+		poolName,
+		volumeName,
+		snapshotName,
+	)
+
+	if err != nil {
+		return fmt.Errorf("cannot delete snapshot: %w", err)
+	}
+
+	return nil
+}
+
+// CreateAnfSnapshotPolicy creates a snapshot policy within an ANF Account, schedules without snapshots to keep are not taken
+func (s *Service) CreateAnfSnapshotPolicy(ctx context.Context, location, resourceGroupName, accountName, snapshotPolicyName string, policyProperties netapp.SnapshotPolicyProperties, tags map[string]*string) (netapp.SnapshotPolicy, error) {
+
+	snapshotPolicyClient := s.SnapshotPolicies
+
+	result, err := snapshotPolicyClient.Create(
+		ctx,
+		netapp.SnapshotPolicy{
+			Location:                 to.StringPtr(location),
+			Tags:                     tags,
+			SnapshotPolicyProperties: &policyProperties,
+		},
+		resourceGroupName,
+		accountName,
+		snapshotPolicyName,
+	)
+
+	if err != nil {
+		return netapp.SnapshotPolicy{}, fmt.Errorf("cannot create snapshot policy: %w", err)
+	}
+
+	return result, nil
+}
+
+// GetAnfSnapshotPolicy gets a snapshot policy
+func (s *Service) GetAnfSnapshotPolicy(ctx context.Context, resourceGroupName, accountName, snapshotPolicyName string) (netapp.SnapshotPolicy, error) {
+
+	snapshotPolicyClient := s.SnapshotPolicies
+
+	snapshotPolicy, err := snapshotPolicyClient.Get(
+		ctx,
+		resourceGroupName,
+		accountName,
+		snapshotPolicyName,
+	)
+
+	if err != nil {
+		return netapp.SnapshotPolicy{}, fmt.Errorf("cannot get snapshot policy: %w", err)
+	}
+
+	return snapshotPolicy, nil
+}
+
+// ListAnfSnapshotPolicies lists snapshot policies of an ANF Account
+func (s *Service) ListAnfSnapshotPolicies(ctx context.Context, resourceGroupName, accountName string) ([]netapp.SnapshotPolicy, error) {
+
+	snapshotPolicyClient := s.SnapshotPolicies
+
+	snapshotPolicyList, err := snapshotPolicyClient.List(
+		ctx,
+		resourceGroupName,
+		accountName,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("cannot list snapshot policies: %w", err)
+	}
+
+	return snapshotPolicyList, nil
+}
+
+// UpdateAnfSnapshotPolicy updates the schedules of a snapshot policy or enables/disables it
+func (s *Service) UpdateAnfSnapshotPolicy(ctx context.Context, location, resourceGroupName, accountName, snapshotPolicyName string, policyProperties netapp.SnapshotPolicyProperties, tags map[string]*string) (netapp.SnapshotPolicy, error) {
+
+	snapshotPolicyClient := s.SnapshotPolicies
+
+	snapshotPolicy, err := snapshotPolicyClient.Update(
+		ctx,
+		netapp.SnapshotPolicyPatch{
+			Location:                 to.StringPtr(location),
+			Tags:                     tags,
+			SnapshotPolicyProperties: &policyProperties,
+		},
+		resourceGroupName,
+		accountName,
+		snapshotPolicyName,
+	)
+
+	if err != nil {
+		return netapp.SnapshotPolicy{}, fmt.Errorf("cannot update snapshot policy: %w", err)
+	}
+
+	return snapshotPolicy, nil
+}
+
+// DeleteAnfSnapshotPolicy deletes a snapshot policy, it must not be assigned to any volume
+func (s *Service) DeleteAnfSnapshotPolicy(ctx context.Context, resourceGroupName, accountName, snapshotPolicyName string) error {
+
+	snapshotPolicyClient := s.SnapshotPolicies
+
+	err := snapshotPolicyClient.Delete(
+		ctx,
+		resourceGroupName,
+		accountName,
+		snapshotPolicyName,
+	)
+
+	if err != nil {
+		return fmt.Errorf("cannot delete snapshot policy: %w", err)
+	}
+
+	return nil
+}
+
+// CreateAnfBackupPolicy creates a backup policy within an ANF Account
+func (s *Service) CreateAnfBackupPolicy(ctx context.Context, location, resourceGroupName, accountName, backupPolicyName string, policyProperties netapp.BackupPolicyProperties, tags map[string]*string) (netapp.BackupPolicy, error) {
+
+	backupPolicyClient := s.BackupPolicies
+
+	result, err := backupPolicyClient.Create(
+		ctx,
+		netapp.BackupPolicy{
+			Location:               to.StringPtr(location),
+			Tags:                   tags,
+			BackupPolicyProperties: &policyProperties,
+		},
+		resourceGroupName,
+		accountName,
+		backupPolicyName,
+	)
+
+	if err != nil {
+		return netapp.BackupPolicy{}, fmt.Errorf("cannot create backup policy: %w", err)
+	}
+
+	return result, nil
+}
+
+// GetAnfBackupPolicy gets a backup policy
+func (s *Service) GetAnfBackupPolicy(ctx context.Context, resourceGroupName, accountName, backupPolicyName string) (netapp.BackupPolicy, error) {
+
+	backupPolicyClient := s.BackupPolicies
+
+	backupPolicy, err := backupPolicyClient.Get(
+		ctx,
+		resourceGroupName,
+		accountName,
+		backupPolicyName,
+	)
+
+	if err != nil {
+		return netapp.BackupPolicy{}, fmt.Errorf("cannot get backup policy: %w", err)
+	}
+
+	return backupPolicy, nil
+}
+
+// ListAnfBackupPolicies lists backup policies of an ANF Account
+func (s *Service) ListAnfBackupPolicies(ctx context.Context, resourceGroupName, accountName string) ([]netapp.BackupPolicy, error) {
+
+	backupPolicyClient := s.BackupPolicies
+
+	backupPolicyList, err := backupPolicyClient.List(
+		ctx,
+		resourceGroupName,
+		accountName,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("cannot list backup policies: %w", err)
+	}
+
+	return backupPolicyList, nil
+}
+
+// UpdateAnfBackupPolicy updates the retention of a backup policy or enables/disables it
+func (s *Service) UpdateAnfBackupPolicy(ctx context.Context, location, resourceGroupName, accountName, backupPolicyName string, policyProperties netapp.BackupPolicyProperties, tags map[string]*string) (netapp.BackupPolicy, error) {
+
+	backupPolicyClient := s.BackupPolicies
+
+	backupPolicy, err := backupPolicyClient.Update(
+		ctx,
+		netapp.BackupPolicyPatch{
+			Location:               to.StringPtr(location),
+			Tags:                   tags,
+			BackupPolicyProperties: &policyProperties,
+		},
+		resourceGroupName,
+		accountName,
+		backupPolicyName,
+	)
+
+	if err != nil {
+		return netapp.BackupPolicy{}, fmt.Errorf("cannot update backup policy: %w", err)
+	}
+
+	return backupPolicy, nil
+}
+
+// DeleteAnfBackupPolicy deletes a backup policy, it must not be assigned to any volume
+func (s *Service) DeleteAnfBackupPolicy(ctx context.Context, resourceGroupName, accountName, backupPolicyName string) error {
+
+	backupPolicyClient := s.BackupPolicies
+
+	err := backupPolicyClient.Delete(
+		ctx,
+		resourceGroupName,
+		accountName,
+		backupPolicyName,
+	)
+
+	if err != nil {
+		return fmt.Errorf("cannot delete backup policy: %w", err)
+	}
+
+	return nil
+}
+
+// CreateAnfBackup creates an on-demand backup of an ANF volume, optionally from its latest existing snapshot
+func (s *Service) CreateAnfBackup(ctx context.Context, location, resourceGroupName, accountName, poolName, volumeName, backupName, label string, useExistingSnapshot bool) (netapp.Backup, error) {
+
+	backupClient := s.Backups
+
+	result, err := backupClient.Create(
+		ctx,
+		netapp.Backup{
+			Location: to.StringPtr(location),
+			BackupProperties: &netapp.BackupProperties{
+				Label:               map[bool]*string{true: to.StringPtr(label), false: nil}[label != ""],
+				UseExistingSnapshot: to.BoolPtr(useExistingSnapshot),
+			},
+		},
+		resourceGroupName,
+		accountName,
+		poolName,
+		volumeName,
+		backupName,
+	)
+
+	if err != nil {
+		return netapp.Backup{}, fmt.Errorf("cannot create backup: %w", err)
+	}
+
+	return result, nil
+}
+
+// GetAnfBackup gets a backup of an ANF volume
+func (s *Service) GetAnfBackup(ctx context.Context, resourceGroupName, accountName, poolName, volumeName, backupName string) (netapp.Backup, error) {
+
+	backupClient := s.Backups
+
+	backup, err := backupClient.Get(
+		ctx,
+		resourceGroupName,
+		accountName,
+		poolName,
+		volumeName,
+		backupName,
+	)
+
+	if err != nil {
+		return netapp.Backup{}, fmt.Errorf("cannot get backup: %w", err)
+	}
+
+	return backup, nil
+}
+
+// ListAnfBackups lists backups of an ANF volume
+func (s *Service) ListAnfBackups(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string) ([]netapp.Backup, error) {
+
+	backupClient := s.Backups
+
+	backupList, err := backupClient.List(
+		ctx,
+		resourceGroupName,
+		accountName,
+		poolName,
+		volumeName,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("cannot list backups: %w", err)
+	}
+
+	return backupList, nil
+}
+
+// UpdateAnfBackup updates the label and tags of a backup
+func (s *Service) UpdateAnfBackup(ctx context.Context, resourceGroupName, accountName, poolName, volumeName, backupName, label string, tags map[string]*string) (netapp.Backup, error) {
+
+	backupClient := s.Backups
+
+	backup, err := backupClient.Update(
+		ctx,
+		netapp.BackupPatch{
+			Tags: tags,
+			BackupProperties: &netapp.BackupProperties{
+				Label: to.StringPtr(label),
+			},
+		},
+		resourceGroupName,
+		accountName,
+		poolName,
+		volumeName,
+		backupName,
+	)
+
+	if err != nil {
+		return netapp.Backup{}, fmt.Errorf("cannot update backup: %w", err)
+	}
+
+	return backup, nil
+}
+
+// DeleteAnfBackup deletes a backup of an ANF volume
+func (s *Service) DeleteAnfBackup(ctx context.Context, resourceGroupName, accountName, poolName, volumeName, backupName string) error {
+
+	backupClient := s.Backups
+
+	err := backupClient.Delete(
+		ctx,
+		resourceGroupName,
+		accountName,
+		poolName,
+		volumeName,
+		backupName,
+	)
+
+	if err != nil {
+		return fmt.Errorf("cannot delete backup: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteAnfVolume deletes a volume
+func (s *Service) DeleteAnfVolume(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string) error {
+
+	volumesClient := s.Volumes
+
+	err := volumesClient.Delete(
+		ctx,
+		resourceGroupName,
+		accountName,
+		poolName,
+		volumeName,
+	)
+
+	if err != nil {
+		return fmt.Errorf("cannot delete volume: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteAnfCapacityPool deletes a capacity pool
+func (s *Service) DeleteAnfCapacityPool(ctx context.Context, resourceGroupName, accountName, poolName string) error {
+
+	poolsClient := s.Pools
+
+	err := poolsClient.Delete(
+		ctx,
+		resourceGroupName,
+		accountName,
+		poolName,
+	)
+
+	if err != nil {
+		return fmt.Errorf("cannot delete capacity pool: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteAnfAccount deletes an account
+func (s *Service) DeleteAnfAccount(ctx context.Context, resourceGroupName, accountName string) error {
+
+	accountsClient := s.Accounts
+
+	err := accountsClient.Delete(
+		ctx,
+		resourceGroupName,
+		accountName,
+	)
+
+	if err != nil {
+		return fmt.Errorf("cannot delete account: %w", err)
+	}
+
+	return nil
+}
+
+// IsNotFoundError checks if an error returned by the SDK clients or the service methods is an HTTP 404 response
+func IsNotFoundError(err error) bool {
+	return getStatusCode(err) == http.StatusNotFound
+}
+
+// IsReplicationMissingError checks if an error returned by the SDK clients or the service methods reports a volume
+// without replication, the service reports it with the VolumeReplicationMissing code whatever the HTTP status code
+func IsReplicationMissingError(err error) bool {
+	return getServiceErrorCode(err) == replicationMissingCode
+}
+
+// isTransientError checks if an error is worth retrying: throttling, server side errors or errors without an HTTP response (e.g. network errors).
+// Authentication, authorization and other client side errors are not transient.
+func isTransientError(err error) bool {
+	statusCode := getStatusCode(err)
+	return statusCode == 0 ||
+		statusCode == http.StatusRequestTimeout ||
+		statusCode == http.StatusTooManyRequests ||
+		statusCode >= http.StatusInternalServerError
+}
+
+// getServiceErrorCode returns the code of the service error carried by an SDK error, empty if there is none
+func getServiceErrorCode(err error) string {
+	var requestError *azure.RequestError
+	if errors.As(err, &requestError) && requestError.ServiceError != nil {
+		return requestError.ServiceError.Code
+	}
+	var serviceError *azure.ServiceError
+	if errors.As(err, &serviceError) {
+		return serviceError.Code
+	}
+	return ""
+}
+
+// getStatusCode returns the HTTP status code of an SDK error, 0 if there is none
+func getStatusCode(err error) int {
+	var detailedError autorest.DetailedError
+	if errors.As(err, &detailedError) {
+		if statusCode, ok := detailedError.StatusCode.(int); ok {
+			return statusCode
+		}
+	}
+	return 0
+}
+
+// getANFResource gets an ANF resource by its id and returns its provisioning state,
+// for volumes it can also check that the replication status is available
+func (s *Service) getANFResource(ctx context.Context, resourceID string, checkForReplication bool) (string, error) {
+
+	if uri.IsAnfBackup(resourceID) {
+		client := s.Backups
+		backup, err := client.Get(
+			ctx,
+			uri.GetResourceGroup(resourceID),
+			uri.GetAnfAccount(resourceID),
+			uri.GetAnfCapacityPool(resourceID),
+			uri.GetAnfVolume(resourceID),
+			uri.GetAnfBackup(resourceID),
+		)
+		if err != nil || backup.BackupProperties == nil {
+			return "", err
+		}
+		return to.String(backup.ProvisioningState), nil
+	} else if uri.IsAnfSnapshotPolicy(resourceID) {
+		client := s.SnapshotPolicies
+		snapshotPolicy, err := client.Get(
+			ctx,
+			uri.GetResourceGroup(resourceID),
+			uri.GetAnfAccount(resourceID),
+			uri.GetAnfSnapshotPolicy(resourceID),
+		)
+		if err != nil || snapshotPolicy.SnapshotPolicyProperties == nil {
+			return "", err
+		}
+		return to.String(snapshotPolicy.ProvisioningState), nil
+	} else if uri.IsAnfBackupPolicy(resourceID) {
+		client := s.BackupPolicies
+		backupPolicy, err := client.Get(
+			ctx,
+			uri.GetResourceGroup(resourceID),
+			uri.GetAnfAccount(resourceID),
+			uri.GetAnfBackupPolicy(resourceID),
+		)
+		if err != nil || backupPolicy.BackupPolicyProperties == nil {
+			return "", err
+		}
+		return to.String(backupPolicy.ProvisioningState), nil
+	} else if uri.IsAnfSnapshot(resourceID) {
+		client := s.Snapshots
+		snapshot, err := client.Get(
+			ctx,
+			uri.GetResourceGroup(resourceID),
+			uri.GetAnfAccount(resourceID),
+			uri.GetAnfCapacityPool(resourceID),
+			uri.GetAnfVolume(resourceID),
+			uri.GetAnfSnapshot(resourceID),
+		)
+		if err != nil || snapshot.SnapshotProperties == nil {
+			return "", err
+		}
+		return to.String(snapshot.ProvisioningState), nil
+	} else if uri.IsAnfVolume(resourceID) {
+		client := s.Volumes
+		volume, err := client.Get(
+			ctx,
+			uri.GetResourceGroup(resourceID),
+			uri.GetAnfAccount(resourceID),
+			uri.GetAnfCapacityPool(resourceID),
+			uri.GetAnfVolume(resourceID),
+		)
+		if err != nil || volume.VolumeProperties == nil {
+			return "", err
+		}
+		provisioningState := to.String(volume.ProvisioningState)
+		if checkForReplication && provisioningState == provisioningStateSucceeded {
+			_, err = client.ReplicationStatus(
+				ctx,
+				uri.GetResourceGroup(resourceID),
+				uri.GetAnfAccount(resourceID),
+				uri.GetAnfCapacityPool(resourceID),
+				uri.GetAnfVolume(resourceID),
+			)
+		}
+		return provisioningState, err
+	} else if uri.IsAnfCapacityPool(resourceID) {
+		client := s.Pools
+		pool, err := client.Get(
+			ctx,
+			uri.GetResourceGroup(resourceID),
+			uri.GetAnfAccount(resourceID),
+			uri.GetAnfCapacityPool(resourceID),
+		)
+		if err != nil || pool.PoolProperties == nil {
+			return "", err
+		}
+		return to.String(pool.ProvisioningState), nil
+	} else if uri.IsAnfAccount(resourceID) {
+		client := s.Accounts
+		account, err := client.Get(
+			ctx,
+			uri.GetResourceGroup(resourceID),
+			uri.GetAnfAccount(resourceID),
+		)
+		if err != nil || account.AccountProperties == nil {
+			return "", err
+		}
+		return to.String(account.ProvisioningState), nil
+	}
+
+	return "", fmt.Errorf("resource %v is not a supported ANF resource", resourceID)
+}
+
+// provisioningStateTimer keeps track of the time a resource spent in each provisioning state
+type provisioningStateTimer struct {
+	states    []string
+	durations map[string]time.Duration
+	since     time.Time
+}
+
+// observe records the provisioning state returned by the last get operation
+func (t *provisioningStateTimer) observe(provisioningState string) {
+
+	now := time.Now()
+
+	if len(t.states) > 0 {
+		t.durations[t.states[len(t.states)-1]] += now.Sub(t.since)
+	}
+	if len(t.states) == 0 || t.states[len(t.states)-1] != provisioningState {
+		t.states = append(t.states, provisioningState)
+	}
+	t.since = now
+}
+
+// String returns the observed provisioning states in order with the time spent in each one of them
+func (t *provisioningStateTimer) String() string {
+
+	var states []string
+	for _, state := range t.states {
+		if duration, ok := t.durations[state]; ok {
+			states = append(states, fmt.Sprintf("%v (%v)", state, duration.Round(time.Second)))
+		} else {
+			states = append(states, state)
+		}
+	}
+
+	return strings.Join(states, " -> ")
+}
+
+// WaitForNoANFResource waits for a specified resource to don't exist anymore following a deletion.
+// This is due to a known issue related to ARM Cache where the state of the resource is still cached within ARM infrastructure
+// reporting that it still exists so looping into a get process will return 404 as soon as the cached state expires.
+// Only a not found response means the resource is gone, transient errors are retried and any other error stops waiting.
+func (s *Service) WaitForNoANFResource(ctx context.Context, resourceID string, intervalInSec int, retries int, checkForReplication bool) error {
+
+	err := poll.Until(ctx, poll.NewBackoff(time.Duration(intervalInSec)*time.Second, retries), func(ctx context.Context) (bool, error) {
+		_, err := s.getANFResource(ctx, resourceID, checkForReplication)
+		switch {
+		case err == nil:
+			return false, nil
+		case IsNotFoundError(err):
+			return true, nil
+		case checkForReplication && IsReplicationMissingError(err):
+			return true, nil
+		case isTransientError(err):
+			return false, poll.Retry(err)
+		default:
+			return false, err
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("resource %v still exists: %w", resourceID, err)
+	}
+
+	return nil
+}
+
+// WaitForANFResource waits for a specified resource to be fully ready following a creation operation,
+// this is when its provisioning state is Succeeded. It stops early if provisioning state is Failed.
+// Not found and transient errors are retried, any other error stops waiting.
+func (s *Service) WaitForANFResource(ctx context.Context, resourceID string, intervalInSec int, retries int, checkForReplication bool) error {
+
+	timer := provisioningStateTimer{durations: make(map[string]time.Duration)}
+
+	err := poll.Until(ctx, poll.NewBackoff(time.Duration(intervalInSec)*time.Second, retries), func(ctx context.Context) (bool, error) {
+		provisioningState, err := s.getANFResource(ctx, resourceID, checkForReplication)
+		switch {
+		case err == nil:
+		case IsNotFoundError(err) || isTransientError(err):
+			return false, poll.Retry(err)
+		case checkForReplication && IsReplicationMissingError(err):
+			return false, poll.Retry(err)
+		default:
+			return false, err
+		}
+
+		// Resources not reporting a provisioning state are considered ready as soon as they can be retrieved
+		if provisioningState == "" {
+			provisioningState = provisioningStateSucceeded
+		}
+		timer.observe(provisioningState)
+
+		switch {
+		case strings.EqualFold(provisioningState, provisioningStateSucceeded):
+			return true, nil
+		case strings.EqualFold(provisioningState, provisioningStateFailed):
+			return false, fmt.Errorf("provisioning failed, provisioning states: %v", &timer)
+		default:
+			return false, poll.Retry(fmt.Errorf("provisioning state is %v", provisioningState))
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("resource %v is not ready: %w", resourceID, err)
+	}
+
+	if len(timer.states) > 1 {
+		utils.ConsoleOutput(fmt.Sprintf("\tResource %v provisioning states: %v", uri.GetResourceName(resourceID), &timer))
+	}
+
+	return nil
+}
+
+// MirrorCondition is a combination of mirror state and relationship status accepted by WaitForMirrorState,
+// an empty relationship status accepts any relationship status
+type MirrorCondition struct {
+	MirrorState        netapp.MirrorState
+	RelationshipStatus netapp.RelationshipStatus
+}
+
+// MirrorWaitResult is the outcome of WaitForMirrorState
+type MirrorWaitResult string
+
+const (
+	// MirrorWaitSuccess means that the replication reached one of the accepted conditions
+	MirrorWaitSuccess MirrorWaitResult = "Success"
+	// MirrorWaitTimeout means that attempts were exceeded or context deadline was reached
+	MirrorWaitTimeout MirrorWaitResult = "Timeout"
+	// MirrorWaitTerminalError means that waiting stopped because of an error that will not go away by waiting
+	MirrorWaitTerminalError MirrorWaitResult = "TerminalError"
+)
+
+// MirrorProgressFunc is called with every replication status retrieved while waiting
+type MirrorProgressFunc func(replicationStatus netapp.ReplicationStatus)
+
+// matches checks if a replication status satisfies the condition
+func (c MirrorCondition) matches(replicationStatus netapp.ReplicationStatus) bool {
+	return replicationStatus.MirrorState == c.MirrorState &&
+		(c.RelationshipStatus == "" || replicationStatus.RelationshipStatus == c.RelationshipStatus)
+}
+
+// String returns the condition in a readable format
+func (c MirrorCondition) String() string {
+	if c.RelationshipStatus == "" {
+		return string(c.MirrorState)
+	}
+	return fmt.Sprintf("%v/%v", c.MirrorState, c.RelationshipStatus)
+}
+
+// WaitForMirrorState waits for a volume replication to reach one of the accepted mirror state and relationship status combinations.
+// Progress, when not nil, is called with every replication status retrieved. It returns the result of the wait
+// along with the last replication status retrieved, error is nil only on success.
+func (s *Service) WaitForMirrorState(ctx context.Context, volumeID string, conditions []MirrorCondition, progress MirrorProgressFunc, intervalInSec int, retries int) (MirrorWaitResult, netapp.ReplicationStatus, error) {
+
+	var replicationStatus netapp.ReplicationStatus
+
+	client := s.Volumes
+
+	err := poll.Until(ctx, poll.NewBackoff(time.Duration(intervalInSec)*time.Second, retries), func(ctx context.Context) (bool, error) {
+		status, err := client.ReplicationStatus(
+			ctx,
+			uri.GetResourceGroup(volumeID),
+			uri.GetAnfAccount(volumeID),
+			uri.GetAnfCapacityPool(volumeID),
+			uri.GetAnfVolume(volumeID),
+		)
+		if err != nil {
+			if IsNotFoundError(err) || isTransientError(err) {
+				return false, poll.Retry(err)
+			}
+			return false, err
+		}
+
+		replicationStatus = status
+		if progress != nil {
+			progress(replicationStatus)
+		}
+
+		for _, condition := range conditions {
+			if condition.matches(replicationStatus) {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		result := MirrorWaitTerminalError
+		if errors.Is(err, poll.ErrAttemptsExceeded) || errors.Is(err, context.DeadlineExceeded) {
+			result = MirrorWaitTimeout
+		}
+		return result, replicationStatus, fmt.Errorf("volume replication not at %v, current state: %v/%v: %w",
+			conditions,
+			replicationStatus.MirrorState,
+			replicationStatus.RelationshipStatus,
+			err,
+		)
+	}
+
+	return MirrorWaitSuccess, replicationStatus, nil
+}
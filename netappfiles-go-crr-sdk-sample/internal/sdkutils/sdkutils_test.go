@@ -0,0 +1,261 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package sdkutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/poll"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/netapp/mgmt/netapp"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure"
+)
+
+func TestProvisioningStateTimer(t *testing.T) {
+
+	timer := provisioningStateTimer{durations: make(map[string]time.Duration)}
+	if got := timer.String(); got != "" {
+		t.Errorf("timer without observations is %q, want empty", got)
+	}
+
+	// Durations are moved back instead of sleeping, repeated states add up
+	timer.observe("Creating")
+	timer.since = timer.since.Add(-2 * time.Second)
+	timer.observe("Creating")
+	timer.since = timer.since.Add(-3 * time.Second)
+	timer.observe("Updating")
+	timer.since = timer.since.Add(-time.Second)
+	timer.observe("Succeeded")
+
+	if len(timer.states) != 3 {
+		t.Fatalf("timer recorded states %v, want Creating, Updating and Succeeded", timer.states)
+	}
+	if want := "Creating (5s) -> Updating (1s) -> Succeeded"; timer.String() != want {
+		t.Errorf("timer is %q, want %q", timer.String(), want)
+	}
+}
+
+func TestIsReplicationMissingError(t *testing.T) {
+
+	// SDK clients wrap the service error in a request error, long running operations report it directly
+	requestError := func(statusCode int, code string) error {
+		return autorest.DetailedError{
+			Original:   &azure.RequestError{ServiceError: &azure.ServiceError{Code: code}},
+			StatusCode: statusCode,
+		}
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"bad request", requestError(http.StatusBadRequest, replicationMissingCode), true},
+		{"not found", requestError(http.StatusNotFound, replicationMissingCode), true},
+		{"wrapped by service method", fmt.Errorf("cannot get replication status: %w", requestError(http.StatusBadRequest, replicationMissingCode)), true},
+		{"long running operation", autorest.DetailedError{Original: &azure.ServiceError{Code: replicationMissingCode}}, true},
+		{"other code", requestError(http.StatusNotFound, "ResourceNotFound"), false},
+		{"no service error", errors.New(replicationMissingCode), false},
+		{"no error", nil, false},
+	}
+
+	for _, test := range tests {
+		if got := IsReplicationMissingError(test.err); got != test.want {
+			t.Errorf("%v: IsReplicationMissingError is %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+// replicationStatusResponse is a response of scriptedReplicationStatus, either a status or an error
+type replicationStatusResponse struct {
+	status netapp.ReplicationStatus
+	err    error
+}
+
+// scriptedReplicationStatus returns its responses in order, the last one is repeated
+type scriptedReplicationStatus struct {
+	VolumesAPI
+	responses []replicationStatusResponse
+	calls     int
+}
+
+func (s *scriptedReplicationStatus) ReplicationStatus(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string) (netapp.ReplicationStatus, error) {
+	response := s.responses[len(s.responses)-1]
+	if s.calls < len(s.responses) {
+		response = s.responses[s.calls]
+	}
+	s.calls++
+	return response.status, response.err
+}
+
+func TestWaitForMirrorState(t *testing.T) {
+
+	initialWait := poll.InitialWait
+	poll.InitialWait = time.Millisecond
+	defer func() { poll.InitialWait = initialWait }()
+
+	const volumeID = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/anf-rg/providers/Microsoft.NetApp/netAppAccounts/account/capacityPools/pool/volumes/volume"
+
+	status := func(mirrorState netapp.MirrorState, relationshipStatus netapp.RelationshipStatus) replicationStatusResponse {
+		return replicationStatusResponse{status: netapp.ReplicationStatus{MirrorState: mirrorState, RelationshipStatus: relationshipStatus}}
+	}
+	failure := func(statusCode int) replicationStatusResponse {
+		return replicationStatusResponse{err: autorest.DetailedError{StatusCode: statusCode}}
+	}
+	mirroredIdle := MirrorCondition{MirrorState: netapp.MirrorStateMirrored, RelationshipStatus: netapp.RelationshipStatusIdle}
+	broken := MirrorCondition{MirrorState: netapp.MirrorStateBroken}
+
+	expired, cancel := context.WithDeadline(context.Background(), time.Now())
+	defer cancel()
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name       string
+		cntx       context.Context
+		conditions []MirrorCondition
+		responses  []replicationStatusResponse
+		want       MirrorWaitResult
+		wantStatus netapp.MirrorState
+		wantCalls  int
+	}{
+		{
+			name:       "transfer completes",
+			conditions: []MirrorCondition{mirroredIdle},
+			responses: []replicationStatusResponse{
+				status(netapp.MirrorStateUninitialized, netapp.RelationshipStatusTransferring),
+				status(netapp.MirrorStateMirrored, netapp.RelationshipStatusTransferring),
+				status(netapp.MirrorStateMirrored, netapp.RelationshipStatusIdle),
+			},
+			want:       MirrorWaitSuccess,
+			wantStatus: netapp.MirrorStateMirrored,
+			wantCalls:  3,
+		},
+		{
+			name:       "empty relationship status accepts any",
+			conditions: []MirrorCondition{broken},
+			responses:  []replicationStatusResponse{status(netapp.MirrorStateBroken, netapp.RelationshipStatusTransferring)},
+			want:       MirrorWaitSuccess,
+			wantStatus: netapp.MirrorStateBroken,
+			wantCalls:  1,
+		},
+		{
+			name:       "any condition is accepted",
+			conditions: []MirrorCondition{mirroredIdle, broken},
+			responses:  []replicationStatusResponse{status(netapp.MirrorStateBroken, netapp.RelationshipStatusIdle)},
+			want:       MirrorWaitSuccess,
+			wantStatus: netapp.MirrorStateBroken,
+			wantCalls:  1,
+		},
+		{
+			name:       "not found and transient errors are retried",
+			conditions: []MirrorCondition{mirroredIdle},
+			responses: []replicationStatusResponse{
+				failure(http.StatusNotFound),
+				failure(http.StatusTooManyRequests),
+				failure(http.StatusInternalServerError),
+				status(netapp.MirrorStateMirrored, netapp.RelationshipStatusIdle),
+			},
+			want:       MirrorWaitSuccess,
+			wantStatus: netapp.MirrorStateMirrored,
+			wantCalls:  4,
+		},
+		{
+			name:       "attempts exceeded",
+			conditions: []MirrorCondition{mirroredIdle},
+			responses:  []replicationStatusResponse{status(netapp.MirrorStateUninitialized, netapp.RelationshipStatusTransferring)},
+			want:       MirrorWaitTimeout,
+			wantStatus: netapp.MirrorStateUninitialized,
+			wantCalls:  5,
+		},
+		{
+			name:       "deadline reached",
+			cntx:       expired,
+			conditions: []MirrorCondition{mirroredIdle},
+			responses:  []replicationStatusResponse{status(netapp.MirrorStateMirrored, netapp.RelationshipStatusIdle)},
+			want:       MirrorWaitTimeout,
+		},
+		{
+			name:       "canceled",
+			cntx:       canceled,
+			conditions: []MirrorCondition{mirroredIdle},
+			responses:  []replicationStatusResponse{status(netapp.MirrorStateMirrored, netapp.RelationshipStatusIdle)},
+			want:       MirrorWaitTerminalError,
+		},
+		{
+			name:       "client error stops waiting",
+			conditions: []MirrorCondition{mirroredIdle},
+			responses: []replicationStatusResponse{
+				status(netapp.MirrorStateUninitialized, netapp.RelationshipStatusTransferring),
+				failure(http.StatusForbidden),
+			},
+			want:       MirrorWaitTerminalError,
+			wantStatus: netapp.MirrorStateUninitialized,
+			wantCalls:  2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			cntx := test.cntx
+			if cntx == nil {
+				cntx = context.Background()
+			}
+			volumes := &scriptedReplicationStatus{responses: test.responses}
+			service := &Service{Volumes: volumes}
+
+			// Progress sees every status retrieved, errors are not reported to it
+			var progressed []netapp.ReplicationStatus
+			progress := func(replicationStatus netapp.ReplicationStatus) {
+				progressed = append(progressed, replicationStatus)
+			}
+
+			result, replicationStatus, err := service.WaitForMirrorState(cntx, volumeID, test.conditions, progress, 1, 5)
+			if result != test.want {
+				t.Fatalf("result is %v (%v), want %v", result, err, test.want)
+			}
+			if (err == nil) != (result == MirrorWaitSuccess) {
+				t.Errorf("result %v returned error %v", result, err)
+			}
+			if replicationStatus.MirrorState != test.wantStatus {
+				t.Errorf("last status is %v, want %v", replicationStatus.MirrorState, test.wantStatus)
+			}
+			if volumes.calls != test.wantCalls {
+				t.Errorf("replication status was retrieved %v times, want %v", volumes.calls, test.wantCalls)
+			}
+			var statuses int
+			for call := 0; call < test.wantCalls; call++ {
+				response := test.responses[len(test.responses)-1]
+				if call < len(test.responses) {
+					response = test.responses[call]
+				}
+				if response.err == nil {
+					statuses++
+				}
+			}
+			if len(progressed) != statuses {
+				t.Errorf("progress was called %v times, want %v", len(progressed), statuses)
+			}
+		})
+	}
+}
+
+func TestMirrorConditionString(t *testing.T) {
+
+	conditions := []MirrorCondition{
+		{MirrorState: netapp.MirrorStateMirrored, RelationshipStatus: netapp.RelationshipStatusIdle},
+		{MirrorState: netapp.MirrorStateBroken},
+	}
+	if got, want := fmt.Sprint(conditions), "[Mirrored/Idle Broken]"; got != want {
+		t.Errorf("conditions are %q, want %q", got, want)
+	}
+}
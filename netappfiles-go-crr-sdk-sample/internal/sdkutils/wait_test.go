@@ -0,0 +1,101 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package sdkutils_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/fake"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/poll"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/uri"
+)
+
+const (
+	testSubscriptionID    = "00000000-0000-0000-0000-000000000000"
+	testResourceGroupName = "anf-rg"
+	testAccountName       = "account"
+)
+
+func TestWaitForANFResource(t *testing.T) {
+
+	initialWait := poll.InitialWait
+	poll.InitialWait = time.Millisecond
+	defer func() { poll.InitialWait = initialWait }()
+
+	accountID := uri.AccountID(testSubscriptionID, testResourceGroupName, testAccountName).String()
+
+	tests := []struct {
+		name               string
+		provisioningStates []string
+		wantErr            string
+		attemptsExceeded   bool
+	}{
+		{
+			name:               "succeeded after creating",
+			provisioningStates: []string{"Creating", "Creating", "Succeeded"},
+		},
+		{
+			name:               "failed stops waiting",
+			provisioningStates: []string{"Creating", "Failed"},
+			wantErr:            "provisioning failed, provisioning states: Creating (0s) -> Failed",
+		},
+		{
+			name:               "still creating",
+			provisioningStates: []string{"Creating"},
+			wantErr:            "provisioning state is Creating",
+			attemptsExceeded:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			backend := fake.New(testSubscriptionID)
+			service := backend.Service()
+
+			_, err := service.CreateAnfAccount(context.Background(), "westus", testResourceGroupName, testAccountName, nil, nil)
+			if err != nil {
+				t.Fatalf("cannot create account: %v", err)
+			}
+			backend.SetProvisioningStates(accountID, test.provisioningStates...)
+
+			// Failed must be reported right away, well before the attempts run out
+			err = service.WaitForANFResource(context.Background(), accountID, 1, 6, false)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatalf("waiting returned %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Fatalf("waiting returned %v, want %q", err, test.wantErr)
+			}
+			if errors.Is(err, poll.ErrAttemptsExceeded) != test.attemptsExceeded {
+				t.Errorf("waiting returned %v, attempts exceeded want %v", err, test.attemptsExceeded)
+			}
+		})
+	}
+}
+
+func TestWaitForANFResourceNotFound(t *testing.T) {
+
+	initialWait := poll.InitialWait
+	poll.InitialWait = time.Millisecond
+	defer func() { poll.InitialWait = initialWait }()
+
+	service := fake.New(testSubscriptionID).Service()
+	accountID := uri.AccountID(testSubscriptionID, testResourceGroupName, testAccountName).String()
+
+	// A resource that never shows up is retried until the attempts run out
+	err := service.WaitForANFResource(context.Background(), accountID, 1, 3, false)
+	if !errors.Is(err, poll.ErrAttemptsExceeded) {
+		t.Fatalf("waiting for a missing resource returned %v, want %v", err, poll.ErrAttemptsExceeded)
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// This package persists the steps completed by this sample and the
+// resource ids they produced into a local json file, so an
+// interrupted execution can be resumed and resources can be
+// removed based on what was actually created.
+
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// Step object definition
+type Step struct {
+	Name        string    `json:"name"`
+	ResourceID  string    `json:"resourceId,omitempty"`
+	CompletedAt time.Time `json:"completedAt"`
+}
+
+// State object definition
+type State struct {
+	Steps []Step `json:"steps"`
+	path  string
+}
+
+// Load reads a state file, returning an empty state if file does not exist yet
+func Load(path string) (*State, error) {
+
+	state := State{path: path}
+
+	stateJSON, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %v", err)
+	}
+
+	err = json.Unmarshal(stateJSON, &state)
+	if err != nil {
+		return nil, fmt.Errorf("invalid state file %v: %v", path, err)
+	}
+
+	return &state, nil
+}
+
+// Path returns the location of the state file
+func (s *State) Path() string {
+	return s.path
+}
+
+// IsEmpty checks if there is any completed step recorded
+func (s *State) IsEmpty() bool {
+	return len(s.Steps) == 0
+}
+
+// Completed returns the resource id recorded by a step and true if step is already completed
+func (s *State) Completed(name string) (string, bool) {
+	for _, step := range s.Steps {
+		if step.Name == name {
+			return step.ResourceID, true
+		}
+	}
+	return "", false
+}
+
+// Complete records a step as completed and saves the state file
+func (s *State) Complete(name, resourceID string) error {
+
+	s.remove(name)
+	s.Steps = append(s.Steps, Step{
+		Name:        name,
+		ResourceID:  resourceID,
+		CompletedAt: time.Now().UTC(),
+	})
+
+	return s.Save()
+}
+
+// Remove removes a step from state and saves the state file, file is deleted when there are no steps left
+func (s *State) Remove(name string) error {
+
+	s.remove(name)
+
+	if s.IsEmpty() {
+		err := os.Remove(s.path)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove state file: %v", err)
+		}
+		return nil
+	}
+
+	return s.Save()
+}
+
+// RenameSteps renames all recorded steps using the provided function and saves the state file
+func (s *State) RenameSteps(rename func(name string) string) error {
+
+	for i := range s.Steps {
+		s.Steps[i].Name = rename(s.Steps[i].Name)
+	}
+
+	return s.Save()
+}
+
+// Save writes the state file
+func (s *State) Save() error {
+
+	stateJSON, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %v", err)
+	}
+
+	err = ioutil.WriteFile(s.path, stateJSON, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write state file: %v", err)
+	}
+
+	return nil
+}
+
+func (s *State) remove(name string) {
+	steps := s.Steps[:0]
+	for _, step := range s.Steps {
+		if step.Name != name {
+			steps = append(steps, step)
+		}
+	}
+	s.Steps = steps
+}
@@ -0,0 +1,167 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package state
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadMissingFile(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "topology.state.json")
+
+	state, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() of a missing file returned error: %v", err)
+	}
+	if !state.IsEmpty() {
+		t.Errorf("Load() of a missing file returned steps %v", state.Steps)
+	}
+	if state.Path() != path {
+		t.Errorf("Path() = %v, want %v", state.Path(), path)
+	}
+}
+
+func TestLoadInvalidFile(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "topology.state.json")
+	err := ioutil.WriteFile(path, []byte("{\"steps\": ["), 0600)
+	if err != nil {
+		t.Fatalf("cannot write state file: %v", err)
+	}
+
+	_, err = Load(path)
+	if err == nil || !strings.Contains(err.Error(), "invalid state file") {
+		t.Fatalf("Load() of an invalid file returned error %v, want invalid state file", err)
+	}
+}
+
+func TestCompleteIsResumed(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "topology.state.json")
+
+	state, err := Load(path)
+	if err != nil {
+		t.Fatalf("cannot load state: %v", err)
+	}
+	if err := state.Complete("Primary/account", "account-1"); err != nil {
+		t.Fatalf("Complete() returned error: %v", err)
+	}
+	if err := state.Complete("Primary/capacityPool", "pool-1"); err != nil {
+		t.Fatalf("Complete() returned error: %v", err)
+	}
+
+	// Completing a step again replaces its resource id instead of recording it twice
+	if err := state.Complete("Primary/account", "account-2"); err != nil {
+		t.Fatalf("Complete() returned error: %v", err)
+	}
+
+	// A new execution resumes from the steps saved by the previous one
+	resumed, err := Load(path)
+	if err != nil {
+		t.Fatalf("cannot reload state: %v", err)
+	}
+	if len(resumed.Steps) != 2 {
+		t.Fatalf("reloaded state has %v steps, want 2", len(resumed.Steps))
+	}
+	for name, want := range map[string]string{"Primary/account": "account-2", "Primary/capacityPool": "pool-1"} {
+		resourceID, completed := resumed.Completed(name)
+		if !completed || resourceID != want {
+			t.Errorf("Completed(%v) = %v, %v, want %v, true", name, resourceID, completed, want)
+		}
+	}
+	for _, step := range resumed.Steps {
+		if step.CompletedAt.IsZero() {
+			t.Errorf("step %v has no completion time", step.Name)
+		}
+	}
+	if _, completed := resumed.Completed("Primary/volume"); completed {
+		t.Errorf("Completed() reports a step that never ran")
+	}
+}
+
+func TestRemoveDeletesEmptyState(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "topology.state.json")
+
+	state, err := Load(path)
+	if err != nil {
+		t.Fatalf("cannot load state: %v", err)
+	}
+	for _, name := range []string{"Primary/account", "Primary/capacityPool"} {
+		if err := state.Complete(name, name); err != nil {
+			t.Fatalf("Complete() returned error: %v", err)
+		}
+	}
+
+	if err := state.Remove("Primary/capacityPool"); err != nil {
+		t.Fatalf("Remove() returned error: %v", err)
+	}
+	resumed, err := Load(path)
+	if err != nil {
+		t.Fatalf("cannot reload state: %v", err)
+	}
+	if _, completed := resumed.Completed("Primary/capacityPool"); completed {
+		t.Errorf("removed step is still saved")
+	}
+	if _, completed := resumed.Completed("Primary/account"); !completed {
+		t.Errorf("remaining step was not saved")
+	}
+
+	// Removing the last step deletes the file, removing again is not an error
+	if err := state.Remove("Primary/account"); err != nil {
+		t.Fatalf("Remove() returned error: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("state file still exists after removing the last step: %v", err)
+	}
+	if err := state.Remove("Primary/account"); err != nil {
+		t.Errorf("Remove() from an empty state returned error: %v", err)
+	}
+}
+
+func TestRenameSteps(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "topology.state.json")
+
+	state, err := Load(path)
+	if err != nil {
+		t.Fatalf("cannot load state: %v", err)
+	}
+	for _, name := range []string{"Primary/volume", "Secondary/volume", "replication"} {
+		if err := state.Complete(name, name); err != nil {
+			t.Fatalf("Complete() returned error: %v", err)
+		}
+	}
+
+	err = state.RenameSteps(func(name string) string {
+		switch {
+		case strings.HasPrefix(name, "Primary/"):
+			return "Secondary/" + strings.TrimPrefix(name, "Primary/")
+		case strings.HasPrefix(name, "Secondary/"):
+			return "Primary/" + strings.TrimPrefix(name, "Secondary/")
+		}
+		return name
+	})
+	if err != nil {
+		t.Fatalf("RenameSteps() returned error: %v", err)
+	}
+
+	resumed, err := Load(path)
+	if err != nil {
+		t.Fatalf("cannot reload state: %v", err)
+	}
+	for name, want := range map[string]string{"Primary/volume": "Secondary/volume", "Secondary/volume": "Primary/volume", "replication": "replication"} {
+		resourceID, completed := resumed.Completed(name)
+		if !completed || resourceID != want {
+			t.Errorf("Completed(%v) = %v, %v, want %v, true", name, resourceID, completed, want)
+		}
+	}
+}
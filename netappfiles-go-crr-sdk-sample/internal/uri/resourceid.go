@@ -0,0 +1,235 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package uri
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	subscriptionsSegment  string = "subscriptions"
+	resourceGroupsSegment string = "resourceGroups"
+	providersSegment      string = "providers"
+
+	networkResourceProviderName string = "Microsoft.Network"
+
+	// Resource types of the ANF resources and subnets used by this sample
+	NetAppAccountsType   string = "netAppAccounts"
+	CapacityPoolsType    string = "capacityPools"
+	VolumesType          string = "volumes"
+	SnapshotsType        string = "snapshots"
+	SnapshotPoliciesType string = "snapshotPolicies"
+	BackupPoliciesType   string = "backupPolicies"
+	BackupsType          string = "backups"
+	VolumeGroupsType     string = "volumeGroups"
+	VirtualNetworksType  string = "virtualNetworks"
+	SubnetsType          string = "subnets"
+)
+
+// ResourceID is a parsed resource id, e.g.
+// /subscriptions/{subscription}/resourceGroups/{resourceGroup}/providers/Microsoft.NetApp/netAppAccounts/{account}/capacityPools/{pool}
+// has Provider Microsoft.NetApp, Types [netAppAccounts capacityPools] and Names [{account} {pool}].
+// Ids of a subscription or of a resource group have no provider.
+type ResourceID struct {
+	SubscriptionID    string
+	ResourceGroupName string
+	Provider          string
+	Types             []string
+	Names             []string
+}
+
+// ParseResourceID parses a resource id, segment keywords are matched regardless of case but only at their positions,
+// so names matching a keyword, e.g. a capacity pool named volumes, are kept as names
+func ParseResourceID(resourceID string) (ResourceID, error) {
+
+	segments := strings.Split(strings.Trim(strings.TrimSpace(resourceID), "/"), "/")
+	for _, segment := range segments {
+		if segment == "" {
+			return ResourceID{}, fmt.Errorf("invalid resource id %q: empty segment", resourceID)
+		}
+	}
+
+	if len(segments) < 2 || !strings.EqualFold(segments[0], subscriptionsSegment) {
+		return ResourceID{}, fmt.Errorf("invalid resource id %q: it must start with /%v/{subscription}", resourceID, subscriptionsSegment)
+	}
+
+	id := ResourceID{SubscriptionID: segments[1]}
+	segments = segments[2:]
+
+	if len(segments) == 0 {
+		return id, nil
+	}
+	if len(segments) < 2 || !strings.EqualFold(segments[0], resourceGroupsSegment) {
+		return ResourceID{}, fmt.Errorf("invalid resource id %q: expected /%v/{resourceGroup} after subscription", resourceID, resourceGroupsSegment)
+	}
+	id.ResourceGroupName = segments[1]
+	segments = segments[2:]
+
+	if len(segments) == 0 {
+		return id, nil
+	}
+	if len(segments) < 4 || !strings.EqualFold(segments[0], providersSegment) {
+		return ResourceID{}, fmt.Errorf("invalid resource id %q: expected /%v/{provider}/{type}/{name} after resource group", resourceID, providersSegment)
+	}
+	id.Provider = segments[1]
+	segments = segments[2:]
+
+	if len(segments)%2 != 0 {
+		return ResourceID{}, fmt.Errorf("invalid resource id %q: resource type %v has no name", resourceID, segments[len(segments)-1])
+	}
+	for i := 0; i < len(segments); i += 2 {
+		id.Types = append(id.Types, segments[i])
+		id.Names = append(id.Names, segments[i+1])
+	}
+
+	return id, nil
+}
+
+// String returns the resource id
+func (id ResourceID) String() string {
+
+	var builder strings.Builder
+
+	fmt.Fprintf(&builder, "/%v/%v", subscriptionsSegment, id.SubscriptionID)
+	if id.ResourceGroupName != "" {
+		fmt.Fprintf(&builder, "/%v/%v", resourceGroupsSegment, id.ResourceGroupName)
+	}
+	if id.Provider != "" {
+		fmt.Fprintf(&builder, "/%v/%v", providersSegment, id.Provider)
+	}
+	for i := range id.Types {
+		fmt.Fprintf(&builder, "/%v/%v", id.Types[i], id.Names[i])
+	}
+
+	return builder.String()
+}
+
+// Name returns the name of the resource, this is the last name of the id
+func (id ResourceID) Name() string {
+	if len(id.Names) == 0 {
+		return ""
+	}
+	return id.Names[len(id.Names)-1]
+}
+
+// Type returns the full resource type, e.g. Microsoft.NetApp/netAppAccounts/capacityPools
+func (id ResourceID) Type() string {
+	if id.Provider == "" {
+		return ""
+	}
+	return strings.Join(append([]string{id.Provider}, id.Types...), "/")
+}
+
+// NameOf returns the name of the resource of a type in the id, e.g. the account name of a volume id, empty if there is none
+func (id ResourceID) NameOf(resourceType string) string {
+	for i, t := range id.Types {
+		if strings.EqualFold(t, resourceType) {
+			return id.Names[i]
+		}
+	}
+	return ""
+}
+
+// Is checks if the resource is of a provider and exactly of a type chain, regardless of case
+func (id ResourceID) Is(provider string, types ...string) bool {
+
+	if !strings.EqualFold(id.Provider, provider) || len(id.Types) != len(types) {
+		return false
+	}
+
+	for i := range types {
+		if !strings.EqualFold(id.Types[i], types[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Parent returns the id of the parent resource, the resource group for top level resources
+func (id ResourceID) Parent() ResourceID {
+
+	if len(id.Types) <= 1 {
+		return ResourceID{SubscriptionID: id.SubscriptionID, ResourceGroupName: id.ResourceGroupName}
+	}
+
+	return ResourceID{
+		SubscriptionID:    id.SubscriptionID,
+		ResourceGroupName: id.ResourceGroupName,
+		Provider:          id.Provider,
+		Types:             append([]string{}, id.Types[:len(id.Types)-1]...),
+		Names:             append([]string{}, id.Names[:len(id.Names)-1]...),
+	}
+}
+
+// Child returns the id of a child resource
+func (id ResourceID) Child(resourceType, name string) ResourceID {
+	return ResourceID{
+		SubscriptionID:    id.SubscriptionID,
+		ResourceGroupName: id.ResourceGroupName,
+		Provider:          id.Provider,
+		Types:             append(append([]string{}, id.Types...), resourceType),
+		Names:             append(append([]string{}, id.Names...), name),
+	}
+}
+
+// AccountID builds the id of an ANF account
+func AccountID(subscriptionID, resourceGroupName, accountName string) ResourceID {
+	return ResourceID{
+		SubscriptionID:    subscriptionID,
+		ResourceGroupName: resourceGroupName,
+		Provider:          netAppResourceProviderName,
+		Types:             []string{NetAppAccountsType},
+		Names:             []string{accountName},
+	}
+}
+
+// CapacityPoolID builds the id of an ANF capacity pool
+func CapacityPoolID(subscriptionID, resourceGroupName, accountName, poolName string) ResourceID {
+	return AccountID(subscriptionID, resourceGroupName, accountName).Child(CapacityPoolsType, poolName)
+}
+
+// VolumeID builds the id of an ANF volume
+func VolumeID(subscriptionID, resourceGroupName, accountName, poolName, volumeName string) ResourceID {
+	return CapacityPoolID(subscriptionID, resourceGroupName, accountName, poolName).Child(VolumesType, volumeName)
+}
+
+// SnapshotID builds the id of an ANF snapshot
+func SnapshotID(subscriptionID, resourceGroupName, accountName, poolName, volumeName, snapshotName string) ResourceID {
+	return VolumeID(subscriptionID, resourceGroupName, accountName, poolName, volumeName).Child(SnapshotsType, snapshotName)
+}
+
+// SnapshotPolicyID builds the id of an ANF snapshot policy
+func SnapshotPolicyID(subscriptionID, resourceGroupName, accountName, snapshotPolicyName string) ResourceID {
+	return AccountID(subscriptionID, resourceGroupName, accountName).Child(SnapshotPoliciesType, snapshotPolicyName)
+}
+
+// BackupPolicyID builds the id of an ANF backup policy
+func BackupPolicyID(subscriptionID, resourceGroupName, accountName, backupPolicyName string) ResourceID {
+	return AccountID(subscriptionID, resourceGroupName, accountName).Child(BackupPoliciesType, backupPolicyName)
+}
+
+// BackupID builds the id of an ANF volume backup
+func BackupID(subscriptionID, resourceGroupName, accountName, poolName, volumeName, backupName string) ResourceID {
+	return VolumeID(subscriptionID, resourceGroupName, accountName, poolName, volumeName).Child(BackupsType, backupName)
+}
+
+// VolumeGroupID builds the id of an ANF volume group
+func VolumeGroupID(subscriptionID, resourceGroupName, accountName, volumeGroupName string) ResourceID {
+	return AccountID(subscriptionID, resourceGroupName, accountName).Child(VolumeGroupsType, volumeGroupName)
+}
+
+// SubnetID builds the id of a virtual network subnet
+func SubnetID(subscriptionID, resourceGroupName, vnetName, subnetName string) ResourceID {
+	return ResourceID{
+		SubscriptionID:    subscriptionID,
+		ResourceGroupName: resourceGroupName,
+		Provider:          networkResourceProviderName,
+		Types:             []string{VirtualNetworksType, SubnetsType},
+		Names:             []string{vnetName, subnetName},
+	}
+}
@@ -0,0 +1,372 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package uri
+
+import (
+	"reflect"
+	"testing"
+)
+
+const (
+	testSubscriptionID = "00000000-0000-0000-0000-000000000000"
+	testAccountID      = "/subscriptions/" + testSubscriptionID + "/resourceGroups/anf-rg/providers/Microsoft.NetApp/netAppAccounts/account1"
+	testPoolID         = testAccountID + "/capacityPools/pool1"
+	testVolumeID       = testPoolID + "/volumes/volume1"
+	testSnapshotID     = testVolumeID + "/snapshots/snapshot1"
+	testSubnetID       = "/subscriptions/" + testSubscriptionID + "/resourceGroups/vnet-rg/providers/Microsoft.Network/virtualNetworks/vnet1/subnets/subnet1"
+)
+
+func TestParseResourceIDRoundTrip(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		resourceID string
+		want       ResourceID
+	}{
+		{
+			name:       "subscription",
+			resourceID: "/subscriptions/" + testSubscriptionID,
+			want:       ResourceID{SubscriptionID: testSubscriptionID},
+		},
+		{
+			name:       "resource group",
+			resourceID: "/subscriptions/" + testSubscriptionID + "/resourceGroups/anf-rg",
+			want:       ResourceID{SubscriptionID: testSubscriptionID, ResourceGroupName: "anf-rg"},
+		},
+		{
+			name:       "account",
+			resourceID: testAccountID,
+			want:       AccountID(testSubscriptionID, "anf-rg", "account1"),
+		},
+		{
+			name:       "capacity pool",
+			resourceID: testPoolID,
+			want:       CapacityPoolID(testSubscriptionID, "anf-rg", "account1", "pool1"),
+		},
+		{
+			name:       "volume",
+			resourceID: testVolumeID,
+			want:       VolumeID(testSubscriptionID, "anf-rg", "account1", "pool1", "volume1"),
+		},
+		{
+			name:       "snapshot",
+			resourceID: testSnapshotID,
+			want:       SnapshotID(testSubscriptionID, "anf-rg", "account1", "pool1", "volume1", "snapshot1"),
+		},
+		{
+			name:       "snapshot policy",
+			resourceID: testAccountID + "/snapshotPolicies/policy1",
+			want:       SnapshotPolicyID(testSubscriptionID, "anf-rg", "account1", "policy1"),
+		},
+		{
+			name:       "backup policy",
+			resourceID: testAccountID + "/backupPolicies/policy1",
+			want:       BackupPolicyID(testSubscriptionID, "anf-rg", "account1", "policy1"),
+		},
+		{
+			name:       "backup",
+			resourceID: testVolumeID + "/backups/backup1",
+			want:       BackupID(testSubscriptionID, "anf-rg", "account1", "pool1", "volume1", "backup1"),
+		},
+		{
+			name:       "volume group",
+			resourceID: testAccountID + "/volumeGroups/group1",
+			want:       VolumeGroupID(testSubscriptionID, "anf-rg", "account1", "group1"),
+		},
+		{
+			name:       "subnet",
+			resourceID: testSubnetID,
+			want:       SubnetID(testSubscriptionID, "vnet-rg", "vnet1", "subnet1"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			id, err := ParseResourceID(test.resourceID)
+			if err != nil {
+				t.Fatalf("ParseResourceID(%q) returned error: %v", test.resourceID, err)
+			}
+			if !reflect.DeepEqual(id, test.want) {
+				t.Errorf("ParseResourceID(%q) = %+v, want %+v", test.resourceID, id, test.want)
+			}
+			if got := id.String(); got != test.resourceID {
+				t.Errorf("ParseResourceID(%q).String() = %q, want the same id", test.resourceID, got)
+			}
+			if got := test.want.String(); got != test.resourceID {
+				t.Errorf("built id String() = %q, want %q", got, test.resourceID)
+			}
+		})
+	}
+}
+
+func TestParseResourceIDKeywordsIgnoreCase(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		resourceID string
+	}{
+		{"lower case", "/subscriptions/" + testSubscriptionID + "/resourcegroups/anf-rg/providers/Microsoft.NetApp/netappaccounts/account1/capacitypools/pool1/volumes/volume1"},
+		{"upper case", "/SUBSCRIPTIONS/" + testSubscriptionID + "/RESOURCEGROUPS/anf-rg/PROVIDERS/MICROSOFT.NETAPP/NETAPPACCOUNTS/account1/CAPACITYPOOLS/pool1/VOLUMES/volume1"},
+		{"no leading slash", "subscriptions/" + testSubscriptionID + "/resourceGroups/anf-rg/providers/Microsoft.NetApp/netAppAccounts/account1/capacityPools/pool1/volumes/volume1"},
+		{"trailing slash", testVolumeID + "/"},
+		{"surrounding spaces", "  " + testVolumeID + "  "},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			id, err := ParseResourceID(test.resourceID)
+			if err != nil {
+				t.Fatalf("ParseResourceID(%q) returned error: %v", test.resourceID, err)
+			}
+			if id.SubscriptionID != testSubscriptionID || id.ResourceGroupName != "anf-rg" {
+				t.Errorf("ParseResourceID(%q) = %+v, want subscription %v and resource group anf-rg", test.resourceID, id, testSubscriptionID)
+			}
+			if !id.Is(netAppResourceProviderName, NetAppAccountsType, CapacityPoolsType, VolumesType) {
+				t.Errorf("ParseResourceID(%q) = %+v, want a volume id", test.resourceID, id)
+			}
+			if got := id.NameOf(CapacityPoolsType); got != "pool1" {
+				t.Errorf("NameOf(%v) = %q, want pool1", CapacityPoolsType, got)
+			}
+			if !IsAnfVolume(test.resourceID) || GetAnfVolume(test.resourceID) != "volume1" {
+				t.Errorf("IsAnfVolume/GetAnfVolume(%q) = %v/%q, want true/volume1", test.resourceID, IsAnfVolume(test.resourceID), GetAnfVolume(test.resourceID))
+			}
+		})
+	}
+}
+
+func TestParseResourceIDNamesMatchingKeywords(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		resourceID string
+		isVolume   bool
+		want       map[string]string
+	}{
+		{
+			name:       "capacity pool named volumes",
+			resourceID: testAccountID + "/capacityPools/volumes/volumes/volume1",
+			isVolume:   true,
+			want:       map[string]string{NetAppAccountsType: "account1", CapacityPoolsType: "volumes", VolumesType: "volume1"},
+		},
+		{
+			name:       "capacity pool named volumes without volume",
+			resourceID: testAccountID + "/capacityPools/volumes",
+			want:       map[string]string{NetAppAccountsType: "account1", CapacityPoolsType: "volumes", VolumesType: ""},
+		},
+		{
+			name:       "volume named snapshots",
+			resourceID: testPoolID + "/volumes/snapshots",
+			isVolume:   true,
+			want:       map[string]string{CapacityPoolsType: "pool1", VolumesType: "snapshots", SnapshotsType: ""},
+		},
+		{
+			name:       "resource group named like the account",
+			resourceID: "/subscriptions/" + testSubscriptionID + "/resourceGroups/account1/providers/Microsoft.NetApp/netAppAccounts/account1/capacityPools/pool1/volumes/volume1",
+			isVolume:   true,
+			want:       map[string]string{resourceGroupsSegment: "account1", NetAppAccountsType: "account1", CapacityPoolsType: "pool1", VolumesType: "volume1"},
+		},
+		{
+			name:       "resource group named like the volume",
+			resourceID: "/subscriptions/" + testSubscriptionID + "/resourceGroups/Volume1/providers/Microsoft.NetApp/netAppAccounts/account1/capacityPools/pool1/volumes/Volume1",
+			isVolume:   true,
+			want:       map[string]string{resourceGroupsSegment: "Volume1", NetAppAccountsType: "account1", VolumesType: "Volume1"},
+		},
+		{
+			name:       "resource group named providers",
+			resourceID: "/subscriptions/" + testSubscriptionID + "/resourceGroups/providers/providers/Microsoft.NetApp/netAppAccounts/account1/capacityPools/pool1/volumes/volume1",
+			isVolume:   true,
+			want:       map[string]string{resourceGroupsSegment: "providers", providersSegment: netAppResourceProviderName, VolumesType: "volume1"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			if _, err := ParseResourceID(test.resourceID); err != nil {
+				t.Fatalf("ParseResourceID(%q) returned error: %v", test.resourceID, err)
+			}
+			for key, want := range test.want {
+				if got := GetResourceValue(test.resourceID, key); got != want {
+					t.Errorf("GetResourceValue(%q, %q) = %q, want %q", test.resourceID, key, got, want)
+				}
+			}
+			if got := IsAnfVolume(test.resourceID); got != test.isVolume {
+				t.Errorf("IsAnfVolume(%q) = %v, want %v", test.resourceID, got, test.isVolume)
+			}
+		})
+	}
+}
+
+func TestParseResourceIDInvalid(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		resourceID string
+	}{
+		{"empty", ""},
+		{"blank", "   "},
+		{"root", "/"},
+		{"no subscription keyword", "/resourceGroups/anf-rg"},
+		{"subscription without id", "/subscriptions"},
+		{"subscription with empty id", "/subscriptions//resourceGroups/anf-rg"},
+		{"resource group without name", "/subscriptions/" + testSubscriptionID + "/resourceGroups"},
+		{"unknown segment after subscription", "/subscriptions/" + testSubscriptionID + "/locations/westus"},
+		{"provider without type", "/subscriptions/" + testSubscriptionID + "/resourceGroups/anf-rg/providers/Microsoft.NetApp"},
+		{"type without name", "/subscriptions/" + testSubscriptionID + "/resourceGroups/anf-rg/providers/Microsoft.NetApp/netAppAccounts"},
+		{"truncated child", testAccountID + "/capacityPools"},
+		{"unknown segment after resource group", "/subscriptions/" + testSubscriptionID + "/resourceGroups/anf-rg/deployments/d1"},
+		{"empty segment in the middle", testAccountID + "//capacityPools/pool1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			if id, err := ParseResourceID(test.resourceID); err == nil {
+				t.Errorf("ParseResourceID(%q) = %+v, want an error", test.resourceID, id)
+			}
+			if got := GetResourceName(test.resourceID); got != "" {
+				t.Errorf("GetResourceName(%q) = %q, want empty", test.resourceID, got)
+			}
+			if IsAnfResource(test.resourceID) || IsAnfAccount(test.resourceID) || IsAnfCapacityPool(test.resourceID) {
+				t.Errorf("Is* helpers report %q as an ANF resource", test.resourceID)
+			}
+		})
+	}
+}
+
+func TestResourceIDNavigation(t *testing.T) {
+
+	volumeID := VolumeID(testSubscriptionID, "anf-rg", "account1", "pool1", "volume1")
+
+	if got := volumeID.Parent().String(); got != testPoolID {
+		t.Errorf("Parent() = %q, want %q", got, testPoolID)
+	}
+	if got := volumeID.Parent().Parent().Parent().String(); got != "/subscriptions/"+testSubscriptionID+"/resourceGroups/anf-rg" {
+		t.Errorf("account Parent() = %q, want the resource group id", got)
+	}
+	if got := volumeID.Child(SnapshotsType, "snapshot1").String(); got != testSnapshotID {
+		t.Errorf("Child() = %q, want %q", got, testSnapshotID)
+	}
+	if got := volumeID.Type(); got != "Microsoft.NetApp/netAppAccounts/capacityPools/volumes" {
+		t.Errorf("Type() = %q, want Microsoft.NetApp/netAppAccounts/capacityPools/volumes", got)
+	}
+	if got := volumeID.Name(); got != "volume1" {
+		t.Errorf("Name() = %q, want volume1", got)
+	}
+
+	// Child and Parent must not share the backing arrays of the original id
+	snapshotA := volumeID.Child(SnapshotsType, "a")
+	snapshotB := volumeID.Child(SnapshotsType, "b")
+	if snapshotA.Name() != "a" || snapshotB.Name() != "b" || len(volumeID.Names) != 3 {
+		t.Errorf("Child() modified a sibling or the parent id: %+v %+v %+v", snapshotA, snapshotB, volumeID)
+	}
+}
+
+func TestHelpersCompatibility(t *testing.T) {
+
+	getters := []struct {
+		name       string
+		get        func(string) string
+		resourceID string
+		want       string
+	}{
+		{"GetSubscription", GetSubscription, testSnapshotID, testSubscriptionID},
+		{"GetResourceGroup", GetResourceGroup, testSnapshotID, "anf-rg"},
+		{"GetAnfAccount", GetAnfAccount, testSnapshotID, "account1"},
+		{"GetAnfCapacityPool", GetAnfCapacityPool, testSnapshotID, "pool1"},
+		{"GetAnfVolume", GetAnfVolume, testSnapshotID, "volume1"},
+		{"GetAnfSnapshot", GetAnfSnapshot, testSnapshotID, "snapshot1"},
+		{"GetAnfSnapshot of a volume", GetAnfSnapshot, testVolumeID, ""},
+		{"GetAnfSnapshotPolicy", GetAnfSnapshotPolicy, testAccountID + "/snapshotPolicies/policy1", "policy1"},
+		{"GetAnfBackupPolicy", GetAnfBackupPolicy, testAccountID + "/backupPolicies/policy1", "policy1"},
+		{"GetAnfBackup", GetAnfBackup, testVolumeID + "/backups/backup1", "backup1"},
+		{"GetAnfVolumeGroup", GetAnfVolumeGroup, testAccountID + "/volumeGroups/group1", "group1"},
+		{"GetAnfAccount of a subnet", GetAnfAccount, testSubnetID, ""},
+		{"GetResourceGroup of a subnet", GetResourceGroup, testSubnetID, "vnet-rg"},
+		{"GetResourceName of a snapshot", GetResourceName, testSnapshotID, "snapshot1"},
+		{"GetResourceName of a subnet", GetResourceName, testSubnetID, "subnet1"},
+		{"GetResourceName of a resource group", GetResourceName, "/subscriptions/" + testSubscriptionID + "/resourceGroups/anf-rg", "anf-rg"},
+		{"GetSubscription of an empty id", GetSubscription, "", ""},
+	}
+
+	for _, test := range getters {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.get(test.resourceID); got != test.want {
+				t.Errorf("%v(%q) = %q, want %q", test.name, test.resourceID, got, test.want)
+			}
+		})
+	}
+
+	// GetResourceValue keeps accepting resource names with or without a leading slash, as callers used both forms
+	values := []struct {
+		resourceName string
+		want         string
+	}{
+		{"/subscriptions", testSubscriptionID},
+		{"subscriptions", testSubscriptionID},
+		{"/resourceGroups", "anf-rg"},
+		{"resourcegroups", "anf-rg"},
+		{"/providers", netAppResourceProviderName},
+		{"/netAppAccounts", "account1"},
+		{"capacityPools", "pool1"},
+		{"/volumes", "volume1"},
+		{"/snapshots", "snapshot1"},
+		{"/backups", ""},
+		{"", ""},
+		{"  ", ""},
+	}
+
+	for _, test := range values {
+		t.Run("GetResourceValue "+test.resourceName, func(t *testing.T) {
+			if got := GetResourceValue(testSnapshotID, test.resourceName); got != test.want {
+				t.Errorf("GetResourceValue(%q, %q) = %q, want %q", testSnapshotID, test.resourceName, got, test.want)
+			}
+		})
+	}
+
+	classifiers := []struct {
+		resourceID string
+		want       []string
+	}{
+		{testAccountID, []string{"IsAnfResource", "IsAnfAccount"}},
+		{testPoolID, []string{"IsAnfResource", "IsAnfCapacityPool"}},
+		{testVolumeID, []string{"IsAnfResource", "IsAnfVolume"}},
+		{testSnapshotID, []string{"IsAnfResource", "IsAnfSnapshot"}},
+		{testAccountID + "/snapshotPolicies/policy1", []string{"IsAnfResource", "IsAnfSnapshotPolicy"}},
+		{testAccountID + "/backupPolicies/policy1", []string{"IsAnfResource", "IsAnfBackupPolicy"}},
+		{testVolumeID + "/backups/backup1", []string{"IsAnfResource", "IsAnfBackup"}},
+		{testAccountID + "/volumeGroups/group1", []string{"IsAnfResource", "IsAnfVolumeGroup"}},
+		{testSubnetID, nil},
+		{"", nil},
+	}
+	is := map[string]func(string) bool{
+		"IsAnfResource":       IsAnfResource,
+		"IsAnfAccount":        IsAnfAccount,
+		"IsAnfCapacityPool":   IsAnfCapacityPool,
+		"IsAnfVolume":         IsAnfVolume,
+		"IsAnfSnapshot":       IsAnfSnapshot,
+		"IsAnfSnapshotPolicy": IsAnfSnapshotPolicy,
+		"IsAnfBackupPolicy":   IsAnfBackupPolicy,
+		"IsAnfBackup":         IsAnfBackup,
+		"IsAnfVolumeGroup":    IsAnfVolumeGroup,
+	}
+
+	for _, test := range classifiers {
+		t.Run("classify "+test.resourceID, func(t *testing.T) {
+			want := map[string]bool{}
+			for _, name := range test.want {
+				want[name] = true
+			}
+			for name, classify := range is {
+				if got := classify(test.resourceID); got != want[name] {
+					t.Errorf("%v(%q) = %v, want %v", name, test.resourceID, got, want[name])
+				}
+			}
+		})
+	}
+}
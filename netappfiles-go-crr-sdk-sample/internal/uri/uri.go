@@ -1,220 +1,184 @@
-// Copyright (c) Microsoft and contributors.  All rights reserved.
-//
-// This source code is licensed under the MIT license found in the
-// LICENSE file in the root directory of this source tree.
-
-// This package provides some functions to parse a resource
-// id and return their names based on their type.
-// It also validates if a resource is of an specific type based
-// on provided id and finally to validate if it is an ANF related
-// resource.
-
-package uri
-
-import (
-	"fmt"
-	"strings"
-)
-
-const (
-	netAppResourceProviderName string = "Microsoft.NetApp"
-)
-
-// GetResourceValue returns the name of a resource from resource id/uri based on resource type name.
-func GetResourceValue(resourceURI string, resourceName string) string {
-
-	if len(strings.TrimSpace(resourceURI)) == 0 {
-		return ""
-	}
-
-	if len(strings.TrimSpace(resourceName)) == 0 {
-		return ""
-	}
-
-	if !strings.HasPrefix(resourceURI, "/") {
-		resourceURI = fmt.Sprintf("/%v", resourceURI)
-	}
-
-	if !strings.HasPrefix(resourceName, "/") {
-		resourceName = fmt.Sprintf("/%v", resourceName)
-	}
-
-	// Checks to see if the ResourceName and ResourceGroup is the same name and if so handles it specially.
-	rgResourceName := fmt.Sprintf("/resourceGroups%v", resourceName)
-	rgIndex := strings.Index(strings.ToLower(resourceURI), strings.ToLower(rgResourceName))
-
-	// Dealing with case where resource name is the same as resource group
-	if rgIndex > -1 {
-		removedSameRgName := strings.Split(strings.ToLower(resourceURI), strings.ToLower(resourceName))
-		return strings.Split(removedSameRgName[len(removedSameRgName)-1], "/")[1]
-	}
-
-	// Dealing with regular cases
-	index := strings.Index(strings.ToLower(resourceURI), strings.ToLower(resourceName))
-	if index > -1 {
-		resource := strings.Split(resourceURI[index+len(resourceName):], "/")
-		if len(resource) > 1 {
-			return resource[1]
-		}
-	}
-
-	return ""
-}
-
-// GetResourceName gets the resource name from resource id/uri
-func GetResourceName(resourceURI string) string {
-
-	if len(strings.TrimSpace(resourceURI)) == 0 {
-		return ""
-	}
-
-	position := strings.LastIndex(resourceURI, "/")
-	return resourceURI[position+1:]
-}
-
-// GetSubscription gets he subscription id from resource id/uri
-func GetSubscription(resourceURI string) string {
-
-	if len(strings.TrimSpace(resourceURI)) == 0 {
-		return ""
-	}
-
-	subscriptionID := GetResourceValue(resourceURI, "/subscriptions")
-	if subscriptionID == "" {
-		return ""
-	}
-
-	return subscriptionID
-}
-
-// GetResourceGroup gets the resource group name from resource id/uri
-func GetResourceGroup(resourceURI string) string {
-
-	if len(strings.TrimSpace(resourceURI)) == 0 {
-		return ""
-	}
-
-	resourceGroupName := GetResourceValue(resourceURI, "/resourceGroups")
-	if resourceGroupName == "" {
-		return ""
-	}
-
-	return resourceGroupName
-}
-
-// GetAnfAccount gets an account name from resource id/uri
-func GetAnfAccount(resourceURI string) string {
-
-	if len(strings.TrimSpace(resourceURI)) == 0 {
-		return ""
-	}
-
-	accountName := GetResourceValue(resourceURI, "/netAppAccounts")
-	if accountName == "" {
-		return ""
-	}
-
-	return accountName
-}
-
-// GetAnfCapacityPool gets pool name from resource id/uri
-func GetAnfCapacityPool(resourceURI string) string {
-
-	if len(strings.TrimSpace(resourceURI)) == 0 {
-		return ""
-	}
-
-	accountName := GetResourceValue(resourceURI, "/capacityPools")
-	if accountName == "" {
-		return ""
-	}
-
-	return accountName
-}
-
-// GetAnfVolume gets volume name from resource id/uri
-func GetAnfVolume(resourceURI string) string {
-
-	if len(strings.TrimSpace(resourceURI)) == 0 {
-		return ""
-	}
-
-	volumeName := GetResourceValue(resourceURI, "/volumes")
-	if volumeName == "" {
-		return ""
-	}
-
-	return volumeName
-}
-
-// GetAnfSnapshot gets snapshot name from resource id/uri
-func GetAnfSnapshot(resourceURI string) string {
-
-	if len(strings.TrimSpace(resourceURI)) == 0 {
-		return ""
-	}
-
-	snapshotName := GetResourceValue(resourceURI, "/snapshots")
-	if snapshotName == "" {
-		return ""
-	}
-
-	return snapshotName
-}
-
-// IsAnfResource checks if resource is an ANF related resource
-func IsAnfResource(resourceURI string) bool {
-
-	if len(strings.TrimSpace(resourceURI)) == 0 {
-		return false
-	}
-
-	return strings.Index(resourceURI, netAppResourceProviderName) > -1
-}
-
-// IsAnfSnapshot checks resource is a snapshot
-func IsAnfSnapshot(resourceURI string) bool {
-
-	if len(strings.TrimSpace(resourceURI)) == 0 || !IsAnfResource(resourceURI) {
-		return false
-	}
-
-	return strings.LastIndex(resourceURI, "/snapshots/") > -1
-}
-
-// IsAnfVolume checks resource is a volume
-func IsAnfVolume(resourceURI string) bool {
-
-	if len(strings.TrimSpace(resourceURI)) == 0 || !IsAnfResource(resourceURI) {
-		return false
-	}
-
-	return !IsAnfSnapshot(resourceURI) &&
-		strings.LastIndex(resourceURI, "/volumes/") > -1
-}
-
-// IsAnfCapacityPool checks resource is a capacity pool
-func IsAnfCapacityPool(resourceURI string) bool {
-
-	if len(strings.TrimSpace(resourceURI)) == 0 || !IsAnfResource(resourceURI) {
-		return false
-	}
-
-	return !IsAnfSnapshot(resourceURI) &&
-		!IsAnfVolume(resourceURI) &&
-		strings.LastIndex(resourceURI, "/capacityPools/") > -1
-}
-
-// IsAnfAccount checks resource is an account
-func IsAnfAccount(resourceURI string) bool {
-
-	if len(strings.TrimSpace(resourceURI)) == 0 || !IsAnfResource(resourceURI) {
-		return false
-	}
-
-	return !IsAnfSnapshot(resourceURI) &&
-		!IsAnfVolume(resourceURI) &&
-		!IsAnfCapacityPool(resourceURI) &&
-		strings.LastIndex(resourceURI, "/backupPolicies/") == -1 &&
-		strings.LastIndex(resourceURI, "/netAppAccounts/") > -1
-}
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// This package provides a resource id parser and builder, and
+// some functions to parse a resource id and return their names
+// based on their type. It also validates if a resource is of an
+// specific type based on provided id and finally to validate if
+// it is an ANF related resource.
+
+package uri
+
+import (
+	"strings"
+)
+
+const (
+	netAppResourceProviderName string = "Microsoft.NetApp"
+)
+
+// GetResourceValue returns the value that follows a segment keyword (subscriptions, resourceGroups, providers)
+// or the name of a resource type in a resource id/uri, empty if the id is invalid or has no such segment.
+func GetResourceValue(resourceURI string, resourceName string) string {
+
+	id, err := ParseResourceID(resourceURI)
+	if err != nil {
+		return ""
+	}
+
+	switch key := strings.Trim(strings.TrimSpace(resourceName), "/"); {
+	case key == "":
+		return ""
+	case strings.EqualFold(key, subscriptionsSegment):
+		return id.SubscriptionID
+	case strings.EqualFold(key, resourceGroupsSegment):
+		return id.ResourceGroupName
+	case strings.EqualFold(key, providersSegment):
+		return id.Provider
+	default:
+		return id.NameOf(key)
+	}
+}
+
+// GetResourceName gets the resource name from resource id/uri
+func GetResourceName(resourceURI string) string {
+
+	id, err := ParseResourceID(resourceURI)
+	if err != nil {
+		return ""
+	}
+
+	switch {
+	case id.Provider != "":
+		return id.Name()
+	case id.ResourceGroupName != "":
+		return id.ResourceGroupName
+	default:
+		return id.SubscriptionID
+	}
+}
+
+// GetSubscription gets he subscription id from resource id/uri
+func GetSubscription(resourceURI string) string {
+	return GetResourceValue(resourceURI, subscriptionsSegment)
+}
+
+// GetResourceGroup gets the resource group name from resource id/uri
+func GetResourceGroup(resourceURI string) string {
+	return GetResourceValue(resourceURI, resourceGroupsSegment)
+}
+
+// GetAnfAccount gets an account name from resource id/uri
+func GetAnfAccount(resourceURI string) string {
+	return getAnfResourceValue(resourceURI, NetAppAccountsType)
+}
+
+// GetAnfCapacityPool gets pool name from resource id/uri
+func GetAnfCapacityPool(resourceURI string) string {
+	return getAnfResourceValue(resourceURI, CapacityPoolsType)
+}
+
+// GetAnfVolume gets volume name from resource id/uri
+func GetAnfVolume(resourceURI string) string {
+	return getAnfResourceValue(resourceURI, VolumesType)
+}
+
+// GetAnfSnapshot gets snapshot name from resource id/uri
+func GetAnfSnapshot(resourceURI string) string {
+	return getAnfResourceValue(resourceURI, SnapshotsType)
+}
+
+// GetAnfSnapshotPolicy gets snapshot policy name from resource id/uri
+func GetAnfSnapshotPolicy(resourceURI string) string {
+	return getAnfResourceValue(resourceURI, SnapshotPoliciesType)
+}
+
+// GetAnfBackupPolicy gets backup policy name from resource id/uri
+func GetAnfBackupPolicy(resourceURI string) string {
+	return getAnfResourceValue(resourceURI, BackupPoliciesType)
+}
+
+// GetAnfBackup gets backup name from resource id/uri
+func GetAnfBackup(resourceURI string) string {
+	return getAnfResourceValue(resourceURI, BackupsType)
+}
+
+// GetAnfVolumeGroup gets volume group name from resource id/uri
+func GetAnfVolumeGroup(resourceURI string) string {
+	return getAnfResourceValue(resourceURI, VolumeGroupsType)
+}
+
+// getAnfResourceValue gets the name of an ANF resource type from resource id/uri, empty if it is not an ANF resource
+func getAnfResourceValue(resourceURI, resourceType string) string {
+
+	id, err := ParseResourceID(resourceURI)
+	if err != nil || !strings.EqualFold(id.Provider, netAppResourceProviderName) {
+		return ""
+	}
+
+	return id.NameOf(resourceType)
+}
+
+// IsAnfResource checks if resource is an ANF related resource
+func IsAnfResource(resourceURI string) bool {
+
+	id, err := ParseResourceID(resourceURI)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(id.Provider, netAppResourceProviderName)
+}
+
+// IsAnfSnapshot checks resource is a snapshot
+func IsAnfSnapshot(resourceURI string) bool {
+	return isAnfResourceOfType(resourceURI, NetAppAccountsType, CapacityPoolsType, VolumesType, SnapshotsType)
+}
+
+// IsAnfVolume checks resource is a volume
+func IsAnfVolume(resourceURI string) bool {
+	return isAnfResourceOfType(resourceURI, NetAppAccountsType, CapacityPoolsType, VolumesType)
+}
+
+// IsAnfCapacityPool checks resource is a capacity pool
+func IsAnfCapacityPool(resourceURI string) bool {
+	return isAnfResourceOfType(resourceURI, NetAppAccountsType, CapacityPoolsType)
+}
+
+// IsAnfAccount checks resource is an account
+func IsAnfAccount(resourceURI string) bool {
+	return isAnfResourceOfType(resourceURI, NetAppAccountsType)
+}
+
+// IsAnfSnapshotPolicy checks resource is a snapshot policy
+func IsAnfSnapshotPolicy(resourceURI string) bool {
+	return isAnfResourceOfType(resourceURI, NetAppAccountsType, SnapshotPoliciesType)
+}
+
+// IsAnfBackupPolicy checks resource is a backup policy
+func IsAnfBackupPolicy(resourceURI string) bool {
+	return isAnfResourceOfType(resourceURI, NetAppAccountsType, BackupPoliciesType)
+}
+
+// IsAnfBackup checks resource is a volume backup
+func IsAnfBackup(resourceURI string) bool {
+	return isAnfResourceOfType(resourceURI, NetAppAccountsType, CapacityPoolsType, VolumesType, BackupsType)
+}
+
+// IsAnfVolumeGroup checks resource is a volume group
+func IsAnfVolumeGroup(resourceURI string) bool {
+	return isAnfResourceOfType(resourceURI, NetAppAccountsType, VolumeGroupsType)
+}
+
+// isAnfResourceOfType checks if a resource id is exactly of an ANF type chain
+func isAnfResourceOfType(resourceURI string, types ...string) bool {
+
+	id, err := ParseResourceID(resourceURI)
+	if err != nil {
+		return false
+	}
+
+	return id.Is(netAppResourceProviderName, types...)
+}
@@ -0,0 +1,228 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Replication lag (RPO) monitoring, polls the replication status of
+// the destination volume on a schedule, works out the time since the
+// last successful transfer and raises an alert through a notifier
+// when it goes over the topology threshold.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/config"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/notify"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/utils"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/netapp/mgmt/netapp"
+)
+
+const (
+	// Snapshots created by the replication on the destination volume start with this prefix
+	replicatedSnapshotPrefix string = "snapmirror."
+)
+
+type (
+	// lagMonitor - replication lag tracking of a destination volume
+	lagMonitor struct {
+		side                   string
+		threshold              time.Duration
+		notifier               notify.Notifier
+		lastTransfer           time.Time
+		lastRelationshipStatus string
+		alertType              string
+	}
+)
+
+// monitor polls replication lag of the destination volume and raises alerts when it goes over the threshold
+func monitor(cntx context.Context, args []string) {
+
+	flags := flag.NewFlagSet("monitor", flag.ExitOnError)
+	interval := flags.Duration("interval", 5*time.Minute, "polling interval")
+	notifierType := flags.String("notifier", "stdout", "alert notifier: stdout, webhook or file")
+	webhookURL := flags.String("webhook-url", "", "url alerts are posted to when notifier is webhook")
+	alertsFile := flags.String("alerts-file", "", "file alerts are appended to when notifier is file")
+	once := flags.Bool("once", false, "checks replication lag once and exits, exit code is 1 if an alert is raised")
+	flags.Parse(args)
+
+	var notifier notify.Notifier
+	switch *notifierType {
+	case "stdout":
+		notifier = notify.NewStdoutNotifier()
+	case "webhook":
+		if *webhookURL == "" {
+			utils.ConsoleOutput("error: -webhook-url is required when notifier is webhook")
+			exitCode = 1
+			return
+		}
+		notifier = notify.NewWebhookNotifier(*webhookURL)
+	case "file":
+		if *alertsFile == "" {
+			utils.ConsoleOutput("error: -alerts-file is required when notifier is file")
+			exitCode = 1
+			return
+		}
+		notifier = notify.NewFileNotifier(*alertsFile)
+	default:
+		utils.ConsoleOutput(fmt.Sprintf("error: invalid notifier %q, valid notifiers are: stdout, webhook, file", *notifierType))
+		exitCode = 1
+		return
+	}
+
+	resolveResourceIDs()
+
+	ctx, stop := signal.NotifyContext(cntx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Lag is unknown, and alerted on, until a replicated snapshot or a transfer is observed
+	destination := &lagMonitor{
+		side:      "Secondary",
+		threshold: config.GetRPOThreshold(topology),
+		notifier:  notifier,
+	}
+	utils.ConsoleOutput(fmt.Sprintf("Monitoring replication lag of %v volume every %v, threshold is %v", anfResources[destination.side].VolumeName, *interval, destination.threshold))
+
+	for {
+		destination.check(ctx)
+
+		if *once {
+			if destination.alertType != "" {
+				exitCode = 1
+			}
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			utils.ConsoleOutput("Monitoring stopped")
+			return
+		case <-time.After(*interval):
+		}
+	}
+}
+
+// check works out the current replication lag and notifies when an alert is raised, changes or clears.
+// Lag over threshold, lag that cannot be worked out and an unhealthy replication all raise an alert.
+func (m *lagMonitor) check(ctx context.Context) {
+
+	now := time.Now()
+	health := getReplicationHealth(ctx, m.side)
+
+	// Latest replicated snapshot is the most accurate last transfer time
+	snapshotTime, snapshotErr := getLatestReplicatedSnapshotTime(ctx, m.side)
+	if snapshotErr != nil {
+		utils.ConsoleOutput(fmt.Sprintf("an error ocurred while listing replicated snapshots: %v", snapshotErr))
+	} else if snapshotTime.After(m.lastTransfer) {
+		m.lastTransfer = snapshotTime
+	}
+
+	// A transfer finishing between two checks also counts as a successful transfer
+	if m.lastRelationshipStatus == string(netapp.RelationshipStatusTransferring) &&
+		health.RelationshipStatus == string(netapp.RelationshipStatusIdle) &&
+		health.Healthy {
+		m.lastTransfer = now
+	}
+	m.lastRelationshipStatus = health.RelationshipStatus
+
+	lag, lastTransfer := "unknown", "unknown"
+	if !m.lastTransfer.IsZero() {
+		lag = now.Sub(m.lastTransfer).Round(time.Second).String()
+		lastTransfer = m.lastTransfer.UTC().Format(time.RFC3339)
+	}
+	utils.ConsoleOutput(fmt.Sprintf("%v volume: healthy: %v, mirror state: %v, relationship status: %v, last transfer: %v, lag: %v",
+		anfResources[m.side].VolumeName,
+		health.Healthy,
+		health.MirrorState,
+		health.RelationshipStatus,
+		lastTransfer,
+		lag,
+	))
+
+	var alertType, message string
+	switch {
+	case !health.Healthy:
+		alertType = notify.ReplicationUnhealthy
+		message = "replication is not healthy"
+		if health.ErrorMessage != "" {
+			message = fmt.Sprintf("%v, replication error: %v", message, health.ErrorMessage)
+		}
+	case snapshotErr != nil:
+		alertType = notify.LagUnknown
+		message = fmt.Sprintf("replication lag cannot be worked out, replicated snapshots cannot be listed: %v", snapshotErr)
+	case m.lastTransfer.IsZero():
+		alertType = notify.LagUnknown
+		message = "replication lag cannot be worked out, no replicated snapshot or transfer was observed"
+	case now.Sub(m.lastTransfer) > m.threshold:
+		alertType = notify.LagExceeded
+		message = fmt.Sprintf("replication lag %v is over threshold %v", lag, m.threshold)
+	}
+
+	// Alerts are only sent when they change, a cleared alert is followed by a recovery alert
+	switch {
+	case alertType == m.alertType:
+		return
+	case alertType == "":
+		alertType = notify.LagRecovered
+		message = fmt.Sprintf("replication is healthy and lag %v is back under threshold %v", lag, m.threshold)
+		m.alertType = ""
+	default:
+		m.alertType = alertType
+	}
+
+	alert := notify.Alert{
+		Type:      alertType,
+		VolumeID:  anfResources[m.side].VolumeID,
+		Lag:       lag,
+		Threshold: m.threshold.String(),
+		Message:   message,
+		Time:      now.UTC(),
+	}
+	if !m.lastTransfer.IsZero() {
+		alert.LastTransfer = m.lastTransfer.UTC()
+	}
+
+	err := m.notifier.Notify(ctx, alert)
+	if err != nil {
+		utils.ConsoleOutput(fmt.Sprintf("an error ocurred while sending %v alert: %v", alertType, err))
+	}
+}
+
+// getLatestReplicatedSnapshotTime returns the creation time of the newest replicated snapshot of a side's volume
+func getLatestReplicatedSnapshotTime(ctx context.Context, side string) (time.Time, error) {
+
+	snapshots, err := anfServices[side].ListAnfSnapshots(
+		ctx,
+		anfResources[side].ResourceGroupName,
+		anfResources[side].AnfAccountName,
+		anfResources[side].CapacityPoolName,
+		anfResources[side].VolumeName,
+	)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var latest time.Time
+	for _, snapshot := range snapshots {
+		if snapshot.Name == nil || snapshot.SnapshotProperties == nil || snapshot.Created == nil {
+			continue
+		}
+		if !strings.HasPrefix(path.Base(*snapshot.Name), replicatedSnapshotPrefix) {
+			continue
+		}
+		if snapshot.Created.Time.After(latest) {
+			latest = snapshot.Created.Time
+		}
+	}
+
+	return latest, nil
+}
@@ -0,0 +1,115 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/notify"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/sdkutils"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/netapp/mgmt/netapp"
+)
+
+// failingSnapshots fails to list snapshots
+type failingSnapshots struct {
+	sdkutils.SnapshotsAPI
+}
+
+func (failingSnapshots) List(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string) ([]netapp.Snapshot, error) {
+	return nil, errors.New("snapshots unavailable")
+}
+
+// failingReplicationStatus fails to get the replication status
+type failingReplicationStatus struct {
+	sdkutils.VolumesAPI
+}
+
+func (failingReplicationStatus) ReplicationStatus(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string) (netapp.ReplicationStatus, error) {
+	return netapp.ReplicationStatus{}, errors.New("replication status unavailable")
+}
+
+// readAlerts reads the alerts appended to a file by the file notifier
+func readAlerts(t *testing.T, alertsFile string) []notify.Alert {
+
+	t.Helper()
+
+	content, err := ioutil.ReadFile(alertsFile)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatalf("cannot read alerts: %v", err)
+	}
+
+	var alerts []notify.Alert
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		if line == "" {
+			continue
+		}
+		var alert notify.Alert
+		if err := json.Unmarshal([]byte(line), &alert); err != nil {
+			t.Fatalf("cannot parse alert %q: %v", line, err)
+		}
+		alerts = append(alerts, alert)
+	}
+
+	return alerts
+}
+
+func TestMonitorOnce(t *testing.T) {
+
+	tests := []struct {
+		name          string
+		transferSteps int
+		breakService  func(service *sdkutils.Service)
+		alertType     string
+	}{
+		{name: "lag under threshold", transferSteps: 1},
+		{name: "no transfer observed", transferSteps: 1000000, alertType: notify.LagUnknown},
+		{name: "snapshots cannot be listed", transferSteps: 1, alertType: notify.LagUnknown, breakService: func(service *sdkutils.Service) {
+			service.Snapshots = failingSnapshots{service.Snapshots}
+		}},
+		{name: "replication status cannot be retrieved", transferSteps: 1, alertType: notify.ReplicationUnhealthy, breakService: func(service *sdkutils.Service) {
+			service.Volumes = failingReplicationStatus{service.Volumes}
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			backend := newFakeSample(t)
+			backend.TransferSteps = test.transferSteps
+			runCommand(t, setup)
+
+			if test.breakService != nil {
+				service := *anfServices["Secondary"]
+				test.breakService(&service)
+				anfServices["Secondary"] = &service
+			}
+
+			alertsFile := filepath.Join(t.TempDir(), "alerts.jsonl")
+			monitor(context.Background(), []string{"-once", "-notifier", "file", "-alerts-file", alertsFile})
+
+			alerts := readAlerts(t, alertsFile)
+			if test.alertType == "" {
+				if exitCode != 0 || len(alerts) != 0 {
+					t.Fatalf("monitor exited with code %v and raised %v alerts, want 0 and none", exitCode, len(alerts))
+				}
+				return
+			}
+			if exitCode == 0 {
+				t.Errorf("monitor raising a %v alert exited with code 0", test.alertType)
+			}
+			if len(alerts) != 1 || alerts[0].Type != test.alertType {
+				t.Fatalf("monitor raised %+v, want a single %v alert", alerts, test.alertType)
+			}
+		})
+	}
+}
@@ -0,0 +1,393 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/fake"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/models"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/poll"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/sdkutils"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/state"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/uri"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/netapp/mgmt/netapp"
+	"github.com/Azure/go-autorest/autorest/to"
+)
+
+const testSubscriptionID = "00000000-0000-0000-0000-000000000000"
+
+// newFakeSample points the sample globals at an in-memory backend with the subnets of a test topology,
+// waits are shortened so commands run in milliseconds
+func newFakeSample(t *testing.T) *fake.Backend {
+
+	t.Helper()
+
+	initialWait := poll.InitialWait
+	poll.InitialWait = time.Millisecond
+	t.Cleanup(func() { poll.InitialWait = initialWait })
+
+	directory := t.TempDir()
+	topologyLocation = filepath.Join(directory, "topology.yaml")
+	topology = &models.Topology{
+		ReplicationSchedule: "10minutely",
+		Primary: &models.Properties{
+			Location:              "westus",
+			ResourceGroupName:     "anf-primary-rg",
+			VnetResourceGroupName: "anf-primary-rg",
+			VnetName:              "westus-primary-vnet",
+			SubnetName:            "anf-primary-sn",
+			AnfAccountName:        "PrimaryANFAccount",
+			CapacityPoolName:      "PrimaryPool",
+			VolumeName:            "PrimaryVolume",
+			ServiceLevel:          "Premium",
+		},
+		Secondary: &models.Properties{
+			Location:              "eastus",
+			ResourceGroupName:     "anf-secondary-rg",
+			VnetResourceGroupName: "anf-secondary-rg",
+			VnetName:              "eastus-secondary-vnet",
+			SubnetName:            "anf-secondary-sn",
+			AnfAccountName:        "SecondaryANFAccount",
+			CapacityPoolName:      "SecondaryPool",
+			VolumeName:            "SecondaryVolume",
+			ServiceLevel:          "Standard",
+		},
+	}
+	anfResources = map[string]*models.Properties{
+		"Primary":   topology.Primary,
+		"Secondary": topology.Secondary,
+	}
+
+	var err error
+	deploymentState, err = state.Load(filepath.Join(directory, "topology.state.json"))
+	if err != nil {
+		t.Fatalf("cannot load state: %v", err)
+	}
+
+	backend := fake.New(testSubscriptionID)
+	backend.TransferSteps = 1
+	service := backend.Service()
+	for _, side := range []string{"Primary", "Secondary"} {
+		subscriptionIDs[side] = testSubscriptionID
+		anfServices[side] = service
+		backend.AddResource(getSubnetID(side))
+	}
+
+	exitCode = 0
+
+	return backend
+}
+
+// runCommand runs a command and fails the test if it sets a non-zero exit code
+func runCommand(t *testing.T, run func(cntx context.Context, args []string), args ...string) {
+
+	t.Helper()
+
+	run(context.Background(), args)
+	if exitCode != 0 {
+		t.Fatalf("command with args %v exited with code %v", args, exitCode)
+	}
+}
+
+// assertReplicationState checks the mirror state and relationship status of the replication a volume takes part in
+func assertReplicationState(t *testing.T, backend *fake.Backend, volumeID string, mirrorState netapp.MirrorState, relationshipStatus netapp.RelationshipStatus) {
+
+	t.Helper()
+
+	gotMirrorState, gotRelationshipStatus, found := backend.ReplicationState(volumeID)
+	if !found {
+		t.Fatalf("volume %v has no replication", volumeID)
+	}
+	if gotMirrorState != mirrorState || gotRelationshipStatus != relationshipStatus {
+		t.Fatalf("replication of %v is %v/%v, want %v/%v", uri.GetAnfVolume(volumeID), gotMirrorState, gotRelationshipStatus, mirrorState, relationshipStatus)
+	}
+}
+
+// countResources counts the backend resources of each ANF type, e.g. volumes or snapshots
+func countResources(backend *fake.Backend) map[string]int {
+
+	counts := map[string]int{}
+	for _, resourceID := range backend.ResourceIDs() {
+		id, err := uri.ParseResourceID(resourceID)
+		if err != nil {
+			continue
+		}
+		counts[id.Types[len(id.Types)-1]]++
+	}
+
+	return counts
+}
+
+func TestSetupFailoverFailbackTeardown(t *testing.T) {
+
+	backend := newFakeSample(t)
+
+	// Setup creates both sides and authorizes the replication
+	runCommand(t, setup)
+
+	counts := countResources(backend)
+	for _, resourceType := range []string{uri.NetAppAccountsType, uri.CapacityPoolsType, uri.VolumesType} {
+		if counts[resourceType] != 2 {
+			t.Errorf("setup created %v %v, want 2", counts[resourceType], resourceType)
+		}
+	}
+	for _, name := range []string{stepName("Primary", volumeStep), stepName("Secondary", volumeStep), replicationStep} {
+		if _, completed := deploymentState.Completed(name); !completed {
+			t.Errorf("setup did not record step %v", name)
+		}
+	}
+
+	secondaryVolumeID := anfResources["Secondary"].VolumeID
+	if _, _, found := backend.ReplicationState(secondaryVolumeID); !found {
+		t.Fatalf("setup did not create the replication of %v", secondaryVolumeID)
+	}
+
+	// A second setup resumes from state and creates nothing
+	runCommand(t, setup)
+	if got := countResources(backend)[uri.VolumesType]; got != 2 {
+		t.Errorf("resumed setup left %v volumes, want 2", got)
+	}
+
+	// Failover waits for the transfer, takes a safety snapshot and breaks the replication
+	runCommand(t, failover)
+	assertReplicationState(t, backend, secondaryVolumeID, netapp.MirrorStateBroken, netapp.RelationshipStatusIdle)
+
+	snapshots, err := getSnapshots(context.Background(), "Secondary")
+	if err != nil {
+		t.Fatalf("cannot list snapshots: %v", err)
+	}
+	var safetySnapshots int
+	for _, snapshot := range snapshots {
+		if snapshot.tag == preFailoverSnapshotTag {
+			safetySnapshots++
+		}
+	}
+	if safetySnapshots != 1 {
+		t.Errorf("failover took %v safety snapshots, want 1", safetySnapshots)
+	}
+
+	// Failback in the original direction mirrors the replication again
+	runCommand(t, failback, "-direction", originalDirection)
+	assertReplicationState(t, backend, secondaryVolumeID, netapp.MirrorStateMirrored, netapp.RelationshipStatusIdle)
+
+	// Teardown removes every resource and step
+	runCommand(t, teardown)
+	if resourceIDs := backend.ResourceIDs(); len(resourceIDs) != 0 {
+		t.Errorf("teardown left resources: %v", strings.Join(resourceIDs, ", "))
+	}
+	if !deploymentState.IsEmpty() {
+		t.Errorf("teardown left steps in state file %v", deploymentState.Path())
+	}
+}
+
+func TestFailoverRequiresMirroredReplication(t *testing.T) {
+
+	backend := newFakeSample(t)
+	backend.TransferSteps = 1000000
+
+	runCommand(t, setup)
+
+	// Transfer never completes, the failover pre-check must give up without breaking the replication
+	cntx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	failover(cntx, []string{"-skip-snapshot"})
+	if exitCode == 0 {
+		t.Fatalf("failover of a replication still transferring exited with code 0")
+	}
+	if _, _, found := backend.ReplicationState(anfResources["Secondary"].VolumeID); !found {
+		t.Fatalf("resumed setup did not create the replication of %v", anfResources["Secondary"].VolumeID)
+	}
+}
+
+// lingeringVolumes accepts volume deletions without removing the volumes
+type lingeringVolumes struct {
+	sdkutils.VolumesAPI
+}
+
+func (lingeringVolumes) Delete(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string) error {
+	return nil
+}
+
+func TestTeardownFailsWhileVolumeRemains(t *testing.T) {
+
+	newFakeSample(t)
+	runCommand(t, setup)
+
+	service := *anfServices["Secondary"]
+	service.Volumes = lingeringVolumes{service.Volumes}
+	anfServices["Secondary"] = &service
+
+	// Waiting for the volume to be gone gives up, the volume is still recorded and nothing else is removed
+	cntx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	teardown(cntx, nil)
+	if exitCode == 0 {
+		t.Fatalf("teardown of a volume that is not removed exited with code 0")
+	}
+	for _, name := range []string{stepName("Secondary", volumeStep), stepName("Secondary", capacityPoolStep), stepName("Primary", volumeStep)} {
+		if _, completed := deploymentState.Completed(name); !completed {
+			t.Errorf("teardown removed step %v", name)
+		}
+	}
+}
+
+// failingVolumeCreation fails to create volumes
+type failingVolumeCreation struct {
+	sdkutils.VolumesAPI
+}
+
+func (failingVolumeCreation) CreateOrUpdate(ctx context.Context, body netapp.Volume, resourceGroupName, accountName, poolName, volumeName string) (netapp.Volume, error) {
+	return netapp.Volume{}, errors.New("volume creation unavailable")
+}
+
+// unexpectedAccountCreation fails if an account is created again
+type unexpectedAccountCreation struct {
+	sdkutils.AccountsAPI
+}
+
+func (unexpectedAccountCreation) CreateOrUpdate(ctx context.Context, body netapp.Account, resourceGroupName, accountName string) (netapp.Account, error) {
+	return netapp.Account{}, errors.New("account created again")
+}
+
+// unexpectedPoolCreation fails if a capacity pool is created again
+type unexpectedPoolCreation struct {
+	sdkutils.PoolsAPI
+}
+
+func (unexpectedPoolCreation) CreateOrUpdate(ctx context.Context, body netapp.CapacityPool, resourceGroupName, accountName, poolName string) (netapp.CapacityPool, error) {
+	return netapp.CapacityPool{}, errors.New("capacity pool created again")
+}
+
+func TestSetupResumesAfterFailure(t *testing.T) {
+
+	backend := newFakeSample(t)
+	service := anfServices["Secondary"]
+
+	failing := *service
+	failing.Volumes = failingVolumeCreation{failing.Volumes}
+	anfServices["Secondary"] = &failing
+
+	// The secondary volume cannot be created, everything created before it is recorded
+	setup(context.Background(), nil)
+	if exitCode == 0 {
+		t.Fatalf("setup with a failing volume creation exited with code 0")
+	}
+	for _, name := range []string{stepName("Primary", accountStep), stepName("Primary", capacityPoolStep), stepName("Primary", volumeStep), stepName("Secondary", accountStep), stepName("Secondary", capacityPoolStep)} {
+		if _, completed := deploymentState.Completed(name); !completed {
+			t.Errorf("failed setup did not record step %v", name)
+		}
+	}
+	for _, name := range []string{stepName("Secondary", volumeStep), replicationStep} {
+		if _, completed := deploymentState.Completed(name); completed {
+			t.Errorf("failed setup recorded step %v", name)
+		}
+	}
+
+	// A new execution loads the resource ids from the state file, as main does
+	var err error
+	deploymentState, err = state.Load(deploymentState.Path())
+	if err != nil {
+		t.Fatalf("cannot reload state: %v", err)
+	}
+	for _, side := range []string{"Primary", "Secondary"} {
+		anfResources[side].AccountID, _ = deploymentState.Completed(stepName(side, accountStep))
+		anfResources[side].CapacityPoolID, _ = deploymentState.Completed(stepName(side, capacityPoolStep))
+		anfResources[side].VolumeID, _ = deploymentState.Completed(stepName(side, volumeStep))
+	}
+
+	// The resumed setup only creates the secondary volume and the replication
+	for _, side := range []string{"Primary", "Secondary"} {
+		resumed := *service
+		resumed.Accounts = unexpectedAccountCreation{resumed.Accounts}
+		resumed.Pools = unexpectedPoolCreation{resumed.Pools}
+		anfServices[side] = &resumed
+	}
+	exitCode = 0
+
+	runCommand(t, setup)
+	for _, name := range []string{stepName("Secondary", volumeStep), replicationStep} {
+		if _, completed := deploymentState.Completed(name); !completed {
+			t.Errorf("resumed setup did not record step %v", name)
+		}
+	}
+	if _, _, found := backend.ReplicationState(anfResources["Secondary"].VolumeID); !found {
+		t.Fatalf("resumed setup did not create the replication of %v", anfResources["Secondary"].VolumeID)
+	}
+}
+
+// snapshotPolicyOf returns the snapshot policy attached to a side's volume, empty if there is none
+func snapshotPolicyOf(t *testing.T, side string) string {
+
+	t.Helper()
+
+	volume, err := anfServices[side].GetAnfVolume(context.Background(), anfResources[side].ResourceGroupName, anfResources[side].AnfAccountName, anfResources[side].CapacityPoolName, anfResources[side].VolumeName)
+	if err != nil {
+		t.Fatalf("cannot get %v volume: %v", side, err)
+	}
+	if volume.DataProtection == nil || volume.DataProtection.Snapshot == nil {
+		return ""
+	}
+	return to.String(volume.DataProtection.Snapshot.SnapshotPolicyID)
+}
+
+func TestSnapshotPolicyAttach(t *testing.T) {
+
+	for _, attachToDestination := range []bool{false, true} {
+		t.Run(fmt.Sprintf("attachToDestination %v", attachToDestination), func(t *testing.T) {
+
+			backend := newFakeSample(t)
+			topology.SnapshotPolicy = &models.SnapshotPolicy{
+				Name:                "daily",
+				Daily:               &models.SnapshotSchedule{SnapshotsToKeep: 7, Hour: 2},
+				AttachToDestination: attachToDestination,
+			}
+
+			// Setup creates the policy on both accounts and attaches it to the source volume only
+			runCommand(t, setup)
+
+			if got := countResources(backend)[uri.SnapshotPoliciesType]; got != 2 {
+				t.Errorf("setup created %v snapshot policies, want 2", got)
+			}
+			for _, side := range []string{"Primary", "Secondary"} {
+				if _, completed := deploymentState.Completed(stepName(side, snapshotPolicyStep)); !completed {
+					t.Errorf("setup did not record step %v", stepName(side, snapshotPolicyStep))
+				}
+			}
+			if got := snapshotPolicyOf(t, "Primary"); !strings.EqualFold(got, anfResources["Primary"].SnapshotPolicyID) {
+				t.Errorf("primary volume snapshot policy is %q, want %v", got, anfResources["Primary"].SnapshotPolicyID)
+			}
+			if got := snapshotPolicyOf(t, "Secondary"); got != "" {
+				t.Errorf("destination volume snapshot policy is %v before failover", got)
+			}
+
+			// Failover attaches the secondary account policy to the writable volume only when asked to
+			runCommand(t, failover, "-skip-snapshot")
+
+			want := ""
+			if attachToDestination {
+				want = anfResources["Secondary"].SnapshotPolicyID
+			}
+			if got := snapshotPolicyOf(t, "Secondary"); !strings.EqualFold(got, want) {
+				t.Errorf("secondary volume snapshot policy is %q after failover, want %q", got, want)
+			}
+
+			// Teardown removes the policies along with everything else
+			runCommand(t, teardown)
+			if resourceIDs := backend.ResourceIDs(); len(resourceIDs) != 0 {
+				t.Errorf("teardown left resources: %v", strings.Join(resourceIDs, ", "))
+			}
+		})
+	}
+}
@@ -0,0 +1,360 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Reverse replication direction, after a disaster the secondary
+// volume becomes the replication source and the primary volume is
+// recreated as its data protection destination. Roles are swapped
+// in topology and state files so later commands use the new roles.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/config"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/sdkutils"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/utils"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/netapp/mgmt/netapp"
+)
+
+const (
+	// Reverse steps recorded in state file until roles are swapped, so a failed reverse resumes where it stopped
+	reverseReplicationStep  string = "reverse/replicationRemoved"
+	reverseDeleteVolumeStep string = "reverse/primaryVolumeDeleted"
+	reverseCreateVolumeStep string = "reverse/primaryVolumeCreated"
+	reverseAuthorizeStep    string = "reverse/replicationAuthorized"
+	reverseRolesSwappedStep string = "reverse/rolesSwapped"
+)
+
+var (
+	reverseSteps = []string{reverseReplicationStep, reverseDeleteVolumeStep, reverseCreateVolumeStep, reverseAuthorizeStep, reverseRolesSwappedStep}
+)
+
+// reverse swaps source and destination roles of the replication. Each step is recorded in state file
+// and checks the current resources before acting, so running it again after a failure resumes the reverse.
+func reverse(cntx context.Context, args []string) {
+
+	flags := flag.NewFlagSet("reverse", flag.ExitOnError)
+	yes := flags.Bool("yes", false, "skips the confirmation prompt")
+	skipSnapshot := flags.Bool("skip-snapshot", false, "skips the safety snapshot of the secondary volume")
+	flags.Parse(args)
+
+	resolveResourceIDs()
+
+	// Replication is already reversed when roles are swapped in state file, only the topology file may be left
+	if _, completed := deploymentState.Completed(reverseRolesSwappedStep); completed {
+		utils.ConsoleOutput(fmt.Sprintf("Resuming reverse from state file %v, roles already swapped", deploymentState.Path()))
+		completeReverse()
+		return
+	}
+
+	primary := anfResources["Primary"]
+	secondary := anfResources["Secondary"]
+
+	utils.ConsoleOutput(fmt.Sprintf("WARNING: primary volume %v will be deleted and recreated as a data protection destination of secondary volume %v, all data on the primary volume will be lost", primary.VolumeID, secondary.VolumeID))
+	if !*yes && !utils.GetConfirmation("Do you want to reverse the replication direction?") {
+		utils.ConsoleOutput("Reverse cancelled")
+		exitCode = 1
+		return
+	}
+
+	// Roles are swapped in topology file once the primary volume is recreated, it is too late to find out it cannot be edited
+	err := config.CheckTopologyRoles(topologyLocation)
+	if err != nil {
+		utils.ConsoleOutput(fmt.Sprintf("an error ocurred while checking topology file: %v", err))
+		exitCode = 1
+		return
+	}
+
+	for _, step := range reverseSteps {
+		if _, completed := deploymentState.Completed(step); completed {
+			utils.ConsoleOutput(fmt.Sprintf("Resuming reverse from state file %v", deploymentState.Path()))
+			break
+		}
+	}
+
+	// Current replication must be broken and removed from secondary volume
+	if _, completed := deploymentState.Completed(reverseReplicationStep); completed {
+		utils.ConsoleOutput(fmt.Sprintf("Data protection object already removed from %v volume", secondary.VolumeName))
+	} else {
+		replicationStatus, err := anfServices["Secondary"].GetAnfVolumeReplicationStatus(
+			cntx,
+			secondary.ResourceGroupName,
+			secondary.AnfAccountName,
+			secondary.CapacityPoolName,
+			secondary.VolumeName,
+		)
+		if err != nil && !sdkutils.IsReplicationMissingError(err) {
+			utils.ConsoleOutput(fmt.Sprintf("an error ocurred while getting replication status: %v", err))
+			exitCode = 1
+			return
+		}
+
+		if err == nil {
+			if replicationStatus.MirrorState == netapp.MirrorStateMirrored {
+				if !*skipSnapshot {
+					err = takeSafetySnapshot(cntx, preReverseSnapshotTag)
+					if err != nil {
+						utils.ConsoleOutput(fmt.Sprintf("an error ocurred while taking safety snapshot: %v", err))
+						exitCode = 1
+						return
+					}
+				}
+
+				err = breakReplication(cntx)
+				if err != nil {
+					utils.ConsoleOutput(fmt.Sprintf("an error ocurred while breaking replication volume: %v", err))
+					exitCode = 1
+					return
+				}
+			}
+
+			utils.ConsoleOutput(fmt.Sprintf("Removing data protection object from %v volume...", secondary.VolumeName))
+			err = anfServices["Secondary"].DeleteAnfVolumeReplication(
+				cntx,
+				secondary.ResourceGroupName,
+				secondary.AnfAccountName,
+				secondary.CapacityPoolName,
+				secondary.VolumeName,
+			)
+			if err != nil && !sdkutils.IsReplicationMissingError(err) {
+				utils.ConsoleOutput(fmt.Sprintf("an error ocurred while deleting data replication: %v", err))
+				exitCode = 1
+				return
+			}
+			err = anfServices["Secondary"].WaitForNoANFResource(cntx, secondary.VolumeID, 60, 50, true)
+			if err != nil {
+				utils.ConsoleOutput(fmt.Sprintf("an error ocurred while waiting for data replication deletion: %v", err))
+				exitCode = 1
+				return
+			}
+			utils.ConsoleOutput("Data replication successfully deleted")
+		}
+
+		if !recordStep(reverseReplicationStep, secondary.VolumeID) {
+			return
+		}
+	}
+
+	// Secondary volume becomes the source, it takes the local snapshots the primary volume used to take
+	if topology.SnapshotPolicy != nil {
+		err := attachSnapshotPolicy(cntx, "Secondary")
+		if err != nil {
+			utils.ConsoleOutput(fmt.Sprintf("an error ocurred while attaching snapshot policy to %v volume: %v", secondary.VolumeName, err))
+			exitCode = 1
+			return
+		}
+	}
+
+	// Primary volume is recreated as a data protection volume of secondary volume
+	if _, completed := deploymentState.Completed(reverseDeleteVolumeStep); completed {
+		utils.ConsoleOutput(fmt.Sprintf("%v volume already removed", primary.VolumeName))
+	} else {
+		utils.ConsoleOutput(fmt.Sprintf("Removing %v volume...", primary.VolumeID))
+		err := anfServices["Primary"].DeleteAnfVolume(
+			cntx,
+			primary.ResourceGroupName,
+			primary.AnfAccountName,
+			primary.CapacityPoolName,
+			primary.VolumeName,
+		)
+		switch {
+		case sdkutils.IsNotFoundError(err):
+			utils.ConsoleOutput(fmt.Sprintf("%v volume does not exist, nothing to remove", primary.VolumeName))
+		case err != nil:
+			utils.ConsoleOutput(fmt.Sprintf("an error ocurred while deleting volume: %v", err))
+			exitCode = 1
+			return
+		default:
+			err = anfServices["Primary"].WaitForNoANFResource(cntx, primary.VolumeID, 60, 50, false)
+			if err != nil {
+				utils.ConsoleOutput(fmt.Sprintf("an error ocurred while waiting for volume deletion: %v", err))
+				exitCode = 1
+				return
+			}
+		}
+
+		if !recordStep(reverseDeleteVolumeStep, primary.VolumeID) {
+			return
+		}
+	}
+
+	if volumeID, completed := deploymentState.Completed(reverseCreateVolumeStep); completed {
+		primary.VolumeID = volumeID
+		utils.ConsoleOutput(fmt.Sprintf("%v volume already created as data protection volume, resource id: %v", primary.VolumeName, primary.VolumeID))
+	} else {
+		// A volume found after the primary volume was removed is the data protection volume of a previous attempt
+		volume, err := anfServices["Primary"].GetAnfVolume(
+			cntx,
+			primary.ResourceGroupName,
+			primary.AnfAccountName,
+			primary.CapacityPoolName,
+			primary.VolumeName,
+		)
+		switch {
+		case err == nil:
+			utils.ConsoleOutput(fmt.Sprintf("%v volume already exists, resource id: %v", primary.VolumeName, *volume.ID))
+		case sdkutils.IsNotFoundError(err):
+			utils.ConsoleOutput(fmt.Sprintf("Creating %v volume as data protection volume, remote volume id is %v...", primary.VolumeName, secondary.VolumeID))
+			dataProtectionObject, err := getDataProtectionObject("Secondary")
+			if err != nil {
+				utils.ConsoleOutput(fmt.Sprintf("an error ocurred while creating data protection object: %v", err))
+				exitCode = 1
+				return
+			}
+
+			volume, err = anfServices["Primary"].CreateAnfVolume(
+				cntx,
+				primary.Location,
+				primary.ResourceGroupName,
+				primary.AnfAccountName,
+				primary.CapacityPoolName,
+				primary.VolumeName,
+				primary.ServiceLevel,
+				getSubnetID("Primary"),
+				"",
+				protocolTypes,
+				volumeSizeBytes,
+				false,
+				true,
+				sampleTags,
+				dataProtectionObject,
+			)
+			if err != nil {
+				utils.ConsoleOutput(fmt.Sprintf("an error ocurred while creating %v volume: %v", primary.VolumeName, err))
+				exitCode = 1
+				return
+			}
+		default:
+			utils.ConsoleOutput(fmt.Sprintf("an error ocurred while getting %v volume: %v", primary.VolumeName, err))
+			exitCode = 1
+			return
+		}
+		primary.VolumeID = *volume.ID
+
+		if !recordStep(reverseCreateVolumeStep, primary.VolumeID) {
+			return
+		}
+	}
+
+	utils.ConsoleOutput("Waiting for volume to be ready...")
+	err = anfServices["Primary"].WaitForANFResource(cntx, primary.VolumeID, 60, 50, false)
+	if err != nil {
+		utils.ConsoleOutput(fmt.Sprintf("an error ocurred while waiting for %v volume: %v", primary.VolumeName, err))
+		exitCode = 1
+		return
+	}
+
+	// Authorizing replication from secondary volume, which is now the source
+	if _, completed := deploymentState.Completed(reverseAuthorizeStep); completed {
+		utils.ConsoleOutput("Replication already authorized")
+	} else {
+		utils.ConsoleOutput("Authorizing replication...")
+		err = anfServices["Secondary"].AuthorizeReplication(
+			cntx,
+			secondary.ResourceGroupName,
+			secondary.AnfAccountName,
+			secondary.CapacityPoolName,
+			secondary.VolumeName,
+			primary.VolumeID,
+		)
+		if err != nil {
+			utils.ConsoleOutput(fmt.Sprintf("an error ocurred while authorizing replication: %v", err))
+			exitCode = 1
+			return
+		}
+
+		if !recordStep(reverseAuthorizeStep, primary.VolumeID) {
+			return
+		}
+	}
+
+	utils.ConsoleOutput("Waiting for secondary volume replication be ready...")
+	err = anfServices["Secondary"].WaitForANFResource(cntx, secondary.VolumeID, 60, 50, true)
+	if err != nil {
+		utils.ConsoleOutput(fmt.Sprintf("an error ocurred while waiting for Secondary volume be replication ready: %v", err))
+		exitCode = 1
+		return
+	}
+
+	completeReverse()
+}
+
+// completeReverse swaps roles, old secondary becomes primary and old primary becomes secondary, and removes the reverse steps
+func completeReverse() {
+
+	err := swapRoles()
+	if err != nil {
+		utils.ConsoleOutput(fmt.Sprintf("an error ocurred while swapping roles: %v", err))
+		exitCode = 1
+		return
+	}
+
+	if !forgetReverseSteps() {
+		return
+	}
+
+	utils.ConsoleOutput(fmt.Sprintf("Replication direction reversed, %v is now the primary volume and %v is the secondary volume", anfResources["Primary"].VolumeName, anfResources["Secondary"].VolumeName))
+}
+
+// forgetReverseSteps removes the steps of a reverse from state file, returns false if state could not be saved
+func forgetReverseSteps() bool {
+	for _, step := range reverseSteps {
+		if _, completed := deploymentState.Completed(step); completed && !forgetStep(step) {
+			return false
+		}
+	}
+	return true
+}
+
+// swapRoles swaps primary and secondary sides in state file, then in topology file and memory. Sides of the state file
+// are renamed in the same write that replaces the authorization step with the roles swapped step, and the topology file
+// is only swapped while its primary volume is not the new primary volume, so a swap that stopped halfway can be resumed.
+func swapRoles() error {
+
+	newPrimaryVolumeID, _ := deploymentState.Completed(reverseReplicationStep)
+	newSecondaryVolumeID, _ := deploymentState.Completed(reverseCreateVolumeStep)
+
+	if _, completed := deploymentState.Completed(reverseRolesSwappedStep); !completed {
+		err := deploymentState.RenameSteps(func(name string) string {
+			switch {
+			case name == reverseAuthorizeStep:
+				return reverseRolesSwappedStep
+			case strings.HasPrefix(name, "Primary/"):
+				return strings.Replace(name, "Primary/", "Secondary/", 1)
+			case strings.HasPrefix(name, "Secondary/"):
+				return strings.Replace(name, "Secondary/", "Primary/", 1)
+			}
+			return name
+		})
+		if err != nil {
+			return err
+		}
+		utils.ConsoleOutput(fmt.Sprintf("State file %v updated with the new roles", deploymentState.Path()))
+	}
+
+	if !strings.EqualFold(anfResources["Primary"].VolumeID, newPrimaryVolumeID) {
+		err := config.SwapTopologyRoles(topologyLocation)
+		if err != nil {
+			return err
+		}
+
+		topology.Primary, topology.Secondary = topology.Secondary, topology.Primary
+		anfResources["Primary"], anfResources["Secondary"] = anfResources["Secondary"], anfResources["Primary"]
+		anfServices["Primary"], anfServices["Secondary"] = anfServices["Secondary"], anfServices["Primary"]
+		subscriptionIDs["Primary"], subscriptionIDs["Secondary"] = subscriptionIDs["Secondary"], subscriptionIDs["Primary"]
+
+		utils.ConsoleOutput(fmt.Sprintf("Topology file %v updated with the new roles", topologyLocation))
+	}
+
+	if hasSetupSteps() {
+		return deploymentState.Complete(replicationStep, newSecondaryVolumeID)
+	}
+
+	return nil
+}
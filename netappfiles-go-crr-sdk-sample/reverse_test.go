@@ -0,0 +1,209 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/config"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/sdkutils"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/uri"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/netapp/mgmt/netapp"
+	"gopkg.in/yaml.v3"
+)
+
+// failingAuthorization fails to authorize replications
+type failingAuthorization struct {
+	sdkutils.VolumesAPI
+}
+
+func (failingAuthorization) AuthorizeReplication(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string, body netapp.AuthorizeRequest) error {
+	return errors.New("authorization unavailable")
+}
+
+// topologyBreakingAuthorization authorizes replications and then overwrites the topology file, so its roles cannot be swapped
+type topologyBreakingAuthorization struct {
+	sdkutils.VolumesAPI
+}
+
+func (a topologyBreakingAuthorization) AuthorizeReplication(ctx context.Context, resourceGroupName, accountName, poolName, volumeName string, body netapp.AuthorizeRequest) error {
+	err := ioutil.WriteFile(topologyLocation, []byte("replicationSchedule: 10minutely\n"), 0644)
+	if err != nil {
+		return err
+	}
+	return a.VolumesAPI.AuthorizeReplication(ctx, resourceGroupName, accountName, poolName, volumeName, body)
+}
+
+// writeTopologyFile writes the in memory topology to the topology file
+func writeTopologyFile(t *testing.T) []byte {
+
+	t.Helper()
+
+	topologyFile, err := yaml.Marshal(topology)
+	if err != nil {
+		t.Fatalf("cannot marshal topology: %v", err)
+	}
+	err = ioutil.WriteFile(topologyLocation, topologyFile, 0644)
+	if err != nil {
+		t.Fatalf("cannot write topology: %v", err)
+	}
+
+	return topologyFile
+}
+
+func TestReverseResumesAfterFailure(t *testing.T) {
+
+	backend := newFakeSample(t)
+	writeTopologyFile(t)
+
+	runCommand(t, setup)
+	runCommand(t, failover, "-skip-snapshot")
+
+	originalPrimaryVolumeID := anfResources["Primary"].VolumeID
+	originalSecondaryVolumeID := anfResources["Secondary"].VolumeID
+
+	// Reverse stops at the authorization, previous steps are recorded
+	service := *anfServices["Secondary"]
+	service.Volumes = failingAuthorization{service.Volumes}
+	workingService := anfServices["Secondary"]
+	anfServices["Secondary"] = &service
+
+	reverse(context.Background(), []string{"-yes"})
+	if exitCode == 0 {
+		t.Fatalf("reverse with a failing authorization exited with code 0")
+	}
+	for _, step := range []string{reverseReplicationStep, reverseDeleteVolumeStep, reverseCreateVolumeStep} {
+		if _, completed := deploymentState.Completed(step); !completed {
+			t.Errorf("reverse did not record step %v", step)
+		}
+	}
+	if _, completed := deploymentState.Completed(reverseAuthorizeStep); completed {
+		t.Errorf("reverse recorded failed step %v", reverseAuthorizeStep)
+	}
+
+	// Resumed reverse keeps the data protection volume created by the failed attempt
+	anfServices["Secondary"] = workingService
+	exitCode = 0
+	runCommand(t, reverse, "-yes")
+
+	if got := countResources(backend)[uri.VolumesType]; got != 2 {
+		t.Errorf("reverse left %v volumes, want 2", got)
+	}
+	if anfResources["Primary"].VolumeID != originalSecondaryVolumeID || anfResources["Secondary"].VolumeID != originalPrimaryVolumeID {
+		t.Fatalf("roles not swapped, primary is %v and secondary is %v", anfResources["Primary"].VolumeID, anfResources["Secondary"].VolumeID)
+	}
+	assertReplicationState(t, backend, originalPrimaryVolumeID, netapp.MirrorStateMirrored, netapp.RelationshipStatusIdle)
+
+	for _, step := range reverseSteps {
+		if _, completed := deploymentState.Completed(step); completed {
+			t.Errorf("reverse left step %v in state file", step)
+		}
+	}
+	if volumeID, _ := deploymentState.Completed(stepName("Secondary", volumeStep)); volumeID != originalPrimaryVolumeID {
+		t.Errorf("state file records secondary volume %v, want %v", volumeID, originalPrimaryVolumeID)
+	}
+
+	swapped, err := config.LoadTopology(topologyLocation)
+	if err != nil {
+		t.Fatalf("cannot load topology: %v", err)
+	}
+	if swapped.Primary.VolumeName != "SecondaryVolume" {
+		t.Errorf("topology file has primary volume %v, want SecondaryVolume", swapped.Primary.VolumeName)
+	}
+}
+
+func TestReverseResumesHalfSwappedRoles(t *testing.T) {
+
+	backend := newFakeSample(t)
+	topologyFile := writeTopologyFile(t)
+
+	runCommand(t, setup)
+	runCommand(t, failover, "-skip-snapshot")
+
+	originalPrimaryVolumeID := anfResources["Primary"].VolumeID
+	originalSecondaryVolumeID := anfResources["Secondary"].VolumeID
+
+	// Roles are swapped in state file but topology file cannot be written
+	service := *anfServices["Secondary"]
+	service.Volumes = topologyBreakingAuthorization{service.Volumes}
+	workingService := anfServices["Secondary"]
+	anfServices["Secondary"] = &service
+
+	reverse(context.Background(), []string{"-yes", "-skip-snapshot"})
+	if exitCode == 0 {
+		t.Fatalf("reverse with an invalid topology file exited with code 0")
+	}
+	if _, completed := deploymentState.Completed(reverseRolesSwappedStep); !completed {
+		t.Fatalf("reverse did not record step %v", reverseRolesSwappedStep)
+	}
+	if volumeID, _ := deploymentState.Completed(stepName("Primary", volumeStep)); volumeID != originalSecondaryVolumeID {
+		t.Fatalf("state file records primary volume %v, want %v", volumeID, originalSecondaryVolumeID)
+	}
+
+	// Resumed reverse only swaps the topology file, state file is not swapped back
+	anfServices["Secondary"] = workingService
+	err := ioutil.WriteFile(topologyLocation, topologyFile, 0644)
+	if err != nil {
+		t.Fatalf("cannot restore topology: %v", err)
+	}
+	exitCode = 0
+	runCommand(t, reverse, "-yes")
+
+	if anfResources["Primary"].VolumeID != originalSecondaryVolumeID {
+		t.Errorf("primary volume is %v, want %v", anfResources["Primary"].VolumeID, originalSecondaryVolumeID)
+	}
+	if volumeID, _ := deploymentState.Completed(stepName("Primary", volumeStep)); volumeID != originalSecondaryVolumeID {
+		t.Errorf("state file records primary volume %v, want %v", volumeID, originalSecondaryVolumeID)
+	}
+	if volumeID, _ := deploymentState.Completed(replicationStep); volumeID != originalPrimaryVolumeID {
+		t.Errorf("state file records replication on %v, want %v", volumeID, originalPrimaryVolumeID)
+	}
+	for _, step := range reverseSteps {
+		if _, completed := deploymentState.Completed(step); completed {
+			t.Errorf("reverse left step %v in state file", step)
+		}
+	}
+
+	swapped, err := config.LoadTopology(topologyLocation)
+	if err != nil {
+		t.Fatalf("cannot load topology: %v", err)
+	}
+	if swapped.Primary.VolumeName != "SecondaryVolume" {
+		t.Errorf("topology file has primary volume %v, want SecondaryVolume", swapped.Primary.VolumeName)
+	}
+	assertReplicationState(t, backend, originalPrimaryVolumeID, netapp.MirrorStateMirrored, netapp.RelationshipStatusIdle)
+}
+
+func TestReverseChecksTopologyFirst(t *testing.T) {
+
+	backend := newFakeSample(t)
+	writeTopologyFile(t)
+
+	runCommand(t, setup)
+	runCommand(t, failover, "-skip-snapshot")
+
+	// Roles of a topology file without secondary side cannot be swapped, nothing is deleted
+	err := ioutil.WriteFile(topologyLocation, []byte("replicationSchedule: 10minutely\n"), 0644)
+	if err != nil {
+		t.Fatalf("cannot write topology: %v", err)
+	}
+
+	reverse(context.Background(), []string{"-yes", "-skip-snapshot"})
+	if exitCode == 0 {
+		t.Fatalf("reverse with an invalid topology file exited with code 0")
+	}
+	if _, _, found := backend.ReplicationState(anfResources["Secondary"].VolumeID); !found {
+		t.Errorf("reverse removed the replication before checking the topology file")
+	}
+	for _, step := range reverseSteps {
+		if _, completed := deploymentState.Completed(step); completed {
+			t.Errorf("reverse recorded step %v before checking the topology file", step)
+		}
+	}
+}
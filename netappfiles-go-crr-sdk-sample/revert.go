@@ -0,0 +1,183 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// In-place recovery, reverts a volume to one of its snapshots picked
+// by name or by point in time, discarding the data written after it.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/sdkutils"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/utils"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/netapp/mgmt/netapp"
+)
+
+// revert reverts a side's volume to a snapshot, destination volumes can only be reverted once their replication is broken
+func revert(cntx context.Context, args []string) {
+
+	flags := flag.NewFlagSet("revert", flag.ExitOnError)
+	side := sideFlag(flags, "Primary")
+	name := flags.String("name", "", "name of the snapshot to revert to")
+	at := flags.String("time", "", "reverts to the newest snapshot created at or before this RFC3339 time, e.g. 2021-10-15T09:30:00Z")
+	yes := flags.Bool("yes", false, "skips the confirmation prompt")
+	flags.Parse(args)
+
+	if !isValidSide(*side) {
+		return
+	}
+	if (*name == "") == (*at == "") {
+		utils.ConsoleOutput("error: provide either the snapshot name with -name or a point in time with -time")
+		exitCode = 1
+		return
+	}
+
+	var pointInTime time.Time
+	if *at != "" {
+		var err error
+		pointInTime, err = time.Parse(time.RFC3339, *at)
+		if err != nil {
+			utils.ConsoleOutput(fmt.Sprintf("error: invalid time %q, it must be in RFC3339 format: %v", *at, err))
+			exitCode = 1
+			return
+		}
+	}
+
+	resolveResourceIDs()
+
+	resources := anfResources[*side]
+
+	// Pre-checks, a destination volume is read-only while it is replicated
+	err := checkRevertible(cntx, *side)
+	if err != nil {
+		utils.ConsoleOutput(fmt.Sprintf("error: %v volume cannot be reverted: %v", resources.VolumeName, err))
+		exitCode = 1
+		return
+	}
+
+	snapshots, err := getSnapshots(cntx, *side)
+	if err != nil {
+		utils.ConsoleOutput(fmt.Sprintf("an error ocurred while listing snapshots: %v", err))
+		exitCode = 1
+		return
+	}
+
+	target, newer := selectRevertSnapshot(snapshots, *name, pointInTime)
+	if target == nil {
+		if *name != "" {
+			utils.ConsoleOutput(fmt.Sprintf("error: snapshot %v not found on %v volume", *name, resources.VolumeName))
+		} else {
+			utils.ConsoleOutput(fmt.Sprintf("error: %v volume has no snapshots created at or before %v", resources.VolumeName, pointInTime.Format(time.RFC3339)))
+		}
+		exitCode = 1
+		return
+	}
+
+	utils.ConsoleOutput(fmt.Sprintf("WARNING: %v volume %v will be reverted to snapshot %v, created %v, all data written after it will be lost", *side, resources.VolumeID, target.name, target.created.Format(time.RFC3339)))
+	for _, snapshot := range newer {
+		utils.ConsoleOutput(fmt.Sprintf("\tNewer snapshot %v, created %v, will be deleted", snapshot.name, snapshot.created.Format(time.RFC3339)))
+	}
+	if !*yes && !utils.GetConfirmation("Do you want to revert the volume?") {
+		utils.ConsoleOutput("Revert cancelled")
+		exitCode = 1
+		return
+	}
+
+	utils.ConsoleOutput(fmt.Sprintf("Reverting %v volume to snapshot %v...", resources.VolumeName, target.name))
+	err = anfServices[*side].RevertAnfVolume(
+		cntx,
+		resources.ResourceGroupName,
+		resources.AnfAccountName,
+		resources.CapacityPoolName,
+		resources.VolumeName,
+		target.snapshotID,
+	)
+	if err != nil {
+		utils.ConsoleOutput(fmt.Sprintf("an error ocurred while reverting volume: %v", err))
+		exitCode = 1
+		return
+	}
+
+	err = anfServices[*side].WaitForANFResource(cntx, resources.VolumeID, 60, 50, false)
+	if err != nil {
+		utils.ConsoleOutput(fmt.Sprintf("an error ocurred while waiting for %v volume: %v", resources.VolumeName, err))
+		exitCode = 1
+		return
+	}
+	utils.ConsoleOutput(fmt.Sprintf("%v volume successfully reverted to snapshot %v", resources.VolumeName, target.name))
+}
+
+// checkRevertible checks that a side's volume is not a replication destination, or that its replication is broken
+func checkRevertible(cntx context.Context, side string) error {
+
+	resources := anfResources[side]
+
+	volume, err := anfServices[side].GetAnfVolume(
+		cntx,
+		resources.ResourceGroupName,
+		resources.AnfAccountName,
+		resources.CapacityPoolName,
+		resources.VolumeName,
+	)
+	if err != nil {
+		return err
+	}
+
+	if volume.VolumeProperties == nil || volume.DataProtection == nil || volume.DataProtection.Replication == nil || volume.DataProtection.Replication.EndpointType != netapp.EndpointTypeDst {
+		return nil
+	}
+
+	replicationStatus, err := anfServices[side].GetAnfVolumeReplicationStatus(
+		cntx,
+		resources.ResourceGroupName,
+		resources.AnfAccountName,
+		resources.CapacityPoolName,
+		resources.VolumeName,
+	)
+	if err != nil {
+		if sdkutils.IsReplicationMissingError(err) {
+			return nil
+		}
+		return err
+	}
+	if replicationStatus.MirrorState != netapp.MirrorStateBroken {
+		return fmt.Errorf("it is a data protection destination in %v state, break the replication first", replicationStatus.MirrorState)
+	}
+
+	return nil
+}
+
+// selectRevertSnapshot picks a snapshot by name or, when no name is given, the newest one created at or before a point in time,
+// returns it along with the snapshots newer than it, which are deleted by the revert
+func selectRevertSnapshot(snapshots []sampleSnapshot, name string, pointInTime time.Time) (*sampleSnapshot, []sampleSnapshot) {
+
+	var target *sampleSnapshot
+	for i := range snapshots {
+		if name != "" && snapshots[i].name == name {
+			target = &snapshots[i]
+			break
+		}
+		// Snapshots are sorted oldest first, the last one not after the point in time wins
+		if name == "" && !snapshots[i].created.After(pointInTime) {
+			target = &snapshots[i]
+		}
+	}
+	if target == nil {
+		return nil, nil
+	}
+
+	var newer []sampleSnapshot
+	for _, snapshot := range snapshots {
+		if snapshot.created.After(target.created) {
+			newer = append(newer, snapshot)
+		}
+	}
+
+	return target, newer
+}
@@ -0,0 +1,246 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/fake"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/netapp/mgmt/netapp"
+)
+
+// revertSnapshotsCreated is the creation time of the first of the revert test snapshots, the next ones are an hour apart
+var revertSnapshotsCreated = time.Date(2021, 10, 15, 9, 0, 0, 0, time.UTC)
+
+// newRevertSample sets up the sample and takes the revert1, revert2 and revert3 snapshots of the primary volume, an hour apart
+func newRevertSample(t *testing.T) *fake.Backend {
+
+	t.Helper()
+
+	backend := newFakeSample(t)
+	runCommand(t, setup)
+
+	primary := anfResources["Primary"]
+	for i, name := range []string{"revert1", "revert2", "revert3"} {
+		snapshot, err := anfServices["Primary"].CreateAnfSnapshot(
+			context.Background(),
+			primary.Location,
+			primary.ResourceGroupName,
+			primary.AnfAccountName,
+			primary.CapacityPoolName,
+			primary.VolumeName,
+			name,
+			sampleTags,
+		)
+		if err != nil {
+			t.Fatalf("cannot create snapshot %v: %v", name, err)
+		}
+		backend.SetSnapshotCreated(*snapshot.ID, revertSnapshotsCreated.Add(time.Duration(i)*time.Hour))
+	}
+
+	return backend
+}
+
+// revertSnapshotNames returns the names of the revert test snapshots left on the primary volume, oldest first
+func revertSnapshotNames(t *testing.T) []string {
+
+	t.Helper()
+
+	snapshots, err := getSnapshots(context.Background(), "Primary")
+	if err != nil {
+		t.Fatalf("cannot list snapshots: %v", err)
+	}
+
+	var names []string
+	for _, snapshot := range snapshots {
+		if strings.HasPrefix(snapshot.name, "revert") {
+			names = append(names, snapshot.name)
+		}
+	}
+
+	return names
+}
+
+// withStdin runs a function with a standard input holding an answer
+func withStdin(t *testing.T, answer string, run func()) {
+
+	t.Helper()
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("cannot create pipe: %v", err)
+	}
+	defer reader.Close()
+
+	_, err = writer.WriteString(answer)
+	writer.Close()
+	if err != nil {
+		t.Fatalf("cannot write answer: %v", err)
+	}
+
+	stdin := os.Stdin
+	os.Stdin = reader
+	defer func() { os.Stdin = stdin }()
+
+	run()
+}
+
+func TestRevert(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantExit int
+		want     []string
+	}{
+		{
+			name: "by name",
+			args: []string{"-name", "revert2"},
+			want: []string{"revert1", "revert2"},
+		},
+		{
+			name: "at snapshot creation time",
+			args: []string{"-time", "2021-10-15T10:00:00Z"},
+			want: []string{"revert1", "revert2"},
+		},
+		{
+			name: "between snapshot creation times",
+			args: []string{"-time", "2021-10-15T09:30:00Z"},
+			want: []string{"revert1"},
+		},
+		{
+			name:     "unknown name",
+			args:     []string{"-name", "revert4"},
+			wantExit: 1,
+			want:     []string{"revert1", "revert2", "revert3"},
+		},
+		{
+			name:     "before first snapshot",
+			args:     []string{"-time", "2021-10-15T08:59:59Z"},
+			wantExit: 1,
+			want:     []string{"revert1", "revert2", "revert3"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			newRevertSample(t)
+
+			revert(context.Background(), append([]string{"-side", "Primary", "-yes"}, test.args...))
+			if exitCode != test.wantExit {
+				t.Fatalf("revert exited with code %v, want %v", exitCode, test.wantExit)
+			}
+			if got := revertSnapshotNames(t); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("snapshots after revert are %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRevertConfirmation(t *testing.T) {
+
+	newRevertSample(t)
+
+	// Anything but yes cancels the revert
+	withStdin(t, "no\n", func() {
+		revert(context.Background(), []string{"-side", "Primary", "-name", "revert1"})
+	})
+	if exitCode != 1 {
+		t.Fatalf("cancelled revert exited with code %v, want 1", exitCode)
+	}
+	if got := revertSnapshotNames(t); len(got) != 3 {
+		t.Fatalf("cancelled revert left snapshots %v", got)
+	}
+
+	exitCode = 0
+	withStdin(t, "yes\n", func() {
+		revert(context.Background(), []string{"-side", "Primary", "-name", "revert1"})
+	})
+	if exitCode != 0 {
+		t.Fatalf("confirmed revert exited with code %v", exitCode)
+	}
+	if got := revertSnapshotNames(t); !reflect.DeepEqual(got, []string{"revert1"}) {
+		t.Errorf("snapshots after revert are %v, want [revert1]", got)
+	}
+}
+
+func TestRevertMirroredDestination(t *testing.T) {
+
+	backend := newFakeSample(t)
+	runCommand(t, setup)
+
+	snapshots, err := getSnapshots(context.Background(), "Secondary")
+	if err != nil || len(snapshots) == 0 {
+		t.Fatalf("cannot list replicated snapshots: %v", err)
+	}
+
+	// Read-only destination is refused before anything is reverted
+	revert(context.Background(), []string{"-side", "Secondary", "-yes", "-name", snapshots[0].name})
+	if exitCode != 1 {
+		t.Fatalf("revert of a mirrored destination exited with code %v, want 1", exitCode)
+	}
+	assertReplicationState(t, backend, anfResources["Secondary"].VolumeID, netapp.MirrorStateMirrored, netapp.RelationshipStatusIdle)
+
+	// Broken destination can be reverted
+	exitCode = 0
+	runCommand(t, breakCommand, "-skip-snapshot")
+	runCommand(t, revert, "-side", "Secondary", "-yes", "-name", snapshots[0].name)
+}
+
+func TestSelectRevertSnapshot(t *testing.T) {
+
+	created := func(hours int) time.Time { return revertSnapshotsCreated.Add(time.Duration(hours) * time.Hour) }
+	snapshots := []sampleSnapshot{
+		{name: "revert1", created: created(0)},
+		{name: "revert2", created: created(1)},
+		{name: "revert3", created: created(2)},
+	}
+
+	tests := []struct {
+		name        string
+		snapshot    string
+		pointInTime time.Time
+		want        string
+		wantNewer   []string
+	}{
+		{name: "by name", snapshot: "revert1", want: "revert1", wantNewer: []string{"revert2", "revert3"}},
+		{name: "unknown name", snapshot: "revert4"},
+		{name: "at creation time", pointInTime: created(1), want: "revert2", wantNewer: []string{"revert3"}},
+		{name: "between creation times", pointInTime: created(1).Add(-time.Second), want: "revert1", wantNewer: []string{"revert2", "revert3"}},
+		{name: "after last", pointInTime: created(3), want: "revert3"},
+		{name: "before first", pointInTime: created(0).Add(-time.Second)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			target, newer := selectRevertSnapshot(snapshots, test.snapshot, test.pointInTime)
+			if test.want == "" {
+				if target != nil {
+					t.Fatalf("selected snapshot %v, want none", target.name)
+				}
+				return
+			}
+			if target == nil || target.name != test.want {
+				t.Fatalf("selected snapshot %v, want %v", target, test.want)
+			}
+
+			var newerNames []string
+			for _, snapshot := range newer {
+				newerNames = append(newerNames, snapshot.name)
+			}
+			if !reflect.DeepEqual(newerNames, test.wantNewer) {
+				t.Errorf("newer snapshots are %v, want %v", newerNames, test.wantNewer)
+			}
+		})
+	}
+}
@@ -0,0 +1,400 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Snapshot lifecycle of the replicated volumes, safety snapshots taken
+// before breaking a replication and list, create, delete and prune
+// subcommands to manage the snapshots created by this sample.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/uri"
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/utils"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/netapp/mgmt/netapp"
+	"github.com/Azure/go-autorest/autorest/to"
+)
+
+const (
+	// Snapshots created by this sample are named <prefix><tag>-<timestamp>, snapshots have no ARM tags in this API version
+	sampleSnapshotPrefix  string = "crr-"
+	sampleSnapshotTimeFmt string = "20060102T150405Z"
+	manualSnapshotTag     string = "manual"
+
+	// Tags of the safety snapshots taken before breaking a replication
+	preBreakSnapshotTag    string = "prebreak"
+	preFailoverSnapshotTag string = "prefailover"
+	preReverseSnapshotTag  string = "prereverse"
+)
+
+var (
+	validSnapshotTag = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9-]{0,39}$`)
+)
+
+type (
+	// sampleSnapshot - a snapshot of a volume, with the tag and time of the ones created by this sample
+	sampleSnapshot struct {
+		name       string
+		snapshotID string
+		created    time.Time
+		tag        string
+		kind       string
+	}
+)
+
+// snapshotCommand runs the list, create, delete and prune snapshot subcommands
+func snapshotCommand(cntx context.Context, args []string) {
+
+	subcommands := map[string]func(cntx context.Context, args []string){
+		"list":   listSnapshots,
+		"create": createSnapshot,
+		"delete": deleteSnapshot,
+		"prune":  pruneSnapshots,
+	}
+
+	if len(args) == 0 || subcommands[args[0]] == nil {
+		utils.ConsoleOutput("error: a snapshot subcommand is required, valid subcommands are: list, create, delete, prune")
+		exitCode = 1
+		return
+	}
+
+	resolveResourceIDs()
+
+	subcommands[args[0]](cntx, args[1:])
+}
+
+// listSnapshots prints the snapshots of a side's volume, oldest first
+func listSnapshots(cntx context.Context, args []string) {
+
+	flags := flag.NewFlagSet("snapshot list", flag.ExitOnError)
+	side := sideFlag(flags, "Secondary")
+	flags.Parse(args)
+
+	if !isValidSide(*side) {
+		return
+	}
+
+	snapshots, err := getSnapshots(cntx, *side)
+	if err != nil {
+		utils.ConsoleOutput(fmt.Sprintf("an error ocurred while listing snapshots: %v", err))
+		exitCode = 1
+		return
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "NAME\tCREATED\tKIND\tTAG")
+	for _, snapshot := range snapshots {
+		fmt.Fprintf(writer, "%v\t%v\t%v\t%v\n",
+			snapshot.name,
+			snapshot.created.Format(time.RFC3339),
+			snapshot.kind,
+			snapshot.tag,
+		)
+	}
+	err = writer.Flush()
+	if err != nil {
+		utils.ConsoleOutput(fmt.Sprintf("an error ocurred while printing snapshots: %v", err))
+		exitCode = 1
+	}
+}
+
+// createSnapshot takes a tagged snapshot of a side's volume
+func createSnapshot(cntx context.Context, args []string) {
+
+	flags := flag.NewFlagSet("snapshot create", flag.ExitOnError)
+	side := sideFlag(flags, "Secondary")
+	tag := flags.String("tag", manualSnapshotTag, "tag added to the snapshot name, letters, digits and hyphens")
+	flags.Parse(args)
+
+	if !isValidSide(*side) {
+		return
+	}
+	if !validSnapshotTag.MatchString(*tag) {
+		utils.ConsoleOutput(fmt.Sprintf("error: invalid tag %q, it must start with a letter or digit and have up to 40 letters, digits and hyphens", *tag))
+		exitCode = 1
+		return
+	}
+
+	_, err := takeSnapshot(cntx, *side, *tag)
+	if err != nil {
+		utils.ConsoleOutput(fmt.Sprintf("an error ocurred while creating snapshot: %v", err))
+		exitCode = 1
+	}
+}
+
+// deleteSnapshot deletes a snapshot of a side's volume by name
+func deleteSnapshot(cntx context.Context, args []string) {
+
+	flags := flag.NewFlagSet("snapshot delete", flag.ExitOnError)
+	side := sideFlag(flags, "Secondary")
+	name := flags.String("name", "", "name of the snapshot to delete")
+	flags.Parse(args)
+
+	if !isValidSide(*side) {
+		return
+	}
+	if *name == "" {
+		utils.ConsoleOutput("error: snapshot name is required, provide it with -name")
+		exitCode = 1
+		return
+	}
+	if strings.HasPrefix(*name, replicatedSnapshotPrefix) {
+		utils.ConsoleOutput(fmt.Sprintf("error: %v is a replication snapshot, it is managed by the replication and cannot be deleted", *name))
+		exitCode = 1
+		return
+	}
+
+	err := removeSnapshot(cntx, *side, *name)
+	if err != nil {
+		utils.ConsoleOutput(fmt.Sprintf("an error ocurred while deleting snapshot: %v", err))
+		exitCode = 1
+	}
+}
+
+// pruneSnapshots deletes the snapshots created by this sample that fall outside the retention rules,
+// the newest snapshots of each tag are always kept and replication and snapshot policy snapshots are never pruned
+func pruneSnapshots(cntx context.Context, args []string) {
+
+	flags := flag.NewFlagSet("snapshot prune", flag.ExitOnError)
+	side := sideFlag(flags, "Secondary")
+	keep := flags.Int("keep", 1, "number of newest snapshots always kept for each tag, at least 1")
+	olderThan := flags.Duration("older-than", 0, "deletes snapshots older than this duration past the ones kept, e.g. 720h")
+	tag := flags.String("tag", "", "only prunes snapshots with this tag, defaults to all tags")
+	dryRun := flags.Bool("dry-run", false, "prints the snapshots that would be deleted without deleting them")
+	flags.Parse(args)
+
+	if !isValidSide(*side) {
+		return
+	}
+
+	keepSet := false
+	flags.Visit(func(f *flag.Flag) { keepSet = keepSet || f.Name == "keep" })
+	if !keepSet && *olderThan <= 0 {
+		utils.ConsoleOutput("error: a retention rule is required, provide -keep and/or -older-than")
+		exitCode = 1
+		return
+	}
+	if *keep < 1 {
+		utils.ConsoleOutput("error: -keep must be at least 1, the newest snapshot of each tag is the rollback point")
+		exitCode = 1
+		return
+	}
+
+	snapshots, err := getSnapshots(cntx, *side)
+	if err != nil {
+		utils.ConsoleOutput(fmt.Sprintf("an error ocurred while listing snapshots: %v", err))
+		exitCode = 1
+		return
+	}
+
+	expired := expiredSnapshots(snapshots, *tag, *keep, *olderThan, time.Now().UTC())
+	if len(expired) == 0 {
+		utils.ConsoleOutput("No snapshots to prune")
+		return
+	}
+
+	for _, snapshot := range expired {
+		if *dryRun {
+			utils.ConsoleOutput(fmt.Sprintf("Would delete snapshot %v, created %v", snapshot.name, snapshot.created.Format(time.RFC3339)))
+			continue
+		}
+		err = removeSnapshot(cntx, *side, snapshot.name)
+		if err != nil {
+			utils.ConsoleOutput(fmt.Sprintf("an error ocurred while deleting snapshot: %v", err))
+			exitCode = 1
+			return
+		}
+	}
+}
+
+// expiredSnapshots returns the snapshots created by this sample with a tag that are past the newest ones to keep,
+// when a duration is set only the ones older than it. At least one snapshot is kept for each tag.
+func expiredSnapshots(snapshots []sampleSnapshot, tag string, keep int, olderThan time.Duration, now time.Time) []sampleSnapshot {
+
+	byTag := map[string][]sampleSnapshot{}
+	for _, snapshot := range snapshots {
+		if snapshot.tag == "" || (tag != "" && snapshot.tag != tag) {
+			continue
+		}
+		byTag[snapshot.tag] = append(byTag[snapshot.tag], snapshot)
+	}
+
+	if keep < 1 {
+		keep = 1
+	}
+
+	var expired []sampleSnapshot
+	for _, tagged := range byTag {
+		// Newest first, so the ones to keep come first
+		sort.Slice(tagged, func(i, j int) bool { return tagged[i].created.After(tagged[j].created) })
+		for i, snapshot := range tagged {
+			if i >= keep && (olderThan <= 0 || now.Sub(snapshot.created) > olderThan) {
+				expired = append(expired, snapshot)
+			}
+		}
+	}
+
+	sort.Slice(expired, func(i, j int) bool { return expired[i].created.Before(expired[j].created) })
+
+	return expired
+}
+
+// takeSafetySnapshot takes a snapshot of the secondary (destination) volume before its replication is broken,
+// so there is a rollback point to the last replicated data
+func takeSafetySnapshot(cntx context.Context, tag string) error {
+
+	utils.ConsoleOutput(fmt.Sprintf("Taking safety snapshot of %v volume before breaking the replication...", anfResources["Secondary"].VolumeName))
+	name, err := takeSnapshot(cntx, "Secondary", tag)
+	if err != nil {
+		return fmt.Errorf("%v, use -skip-snapshot to go ahead without a safety snapshot", err)
+	}
+	utils.ConsoleOutput(fmt.Sprintf("Safety snapshot %v taken, it can be removed with the snapshot delete or prune commands", name))
+
+	return nil
+}
+
+// takeSnapshot creates a snapshot named after a tag and the current time on a side's volume and waits for it, returns its name
+func takeSnapshot(cntx context.Context, side, tag string) (string, error) {
+
+	name := fmt.Sprintf("%v%v-%v", sampleSnapshotPrefix, tag, time.Now().UTC().Format(sampleSnapshotTimeFmt))
+
+	utils.ConsoleOutput(fmt.Sprintf("Creating snapshot %v of %v volume...", name, anfResources[side].VolumeName))
+	snapshot, err := anfServices[side].CreateAnfSnapshot(
+		cntx,
+		anfResources[side].Location,
+		anfResources[side].ResourceGroupName,
+		anfResources[side].AnfAccountName,
+		anfResources[side].CapacityPoolName,
+		anfResources[side].VolumeName,
+		name,
+		nil,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	err = anfServices[side].WaitForANFResource(cntx, *snapshot.ID, 10, 30, false)
+	if err != nil {
+		return "", err
+	}
+	utils.ConsoleOutput(fmt.Sprintf("Snapshot successfully created, resource id: %v", *snapshot.ID))
+
+	return name, nil
+}
+
+// removeSnapshot deletes a snapshot of a side's volume and waits until it is gone
+func removeSnapshot(cntx context.Context, side, name string) error {
+
+	utils.ConsoleOutput(fmt.Sprintf("Deleting snapshot %v of %v volume...", name, anfResources[side].VolumeName))
+	err := anfServices[side].DeleteAnfSnapshot(
+		cntx,
+		anfResources[side].ResourceGroupName,
+		anfResources[side].AnfAccountName,
+		anfResources[side].CapacityPoolName,
+		anfResources[side].VolumeName,
+		name,
+	)
+	if err != nil {
+		return err
+	}
+
+	snapshotID := uri.SnapshotID(
+		subscriptionIDs[side],
+		anfResources[side].ResourceGroupName,
+		anfResources[side].AnfAccountName,
+		anfResources[side].CapacityPoolName,
+		anfResources[side].VolumeName,
+		name,
+	)
+	err = anfServices[side].WaitForNoANFResource(cntx, snapshotID.String(), 10, 30, false)
+	if err != nil {
+		return err
+	}
+	utils.ConsoleOutput("Snapshot successfully deleted")
+
+	return nil
+}
+
+// getSnapshots lists the snapshots of a side's volume, oldest first
+func getSnapshots(cntx context.Context, side string) ([]sampleSnapshot, error) {
+
+	snapshotList, err := anfServices[side].ListAnfSnapshots(
+		cntx,
+		anfResources[side].ResourceGroupName,
+		anfResources[side].AnfAccountName,
+		anfResources[side].CapacityPoolName,
+		anfResources[side].VolumeName,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []sampleSnapshot
+	for _, snapshot := range snapshotList {
+		if snapshot.Name == nil {
+			continue
+		}
+		snapshots = append(snapshots, newSampleSnapshot(snapshot))
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].created.Before(snapshots[j].created) })
+
+	return snapshots, nil
+}
+
+// newSampleSnapshot classifies a snapshot as replication, sample (with its tag) or other snapshot, e.g. taken by a snapshot policy
+func newSampleSnapshot(snapshot netapp.Snapshot) sampleSnapshot {
+
+	result := sampleSnapshot{name: path.Base(*snapshot.Name), kind: "other"}
+	if snapshot.SnapshotProperties != nil {
+		result.snapshotID = to.String(snapshot.SnapshotID)
+		if snapshot.Created != nil {
+			result.created = snapshot.Created.Time
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(result.name, replicatedSnapshotPrefix):
+		result.kind = "replication"
+	case strings.HasPrefix(result.name, sampleSnapshotPrefix):
+		tagAndTime := strings.TrimPrefix(result.name, sampleSnapshotPrefix)
+		separator := strings.LastIndex(tagAndTime, "-")
+		if separator <= 0 {
+			break
+		}
+		if _, err := time.Parse(sampleSnapshotTimeFmt, tagAndTime[separator+1:]); err != nil {
+			break
+		}
+		result.kind = "sample"
+		result.tag = tagAndTime[:separator]
+	}
+
+	return result
+}
+
+// sideFlag defines the flag selecting the side whose volume snapshots are managed
+func sideFlag(flags *flag.FlagSet, defaultSide string) *string {
+	return flags.String("side", defaultSide, "side of the volume, Primary or Secondary")
+}
+
+// isValidSide checks a side name, reporting an error if it is not valid
+func isValidSide(side string) bool {
+	if side != "Primary" && side != "Secondary" {
+		utils.ConsoleOutput(fmt.Sprintf("error: invalid side %q, valid sides are: Primary, Secondary", side))
+		exitCode = 1
+		return false
+	}
+	return true
+}
@@ -0,0 +1,59 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExpiredSnapshots(t *testing.T) {
+
+	now := time.Date(2021, 10, 15, 12, 0, 0, 0, time.UTC)
+	snapshot := func(name, tag string, age time.Duration) sampleSnapshot {
+		return sampleSnapshot{name: name, tag: tag, created: now.Add(-age)}
+	}
+
+	snapshots := []sampleSnapshot{
+		snapshot("snapmirror.1", "", 300*time.Hour),
+		snapshot("crr-manual-1", "manual", 200*time.Hour),
+		snapshot("crr-prebreak-1", "prebreak", 100*time.Hour),
+		snapshot("crr-prebreak-2", "prebreak", 48*time.Hour),
+		snapshot("crr-prebreak-3", "prebreak", time.Hour),
+	}
+
+	tests := []struct {
+		name      string
+		tag       string
+		keep      int
+		olderThan time.Duration
+		want      []string
+	}{
+		{name: "keep newest two", keep: 2, want: []string{"crr-prebreak-1"}},
+		{name: "keep newest one", keep: 1, want: []string{"crr-prebreak-1", "crr-prebreak-2"}},
+		{name: "keep zero keeps newest one", keep: 0, want: []string{"crr-prebreak-1", "crr-prebreak-2"}},
+		{name: "older than keeps newest one of each tag", keep: 1, olderThan: 72 * time.Hour, want: []string{"crr-prebreak-1"}},
+		{name: "older than past the ones kept", keep: 2, olderThan: 24 * time.Hour, want: []string{"crr-prebreak-1"}},
+		{name: "older than within the ones kept", keep: 3, olderThan: 24 * time.Hour, want: nil},
+		{name: "older than everything", keep: 1, olderThan: time.Minute, want: []string{"crr-prebreak-1", "crr-prebreak-2"}},
+		{name: "single tag", tag: "manual", keep: 1, olderThan: time.Minute, want: nil},
+		{name: "unknown tag", tag: "prefailover", keep: 1, want: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			var got []string
+			for _, expired := range expiredSnapshots(snapshots, test.tag, test.keep, test.olderThan, now) {
+				got = append(got, expired.name)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("expired snapshots are %v, want %v", got, test.want)
+			}
+		})
+	}
+}
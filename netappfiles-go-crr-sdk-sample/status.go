@@ -0,0 +1,115 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+// Replication health report for the destination volumes of the
+// topology, printed as a table or as json to stdout.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/Azure-Samples/netappfiles-go-crr-sdk-sample/netappfiles-go-crr-sdk-sample/internal/utils"
+	"github.com/Azure/go-autorest/autorest/to"
+)
+
+const (
+	tableOutput string = "table"
+	jsonOutput  string = "json"
+)
+
+type (
+	// replicationHealth - replication status of a destination volume
+	replicationHealth struct {
+		VolumeID           string `json:"volumeId"`
+		Healthy            bool   `json:"healthy"`
+		MirrorState        string `json:"mirrorState"`
+		RelationshipStatus string `json:"relationshipStatus"`
+		TotalProgress      string `json:"totalProgress"`
+		ErrorMessage       string `json:"errorMessage"`
+	}
+)
+
+// status prints the replication status of every destination volume, exit code is 1 if any of them is unhealthy
+func status(cntx context.Context, args []string) {
+
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	output := flags.String("output", tableOutput, fmt.Sprintf("output format, %q or %q", tableOutput, jsonOutput))
+	flags.Parse(args)
+
+	if *output != tableOutput && *output != jsonOutput {
+		utils.ConsoleOutput(fmt.Sprintf("error: invalid output format %q, valid formats are: %v, %v", *output, tableOutput, jsonOutput))
+		exitCode = 1
+		return
+	}
+
+	resolveResourceIDs()
+
+	report := []replicationHealth{getReplicationHealth(cntx, "Secondary")}
+
+	var err error
+	if *output == jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(report)
+	} else {
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "VOLUME\tHEALTHY\tMIRROR STATE\tRELATIONSHIP STATUS\tTOTAL PROGRESS\tERROR MESSAGE")
+		for _, health := range report {
+			fmt.Fprintf(writer, "%v\t%v\t%v\t%v\t%v\t%v\n",
+				health.VolumeID,
+				health.Healthy,
+				health.MirrorState,
+				health.RelationshipStatus,
+				health.TotalProgress,
+				health.ErrorMessage,
+			)
+		}
+		err = writer.Flush()
+	}
+	if err != nil {
+		utils.ConsoleOutput(fmt.Sprintf("an error ocurred while printing replication status: %v", err))
+		exitCode = 1
+		return
+	}
+
+	for _, health := range report {
+		if !health.Healthy {
+			utils.ConsoleOutput(fmt.Sprintf("error: replication of %v volume is unhealthy", health.VolumeID))
+			exitCode = 1
+		}
+	}
+}
+
+// getReplicationHealth gets the replication status of a side's volume, failing to get it is reported as unhealthy
+func getReplicationHealth(cntx context.Context, side string) replicationHealth {
+
+	health := replicationHealth{VolumeID: anfResources[side].VolumeID}
+
+	replicationStatus, err := anfServices[side].GetAnfVolumeReplicationStatus(
+		cntx,
+		anfResources[side].ResourceGroupName,
+		anfResources[side].AnfAccountName,
+		anfResources[side].CapacityPoolName,
+		anfResources[side].VolumeName,
+	)
+	if err != nil {
+		health.ErrorMessage = err.Error()
+		return health
+	}
+
+	health.Healthy = to.Bool(replicationStatus.Healthy) && to.String(replicationStatus.ErrorMessage) == ""
+	health.MirrorState = string(replicationStatus.MirrorState)
+	health.RelationshipStatus = string(replicationStatus.RelationshipStatus)
+	health.TotalProgress = to.String(replicationStatus.TotalProgress)
+	health.ErrorMessage = to.String(replicationStatus.ErrorMessage)
+
+	return health
+}